@@ -1,8 +1,12 @@
 package agent
 
 import (
-	"encoding/json"
+	"bufio"
+	"context"
+	"errors"
 	"fmt"
+	"os"
+	"strings"
 	"time"
 
 	"github.com/yourusername/playground/internal/llm"
@@ -16,174 +20,95 @@ type Agent struct {
 	Store    *session.Store
 	Provider llm.Provider
 	RepoRoot string
+	Tools    *tools.Registry // Tools offered to the model; nil means tools.Builtin()
+
+	// Approve asks the user before a tool that runs something on their machine;
+	// nil prompts on stdin
+	Approve func(name string, args tools.Args) bool
 }
 
 // AgentConfig holds configuration for the agent
 type AgentConfig struct {
-	MaxIterations int  // Hard limit on agent loop iterations
-	Verbose       bool // Print detailed logging
-	IsAgentMode   bool // Use agent mode system prompt (conversational)
+	MaxIterations  int           // Hard limit on agent loop iterations
+	Verbose        bool          // Print detailed logging
+	IsAgentMode    bool          // Use agent mode system prompt (conversational)
+	RequestTimeout time.Duration // Limit for a single LLM call (0 = no limit)
+	HistoryTokens  int           // History size that triggers summarization into ContextSummary (0 = never)
+
+	Generation llm.GenerationOptions // Sampling settings for the chat role
 }
 
 var DefaultConfig = AgentConfig{
 	MaxIterations: 10,
 	Verbose:       false,
 	IsAgentMode:   false,
+	HistoryTokens: defaultHistoryTokens,
 }
 
 var AgentModeConfig = AgentConfig{
 	MaxIterations: 10,
 	Verbose:       false,
 	IsAgentMode:   true,
+	HistoryTokens: defaultHistoryTokens,
 }
 
-// getSystemPrompt is now in prompts.go
-// Kept as wrapper for compatibility
-func getSystemPrompt(isAgentMode bool) string {
-	return GetSystemPrompt(isAgentMode)
-}
+// defaultHistoryTokens leaves room for the system prompt, the current turn
+// and the reply in a 4k context window
+const defaultHistoryTokens = 1500
 
-// defineTools returns the tool definitions for the LLM
-func defineTools() []llm.Tool {
-	return []llm.Tool{
-		{
-			Name:        "read_file",
-			Description: "Read the contents of a file from the repository",
-			Parameters: map[string]interface{}{
-				"type": "object",
-				"properties": map[string]interface{}{
-					"path": map[string]interface{}{
-						"type":        "string",
-						"description": "Relative path to the file from repository root",
-					},
-				},
-				"required": []string{"path"},
-			},
-		},
-		{
-			Name:        "list_files",
-			Description: "List files and directories at the given path",
-			Parameters: map[string]interface{}{
-				"type": "object",
-				"properties": map[string]interface{}{
-					"path": map[string]interface{}{
-						"type":        "string",
-						"description": "Relative path to directory from repository root (use '.' for root)",
-					},
-				},
-				"required": []string{"path"},
-			},
-		},
-		{
-			Name:        "git_status",
-			Description: "Get the current Git status of the repository",
-			Parameters: map[string]interface{}{
-				"type":       "object",
-				"properties": map[string]interface{}{},
-			},
-		},
-		{
-			Name:        "git_diff",
-			Description: "Get the current Git diff (uncommitted changes)",
-			Parameters: map[string]interface{}{
-				"type":       "object",
-				"properties": map[string]interface{}{},
-			},
-		},
-		{
-			Name:        "run_command",
-			Description: "Execute a shell command (requires user approval)",
-			Parameters: map[string]interface{}{
-				"type": "object",
-				"properties": map[string]interface{}{
-					"command": map[string]interface{}{
-						"type":        "string",
-						"description": "The shell command to execute",
-					},
-				},
-				"required": []string{"command"},
-			},
-		},
-		{
-			Name:        "propose_patch",
-			Description: "Propose a code change as a unified diff patch",
-			Parameters: map[string]interface{}{
-				"type": "object",
-				"properties": map[string]interface{}{
-					"file_path": map[string]interface{}{
-						"type":        "string",
-						"description": "Relative path to the file from repository root",
-					},
-					"unified_diff": map[string]interface{}{
-						"type":        "string",
-						"description": "Complete unified diff with --- and +++ headers",
-					},
-				},
-				"required": []string{"file_path", "unified_diff"},
-			},
-		},
+// registry returns the tools offered to the model
+func (a *Agent) registry() *tools.Registry {
+	if a.Tools == nil {
+		a.Tools = tools.Builtin()
 	}
+	return a.Tools
 }
 
 // executeTool executes a tool call and returns the result
 func (a *Agent) executeTool(toolCall llm.ToolCall) (string, error) {
-	switch toolCall.Name {
-	case "read_file":
-		path, ok := toolCall.Arguments["path"].(string)
-		if !ok {
-			return "", fmt.Errorf("invalid path argument")
-		}
-		return tools.ReadFile(a.RepoRoot, path)
-
-	case "list_files":
-		path, ok := toolCall.Arguments["path"].(string)
-		if !ok {
-			return "", fmt.Errorf("invalid path argument")
+	approve := a.Approve
+	if approve == nil {
+		approve = func(name string, args tools.Args) bool {
+			stdin := bufio.NewReader(os.Stdin)
+			return a.confirmToolCall(llm.ToolCall{Name: name, Arguments: args}, func() (string, bool) {
+				line, err := stdin.ReadString('\n')
+				return line, err == nil || line != ""
+			})
 		}
-		files, err := tools.ListFiles(a.RepoRoot, path)
-		if err != nil {
-			return "", err
-		}
-		// Convert to JSON for LLM
-		data, _ := json.MarshalIndent(files, "", "  ")
-		return string(data), nil
-
-	case "git_status":
-		return tools.GitStatus(a.RepoRoot)
-
-	case "git_diff":
-		return tools.GitDiff(a.RepoRoot)
+	}
 
-	case "run_command":
-		cmd, ok := toolCall.Arguments["command"].(string)
-		if !ok {
-			return "", fmt.Errorf("invalid command argument")
-		}
-		return tools.RunCommand(a.RepoRoot, cmd)
+	result, err := a.registry().Execute(tools.Env{
+		RepoRoot: a.RepoRoot,
+		Session:  a.Session,
+		Approve:  approve,
+	}, toolCall)
 
-	case "propose_patch":
-		filePath, ok := toolCall.Arguments["file_path"].(string)
-		if !ok {
-			return "", fmt.Errorf("invalid file_path argument")
-		}
-		unifiedDiff, ok := toolCall.Arguments["unified_diff"].(string)
-		if !ok {
-			return "", fmt.Errorf("invalid unified_diff argument")
-		}
+	var argErr *tools.ArgumentError
+	if errors.As(err, &argErr) {
+		a.Session.Stats.RecordArgumentError(toolCall.Name)
+	}
 
-		// Add patch to session
-		newPatch := session.Patch{
-			FilePath:    filePath,
-			UnifiedDiff: unifiedDiff,
-			CreatedAt:   time.Now(),
-		}
-		a.Session.PendingPatches = append(a.Session.PendingPatches, newPatch)
+	return result, err
+}
 
-		return fmt.Sprintf("Patch proposed for %s. User can review with 'pg review' and apply with 'pg apply'.", filePath), nil
+// confirmToolCall asks the user whether a tool call that needs approval may run
+func (a *Agent) confirmToolCall(call llm.ToolCall, readLine func() (string, bool)) bool {
+	if command, ok := call.Arguments["command"].(string); ok {
+		fmt.Printf("\n⚠️  The agent wants to run this command:\n")
+		fmt.Printf("   %s\n\n", command)
+		fmt.Printf("Allow this command? [y/N]: ")
+	} else {
+		fmt.Printf("\n⚠️  The agent wants to use %s: %s\n\n", call.Name, a.registry().Describe(call))
+		fmt.Printf("Allow this? [y/N]: ")
+	}
 
-	default:
-		return "", fmt.Errorf("unknown tool: %s", toolCall.Name)
+	response, ok := readLine()
+	if !ok {
+		return false
 	}
+
+	response = strings.TrimSpace(strings.ToLower(response))
+	return response == "y" || response == "yes"
 }
 
 // logToolCall records a tool invocation in the session history
@@ -201,3 +126,11 @@ func (a *Agent) logToolCall(toolCall llm.ToolCall, result string, err error) {
 
 	a.Session.ToolHistory = append(a.Session.ToolHistory, historyEntry)
 }
+
+// requestContext derives the context for a single LLM call
+func requestContext(ctx context.Context, config AgentConfig) (context.Context, context.CancelFunc) {
+	if config.RequestTimeout > 0 {
+		return context.WithTimeout(ctx, config.RequestTimeout)
+	}
+	return context.WithCancel(ctx)
+}
@@ -0,0 +1,508 @@
+package agent
+
+import (
+	"context"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/yourusername/playground/internal/llm"
+	"github.com/yourusername/playground/internal/session"
+	"github.com/yourusername/playground/internal/tools"
+)
+
+var update = flag.Bool("update", false, "re-record cassettes in testdata from the scripted responses")
+
+const testMainGo = "package main\n\nfunc main() {\n\tprintln(\"hello\")\n}\n"
+
+// testMainGoRead is what read_file returns for testMainGo
+const testMainGoRead = "main.go (lines 1-5 of 5)\n1  package main\n2  \n3  func main() {\n4  \tprintln(\"hello\")\n5  }\n"
+
+const testPatch = `--- a/main.go
++++ b/main.go
+@@ -1,5 +1,5 @@
+ package main
+
+ func main() {
+-	println("hello")
++	println("hello, world")
+ }
+`
+
+// testPatchQueued is testPatch as queued, after Lint restores the space its
+// blank context line lost
+var testPatchQueued = strings.Replace(testPatch, "package main\n\n", "package main\n \n", 1)
+
+// patchScript reads main.go, proposes a patch for it and then answers
+var patchScript = []llm.Response{
+	{
+		ToolCalls: []llm.ToolCall{
+			{ID: "call_1", Name: "read_file", Arguments: map[string]interface{}{"path": "main.go"}},
+		},
+		FinishReason: "tool_calls",
+	},
+	{
+		Content: "I'll change the greeting.",
+		ToolCalls: []llm.ToolCall{
+			{ID: "call_2", Name: "propose_patch", Arguments: map[string]interface{}{"file_path": "main.go", "unified_diff": testPatch}},
+		},
+		FinishReason: "tool_calls",
+	},
+	{
+		Content:      "I proposed a patch that makes main.go print \"hello, world\".",
+		FinishReason: "stop",
+	},
+}
+
+// cassetteProvider replays testdata/cassettes/<name>.json and checks that it is used up.
+// With -update the scripted responses are recorded instead, capturing the current prompts.
+func cassetteProvider(t *testing.T, name string, script []llm.Response) llm.Provider {
+	t.Helper()
+
+	path := filepath.Join("testdata", "cassettes", name+".json")
+	if *update {
+		return llm.NewRecordingProvider(&scriptedProvider{responses: script}, path)
+	}
+
+	replay, err := llm.NewReplayProvider(path)
+	if err != nil {
+		t.Fatalf("Failed to load cassette (run with -update to record it): %v", err)
+	}
+	t.Cleanup(func() {
+		if n := replay.Remaining(); n != 0 {
+			t.Errorf("%d recorded interactions were not replayed", n)
+		}
+	})
+	return replay
+}
+
+// newTestAgent creates an agent over a temporary repository containing main.go
+func newTestAgent(t *testing.T, provider llm.Provider) *Agent {
+	t.Helper()
+
+	repo := t.TempDir()
+	if err := os.WriteFile(filepath.Join(repo, "main.go"), []byte(testMainGo), 0644); err != nil {
+		t.Fatalf("Failed to write main.go: %v", err)
+	}
+
+	store, err := session.NewStore(repo)
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+
+	return &Agent{
+		Session: &session.Session{
+			ID:        "pg-test",
+			Repo:      repo,
+			Goal:      "Update the greeting",
+			CreatedAt: time.Now(),
+		},
+		Store:    store,
+		Provider: provider,
+		RepoRoot: repo,
+	}
+}
+
+// checkPatchSession verifies the tool history and patch left behind by patchScript
+func checkPatchSession(t *testing.T, a *Agent) {
+	t.Helper()
+
+	if len(a.Session.ToolHistory) != 2 {
+		t.Fatalf("Expected 2 tool calls in history, got %d", len(a.Session.ToolHistory))
+	}
+	if got := a.Session.ToolHistory[0]; got.ToolName != "read_file" || got.Result != testMainGoRead || got.Error != "" {
+		t.Errorf("Unexpected read_file entry: %+v", got)
+	}
+
+	if len(a.Session.PendingPatches) != 1 {
+		t.Fatalf("Expected 1 pending patch, got %d", len(a.Session.PendingPatches))
+	}
+	if got := a.Session.PendingPatches[0]; got.FilePath != "main.go" || got.UnifiedDiff != testPatchQueued || len(got.Repairs) != 1 {
+		t.Errorf("Unexpected patch: %+v", got)
+	}
+
+	saved, err := a.Store.Load(a.Session.ID)
+	if err != nil {
+		t.Fatalf("Session was not saved: %v", err)
+	}
+	if len(saved.PendingPatches) != 1 {
+		t.Errorf("Expected the saved session to hold the patch, got %d", len(saved.PendingPatches))
+	}
+}
+
+func TestRunProposesPatch(t *testing.T) {
+	a := newTestAgent(t, cassetteProvider(t, "run_propose_patch", patchScript))
+
+	result, err := a.Run(context.Background(), "Make main.go greet the world", DefaultConfig)
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if result != patchScript[2].Content {
+		t.Errorf("Expected final answer %q, got %q", patchScript[2].Content, result)
+	}
+
+	checkPatchSession(t, a)
+}
+
+func TestRunReportsToolErrors(t *testing.T) {
+	script := []llm.Response{
+		{
+			ToolCalls: []llm.ToolCall{
+				{ID: "call_1", Name: "read_file", Arguments: map[string]interface{}{"path": "missing.go"}},
+			},
+			FinishReason: "tool_calls",
+		},
+		{
+			Content:      "missing.go does not exist in this repository.",
+			FinishReason: "stop",
+		},
+	}
+	a := newTestAgent(t, cassetteProvider(t, "run_tool_error", script))
+
+	result, err := a.Run(context.Background(), "What is in missing.go?", DefaultConfig)
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if result != script[1].Content {
+		t.Errorf("Expected final answer %q, got %q", script[1].Content, result)
+	}
+
+	// The replayed second request proves the error reached the model as a tool result
+	if len(a.Session.ToolHistory) != 1 || !strings.Contains(a.Session.ToolHistory[0].Error, "file not found") {
+		t.Errorf("Expected a failed read_file in history, got %+v", a.Session.ToolHistory)
+	}
+}
+
+func TestRunReportsInvalidArguments(t *testing.T) {
+	script := []llm.Response{
+		{
+			ToolCalls: []llm.ToolCall{
+				{ID: "call_1", Name: "read_file", Arguments: map[string]interface{}{"file": "main.go", "start_line": "1"}},
+			},
+			FinishReason: "tool_calls",
+		},
+		{
+			ToolCalls: []llm.ToolCall{
+				{ID: "call_2", Name: "read_file", Arguments: map[string]interface{}{"path": "main.go", "start_line": float64(1)}},
+			},
+			FinishReason: "tool_calls",
+		},
+		{
+			Content:      "main.go prints \"hello\".",
+			FinishReason: "stop",
+		},
+	}
+	a := newTestAgent(t, cassetteProvider(t, "run_invalid_args", script))
+
+	if _, err := a.Run(context.Background(), "What does main.go print?", DefaultConfig); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	// The model is told exactly what was wrong and what the call should look like
+	feedback := a.Session.History[2]
+	for _, want := range []string{
+		`unknown argument "file"`,
+		`"path" is required`,
+		`"start_line" must be an integer, got string "1"`,
+		`Expected arguments: {"path": string, "end_line"?: integer, "start_line"?: integer}`,
+	} {
+		if feedback.Role != "tool" || !strings.Contains(feedback.Content, want) {
+			t.Errorf("Expected tool feedback to contain %q, got %+v", want, feedback)
+		}
+	}
+
+	if got := a.Session.Stats.ArgumentErrors; len(got) != 1 || got["read_file"] != 1 {
+		t.Errorf("Expected one read_file argument error in stats, got %v", got)
+	}
+}
+
+func TestRunStreamingProposesPatch(t *testing.T) {
+	a := newTestAgent(t, cassetteProvider(t, "stream_propose_patch", patchScript))
+
+	output := make(chan string, 10)
+	errc := make(chan error, 1)
+	go func() {
+		errc <- a.RunStreaming(context.Background(), "Make main.go greet the world", AgentModeConfig, output)
+	}()
+
+	var streamed strings.Builder
+	for chunk := range output {
+		streamed.WriteString(chunk)
+	}
+	if err := <-errc; err != nil {
+		t.Fatalf("RunStreaming failed: %v", err)
+	}
+
+	// Tool calls show up as progress lines rather than JSON
+	want := "🔧 reading main.go…\n" +
+		patchScript[1].Content + "\n🔧 proposing a patch for main.go…\n" +
+		patchScript[2].Content + "\n"
+	if streamed.String() != want {
+		t.Errorf("Expected streamed output %q, got %q", want, streamed.String())
+	}
+
+	checkPatchSession(t, a)
+}
+
+func TestChatSessionProposesPatch(t *testing.T) {
+	a := newTestAgent(t, cassetteProvider(t, "chat_propose_patch", patchScript))
+
+	chat := NewChatSession(a, a.Store)
+	chat.Input = strings.NewReader("Make main.go greet the world\nstatus\nexit\n")
+
+	if err := chat.Run(); err != nil {
+		t.Fatalf("Chat session failed: %v", err)
+	}
+
+	if len(chat.Messages) != 2 {
+		t.Fatalf("Expected one exchange in chat history, got %q", chat.Messages)
+	}
+	if !strings.Contains(chat.Messages[1], patchScript[2].Content) {
+		t.Errorf("Expected the agent's answer in chat history, got %q", chat.Messages[1])
+	}
+
+	checkPatchSession(t, a)
+}
+
+// followUpScript answers a question about main.go, then changes it in a second turn
+// without reading it again, which only works if the first turn is remembered
+var followUpScript = []llm.Response{
+	{
+		ToolCalls: []llm.ToolCall{
+			{ID: "call_1", Name: "read_file", Arguments: map[string]interface{}{"path": "main.go"}},
+		},
+		FinishReason: "tool_calls",
+	},
+	{Content: "It prints \"hello\".", FinishReason: "stop"},
+	{
+		ToolCalls: []llm.ToolCall{
+			{ID: "call_2", Name: "propose_patch", Arguments: map[string]interface{}{"file_path": "main.go", "unified_diff": testPatch}},
+		},
+		FinishReason: "tool_calls",
+	},
+	{Content: "Done, it now greets the world.", FinishReason: "stop"},
+}
+
+// checkFollowUpHistory verifies the session history left behind by followUpScript
+func checkFollowUpHistory(t *testing.T, history []session.Message) {
+	t.Helper()
+
+	roles := make([]string, len(history))
+	for i, msg := range history {
+		roles[i] = msg.Role
+	}
+	want := "user,assistant,tool,assistant,user,assistant,tool,assistant"
+	if got := strings.Join(roles, ","); got != want {
+		t.Fatalf("Expected history roles %s, got %s", want, got)
+	}
+
+	if history[2].Content != testMainGoRead || history[2].ToolCallID != "call_1" {
+		t.Errorf("Expected the first tool result in history, got %+v", history[2])
+	}
+	if history[7].Content != followUpScript[3].Content {
+		t.Errorf("Expected the final answer last in history, got %+v", history[7])
+	}
+}
+
+func TestChatSessionRemembersEarlierTurns(t *testing.T) {
+	a := newTestAgent(t, cassetteProvider(t, "chat_follow_up", followUpScript))
+
+	chat := NewChatSession(a, a.Store)
+	chat.Input = strings.NewReader("What does main.go print?\nChange it to greet the world\nexit\n")
+
+	if err := chat.Run(); err != nil {
+		t.Fatalf("Chat session failed: %v", err)
+	}
+
+	checkFollowUpHistory(t, a.Session.History)
+	if len(a.Session.PendingPatches) != 1 {
+		t.Errorf("Expected the follow-up to propose a patch, got %d", len(a.Session.PendingPatches))
+	}
+}
+
+func TestRunResumesConversation(t *testing.T) {
+	provider := cassetteProvider(t, "run_resume", followUpScript)
+	first := newTestAgent(t, provider)
+
+	if _, err := first.Run(context.Background(), "What does main.go print?", DefaultConfig); err != nil {
+		t.Fatalf("First turn failed: %v", err)
+	}
+	if err := first.Store.Save(first.Session); err != nil {
+		t.Fatalf("Failed to save session: %v", err)
+	}
+
+	// A new process resuming the session only has what was saved
+	resumed, err := first.Store.Load(first.Session.ID)
+	if err != nil {
+		t.Fatalf("Failed to load session: %v", err)
+	}
+	second := &Agent{Session: resumed, Store: first.Store, Provider: provider, RepoRoot: first.RepoRoot}
+
+	if _, err := second.Run(context.Background(), "Change it to greet the world", DefaultConfig); err != nil {
+		t.Fatalf("Resumed turn failed: %v", err)
+	}
+
+	checkFollowUpHistory(t, resumed.History)
+}
+
+// TestConversationCarriesReviewFeedback checks that rejections reach the model once, with the next turn
+func TestConversationCarriesReviewFeedback(t *testing.T) {
+	a := newTestAgent(t, &scriptedProvider{})
+	a.Session.Feedback = []string{"The user rejected your patch to main.go: keep the greeting short"}
+
+	messages := a.conversation("Try again", AgentModeConfig)
+	want := "Since your last reply:\n- The user rejected your patch to main.go: keep the greeting short\n\nTry again"
+	if got := messages[len(messages)-1].Content; got != want {
+		t.Errorf("Expected user message %q, got %q", want, got)
+	}
+	if len(a.Session.Feedback) != 0 {
+		t.Errorf("Expected the feedback to be used up, got %q", a.Session.Feedback)
+	}
+
+	messages = a.conversation("And now?", AgentModeConfig)
+	if got := messages[len(messages)-1].Content; got != "And now?" {
+		t.Errorf("Expected feedback only once, got %q", got)
+	}
+}
+
+func TestSummarize(t *testing.T) {
+	// Two finished exchanges and the start of a third
+	history := []session.Message{
+		{Role: "user", Content: "What does main.go print?"},
+		{Role: "assistant", ToolCalls: []session.MessageToolCall{{ID: "call_1", Name: "read_file", Arguments: map[string]interface{}{"path": "main.go"}}}},
+		{Role: "tool", Name: "read_file", ToolCallID: "call_1", Content: testMainGo},
+		{Role: "assistant", Content: "It prints \"hello\"."},
+		{Role: "user", Content: "Change it to greet the world"},
+		{Role: "assistant", Content: "Done."},
+	}
+	summary := "Progress:\n- Read main.go, which prints \"hello\""
+
+	tests := []struct {
+		name          string
+		history       []session.Message
+		historyTokens int
+		summarized    bool
+		keptMessages  int
+	}{
+		{"Under the limit", history, 1000, false, 6},
+		{"Over the limit", history, 10, true, 2},
+		{"Only the latest exchange", history[4:], 1, false, 2},
+		{"Disabled", history, 0, false, 6},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			provider := &scriptedProvider{responses: []llm.Response{{Content: summary}}}
+			a := newTestAgent(t, provider)
+			a.Session.ContextSummary = "Progress:\n- Session started"
+			a.Session.History = append([]session.Message(nil), tt.history...)
+
+			config := AgentModeConfig
+			config.HistoryTokens = tt.historyTokens
+
+			summarized, err := a.Summarize(context.Background(), config)
+			if err != nil {
+				t.Fatalf("Summarize failed: %v", err)
+			}
+			if summarized != tt.summarized {
+				t.Fatalf("Expected summarized=%v, got %v", tt.summarized, summarized)
+			}
+			if len(a.Session.History) != tt.keptMessages {
+				t.Errorf("Expected %d messages kept, got %d", tt.keptMessages, len(a.Session.History))
+			}
+			if !tt.summarized {
+				if len(provider.requests) != 0 {
+					t.Errorf("Expected no model call, got %d", len(provider.requests))
+				}
+				return
+			}
+
+			// The summarizer sees the earlier summary and the older turns, not the latest exchange
+			request := provider.requests[0][1].Content
+			for _, want := range []string{"Session started", "Tool result (read_file): package main", "It prints"} {
+				if !strings.Contains(request, want) {
+					t.Errorf("Expected summarizer prompt to contain %q, got:\n%s", want, request)
+				}
+			}
+			if strings.Contains(request, "greet the world") {
+				t.Errorf("The latest exchange should not be summarized:\n%s", request)
+			}
+
+			// Later prompts carry the summary in place of the dropped turns
+			messages := a.conversation("And the tests?", config)
+			if messages[1].Role != "system" || !strings.Contains(messages[1].Content, summary) {
+				t.Errorf("Expected the summary after the system prompt, got %+v", messages[1])
+			}
+			if a.Session.ContextSummary != summary {
+				t.Errorf("Expected ContextSummary %q, got %q", summary, a.Session.ContextSummary)
+			}
+		})
+	}
+}
+
+// scriptedProvider returns canned responses in order; it stands in for a model when recording
+type scriptedProvider struct {
+	responses []llm.Response
+	calls     int
+	requests  [][]llm.Message // Messages of each Chat call
+}
+
+func (p *scriptedProvider) Chat(ctx context.Context, messages []llm.Message, tools []llm.Tool, opts llm.GenerationOptions) (*llm.Response, error) {
+	p.requests = append(p.requests, messages)
+	response := p.responses[p.calls]
+	p.calls++
+	return &response, nil
+}
+
+func (p *scriptedProvider) ChatStream(ctx context.Context, messages []llm.Message, tools []llm.Tool, opts llm.GenerationOptions) (<-chan llm.StreamChunk, error) {
+	response := p.responses[p.calls]
+	p.calls++
+
+	words := strings.SplitAfter(response.Content, " ")
+	out := make(chan llm.StreamChunk, len(words)+len(response.ToolCalls)+1)
+	for _, word := range words {
+		if word != "" {
+			out <- llm.StreamChunk{Content: word}
+		}
+	}
+	for i := range response.ToolCalls {
+		out <- llm.StreamChunk{ToolCall: &response.ToolCalls[i]}
+	}
+	out <- llm.StreamChunk{FinishReason: response.FinishReason}
+	close(out)
+	return out, nil
+}
+
+func (p *scriptedProvider) Name() string {
+	return "scripted"
+}
+
+// TestSystemPromptListsTools checks that the prompt's tool list comes from the registry
+func TestSystemPromptListsTools(t *testing.T) {
+	defs := tools.Builtin().Definitions()
+	for _, agentMode := range []bool{false, true} {
+		prompt := GetSystemPrompt(agentMode, defs)
+		if strings.Contains(prompt, "{{tools}}") {
+			t.Fatalf("agent mode %v: tool list was not filled in", agentMode)
+		}
+		for _, def := range defs {
+			if !strings.Contains(prompt, "\n- "+def.Name+"(") {
+				t.Errorf("agent mode %v: %s is not listed", agentMode, def.Name)
+			}
+		}
+	}
+
+	prompt := GetSystemPrompt(true, defs)
+	for _, want := range []string{
+		"- read_file(path, end_line?, start_line?): ",
+		"- propose_patch(unified_diff, file_path?): ",
+		"- git_status(): ",
+	} {
+		if !strings.Contains(prompt, want) {
+			t.Errorf("prompt does not contain %q", want)
+		}
+	}
+}
@@ -2,11 +2,16 @@ package agent
 
 import (
 	"bufio"
+	"context"
 	"fmt"
+	"io"
 	"os"
+	"os/signal"
 	"strings"
 
+	"github.com/yourusername/playground/internal/llm"
 	"github.com/yourusername/playground/internal/session"
+	"github.com/yourusername/playground/internal/tools"
 )
 
 // ChatSession represents an interactive agent chat session
@@ -14,37 +19,62 @@ type ChatSession struct {
 	Agent    *Agent
 	Session  *session.Session
 	Store    *session.Store
-	Messages []string // Chat history for display
+	Config   AgentConfig
+	Messages []string  // Chat history for display
+	Input    io.Reader // User input, os.Stdin unless replaced (e.g. by tests)
+	input    *bufio.Scanner
 	running  bool
 }
 
 // NewChatSession creates a new interactive chat session
 func NewChatSession(agent *Agent, store *session.Store) *ChatSession {
-	return &ChatSession{
+	cs := &ChatSession{
 		Agent:    agent,
 		Session:  agent.Session,
 		Store:    store,
+		Config:   AgentModeConfig,
 		Messages: []string{},
+		Input:    os.Stdin,
 		running:  true,
 	}
+
+	// Approval prompts read from the same input as the chat
+	if agent.Approve == nil {
+		agent.Approve = func(name string, args tools.Args) bool {
+			return agent.confirmToolCall(llm.ToolCall{Name: name, Arguments: args}, cs.readLine)
+		}
+	}
+
+	// A resumed session carries its conversation; rebuild the display history from it
+	for _, msg := range agent.Session.History {
+		switch {
+		case msg.Role == "user":
+			cs.Messages = append(cs.Messages, "You: "+msg.Content)
+		case msg.Role == "assistant" && msg.Content != "":
+			cs.Messages = append(cs.Messages, "Agent: "+msg.Content)
+		}
+	}
+
+	return cs
 }
 
 // Run starts the interactive chat loop
 func (cs *ChatSession) Run() error {
 	cs.displayWelcome()
 
-	reader := bufio.NewScanner(os.Stdin)
+	cs.input = bufio.NewScanner(cs.Input)
 
 	for cs.running {
 		// Display prompt
 		fmt.Print("\nYou: ")
 
 		// Read user input
-		if !reader.Scan() {
+		line, ok := cs.readLine()
+		if !ok {
 			break
 		}
 
-		input := strings.TrimSpace(reader.Text())
+		input := strings.TrimSpace(line)
 
 		if input == "" {
 			continue
@@ -63,13 +93,16 @@ func (cs *ChatSession) Run() error {
 
 		fmt.Print("\nAgent: ")
 
+		// Ctrl-C cancels this turn only; the session keeps running
+		ctx, stop := interruptContext()
+
 		// Create channel for streaming output
 		outputChan := make(chan string, 10)
 		var fullResponse string
 
 		// Start streaming in goroutine
 		go func() {
-			cs.Agent.RunStreaming(input, AgentModeConfig, outputChan)
+			cs.Agent.RunStreaming(ctx, input, cs.Config, outputChan)
 		}()
 
 		// Display streaming output as it arrives
@@ -78,11 +111,22 @@ func (cs *ChatSession) Run() error {
 			fullResponse += chunk
 		}
 
+		// Fold older turns into the session summary once the history gets long
+		if ctx.Err() == nil {
+			if summarized, err := cs.Agent.Summarize(ctx, cs.Config); err != nil {
+				fmt.Printf("\nWarning: %v\n", err)
+			} else if summarized {
+				fmt.Println("\n(Earlier conversation summarized to stay within the context window)")
+			}
+		}
+
+		stop()
+
 		cs.Messages = append(cs.Messages, "Agent: "+fullResponse)
 
 		// If agent proposed patches, prompt for review
 		if len(cs.Session.PendingPatches) > 0 {
-			fmt.Println("\n💡 Type 'review' to see the changes, or 'apply' to accept them.")
+			fmt.Println("\n💡 Type 'review' to go through the changes, or 'apply' to accept them all.")
 		}
 
 		// Save session after each interaction
@@ -94,6 +138,23 @@ func (cs *ChatSession) Run() error {
 	return nil
 }
 
+// readLine reads the next line of user input; ok is false at end of input
+func (cs *ChatSession) readLine() (line string, ok bool) {
+	if cs.input == nil {
+		cs.input = bufio.NewScanner(cs.Input)
+	}
+	if !cs.input.Scan() {
+		return "", false
+	}
+	return cs.input.Text(), true
+}
+
+// interruptContext returns a context that is cancelled on the next SIGINT.
+// Call stop to restore the default Ctrl-C behaviour.
+func interruptContext() (context.Context, context.CancelFunc) {
+	return signal.NotifyContext(context.Background(), os.Interrupt)
+}
+
 // displayWelcome shows the welcome message
 func (cs *ChatSession) displayWelcome() {
 	fmt.Println("╔════════════════════════════════════════════════════════════╗")
@@ -103,16 +164,39 @@ func (cs *ChatSession) displayWelcome() {
 	fmt.Printf("Session: %s\n", cs.Session.ID)
 	fmt.Printf("Goal: %s\n", cs.Session.Goal)
 	fmt.Println()
+
+	if len(cs.Messages) > 0 {
+		fmt.Printf("Restored conversation (%d messages). Last exchange:\n", len(cs.Messages))
+		start := len(cs.Messages) - 2
+		if start < 0 {
+			start = 0
+		}
+		for _, msg := range cs.Messages[start:] {
+			fmt.Printf("  %s\n", truncateLine(msg, 200))
+		}
+		fmt.Println()
+	}
+
 	fmt.Println("Available commands:")
-	fmt.Println("  review   - Show pending patches")
+	fmt.Println("  review   - Accept, reject or edit pending patches")
 	fmt.Println("  apply    - Apply pending patches")
 	fmt.Println("  status   - Show session status")
 	fmt.Println("  exit     - Exit agent mode")
 	fmt.Println()
 	fmt.Println("Just type naturally to chat with the agent.")
+	fmt.Println("Press Ctrl-C while the agent is working to stop the current reply.")
 	fmt.Println("────────────────────────────────────────────────────────────")
 }
 
+// truncateLine shortens text to one line of at most n bytes for display
+func truncateLine(text string, n int) string {
+	text = strings.Join(strings.Fields(text), " ")
+	if len(text) <= n {
+		return text
+	}
+	return text[:n] + "..."
+}
+
 // isCommand checks if input is a command
 func (cs *ChatSession) isCommand(input string) bool {
 	commands := []string{"review", "apply", "status", "exit", "quit", "help"}
@@ -1,12 +1,11 @@
 package agent
 
 import (
-	"bufio"
 	"fmt"
-	"os"
 	"strings"
 
 	"github.com/yourusername/playground/internal/patch"
+	"github.com/yourusername/playground/internal/review"
 )
 
 // handleCommand processes in-chat commands
@@ -29,7 +28,7 @@ func (cs *ChatSession) handleCommand(input string) error {
 	}
 }
 
-// handleReview shows all pending patches
+// handleReview walks through the pending patches, then applies the accepted ones
 func (cs *ChatSession) handleReview() error {
 	if len(cs.Session.PendingPatches) == 0 {
 		fmt.Println("No pending patches to review.")
@@ -40,15 +39,23 @@ func (cs *ChatSession) handleReview() error {
 	fmt.Printf("  Pending Patches: %d\n", len(cs.Session.PendingPatches))
 	fmt.Printf("═══════════════════════════════════════\n\n")
 
-	for i, p := range cs.Session.PendingPatches {
-		fmt.Printf("═══ Patch %d/%d ═══\n", i+1, len(cs.Session.PendingPatches))
-		fmt.Printf("File: %s\n", p.FilePath)
-		fmt.Printf("Created: %s\n\n", p.CreatedAt.Format("2006-01-02 15:04:05"))
-		fmt.Println(p.UnifiedDiff)
-		fmt.Println()
+	reviewer := &review.Reviewer{Session: cs.Session, ReadLine: cs.readLine}
+	accepted := reviewer.Run()
+
+	var err error
+	if len(accepted) > 0 {
+		fmt.Printf("\nApplying %d accepted patch(es)...\n", len(accepted))
+		if err = review.Apply(cs.Agent.RepoRoot, cs.Session, accepted, patch.DefaultOptions); err == nil {
+			fmt.Printf("\n✅ Successfully applied %d patch(es)\n", len(accepted))
+		}
 	}
+	fmt.Printf("%d patch(es) still pending.\n", len(cs.Session.PendingPatches))
 
-	return nil
+	// Decisions are kept even when applying fails
+	if serr := cs.Store.Save(cs.Session); serr != nil {
+		return fmt.Errorf("failed to save session: %w", serr)
+	}
+	return err
 }
 
 // handleApply applies pending patches with user confirmation
@@ -61,10 +68,9 @@ func (cs *ChatSession) handleApply() error {
 	fmt.Printf("\nAbout to apply %d patch(es) to the repository.\n", len(cs.Session.PendingPatches))
 	fmt.Print("Apply all patches? [y/N]: ")
 
-	reader := bufio.NewReader(os.Stdin)
-	response, err := reader.ReadString('\n')
-	if err != nil {
-		return fmt.Errorf("failed to read input: %w", err)
+	response, ok := cs.readLine()
+	if !ok {
+		return fmt.Errorf("failed to read input: end of input")
 	}
 
 	response = strings.TrimSpace(strings.ToLower(response))
@@ -73,34 +79,16 @@ func (cs *ChatSession) handleApply() error {
 		return nil
 	}
 
-	// Apply patches
-	applied := 0
-	for i, p := range cs.Session.PendingPatches {
-		fmt.Printf("Applying patch %d/%d: %s... ", i+1, len(cs.Session.PendingPatches), p.FilePath)
-
-		patchToApply := patch.Patch{
-			FilePath:    p.FilePath,
-			UnifiedDiff: p.UnifiedDiff,
-		}
-
-		if err := patch.Apply(cs.Agent.RepoRoot, patchToApply); err != nil {
-			fmt.Printf("❌ FAILED\n")
-			fmt.Printf("Error: %v\n", err)
-			fmt.Printf("\nApplied %d/%d patches before failure.\n", applied, len(cs.Session.PendingPatches))
-			return fmt.Errorf("patch application failed")
-		}
-
-		fmt.Printf("✓\n")
-		applied++
+	// Nothing is written unless every patch applies
+	n := len(cs.Session.PendingPatches)
+	if err := review.Apply(cs.Agent.RepoRoot, cs.Session, review.All(cs.Session), patch.DefaultOptions); err != nil {
+		return err
 	}
-
-	// Clear pending patches
-	cs.Session.PendingPatches = nil
 	if err := cs.Store.Save(cs.Session); err != nil {
 		return fmt.Errorf("failed to save session: %w", err)
 	}
 
-	fmt.Printf("\n✅ Successfully applied %d patch(es)\n", applied)
+	fmt.Printf("\n✅ Successfully applied %d patch(es)\n", n)
 	return nil
 }
 
@@ -116,7 +104,14 @@ func (cs *ChatSession) handleStatus() error {
 	fmt.Printf("Created: %s\n\n", cs.Session.CreatedAt.Format("2006-01-02 15:04:05"))
 
 	fmt.Printf("Pending Patches: %d\n", len(cs.Session.PendingPatches))
+	fmt.Printf("Applied Patches: %d\n", len(cs.Session.AppliedPatches))
 	fmt.Printf("Tool Calls: %d\n", len(cs.Session.ToolHistory))
+	if len(cs.Session.Stats.ArgumentErrors) > 0 {
+		fmt.Printf("Invalid Arguments: %s\n", cs.Session.Stats.DescribeArgumentErrors())
+	}
+	if cs.Session.Stats.RejectedPatches > 0 {
+		fmt.Printf("Rejected Patches: %d (did not apply; sent back to the agent)\n", cs.Session.Stats.RejectedPatches)
+	}
 
 	// Show recent tool calls
 	if len(cs.Session.ToolHistory) > 0 {
@@ -148,7 +143,7 @@ func (cs *ChatSession) handleHelp() error {
 	fmt.Println("\n═══════════════════════════════════════")
 	fmt.Println("  Available Commands")
 	fmt.Println("═══════════════════════════════════════")
-	fmt.Println("  review   - Show all pending patches as diffs")
+	fmt.Println("  review   - Accept, reject, skip or edit each pending patch or hunk")
 	fmt.Println("  apply    - Apply pending patches to files")
 	fmt.Println("  status   - Display current session status")
 	fmt.Println("  help     - Show this help message")
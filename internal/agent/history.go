@@ -0,0 +1,87 @@
+package agent
+
+import (
+	"strings"
+
+	"github.com/yourusername/playground/internal/llm"
+	"github.com/yourusername/playground/internal/session"
+)
+
+// conversation builds the messages for a new turn: the system prompt, the
+// session summary, everything said since, then the user's input with any
+// review feedback
+func (a *Agent) conversation(userInput string, config AgentConfig) []llm.Message {
+	messages := []llm.Message{{Role: "system", Content: GetSystemPrompt(config.IsAgentMode, a.registry().Definitions())}}
+
+	// Turns folded into the summary are no longer in the history; the summary stands in for them
+	if a.Session.ContextSummary != "" {
+		messages = append(messages, llm.Message{
+			Role:    "system",
+			Content: "Summary of the session so far:\n" + a.Session.ContextSummary,
+		})
+	}
+
+	// What the user rejected in review since the last turn goes with this one, once
+	if len(a.Session.Feedback) > 0 {
+		userInput = "Since your last reply:\n- " + strings.Join(a.Session.Feedback, "\n- ") + "\n\n" + userInput
+		a.Session.Feedback = nil
+	}
+
+	messages = append(messages, fromSessionMessages(a.Session.History)...)
+	return append(messages, llm.Message{Role: "user", Content: userInput})
+}
+
+// remember stores a turn's messages, minus the system prompts, as the session history
+func (a *Agent) remember(messages []llm.Message) {
+	var turns []llm.Message
+	for _, msg := range messages {
+		if msg.Role != "system" {
+			turns = append(turns, msg)
+		}
+	}
+	a.Session.History = toSessionMessages(turns)
+}
+
+// toSessionMessages converts model messages into their stored form
+func toSessionMessages(messages []llm.Message) []session.Message {
+	out := make([]session.Message, 0, len(messages))
+	for _, msg := range messages {
+		stored := session.Message{
+			Role:       msg.Role,
+			Content:    msg.Content,
+			Name:       msg.Name,
+			ToolCallID: msg.ToolCallID,
+		}
+		for _, tc := range msg.ToolCalls {
+			stored.ToolCalls = append(stored.ToolCalls, session.MessageToolCall{
+				ID:        tc.ID,
+				Name:      tc.Name,
+				Arguments: tc.Arguments,
+			})
+		}
+		out = append(out, stored)
+	}
+	return out
+}
+
+// fromSessionMessages converts stored messages back into model messages
+func fromSessionMessages(messages []session.Message) []llm.Message {
+	out := make([]llm.Message, 0, len(messages))
+	for _, stored := range messages {
+		msg := llm.Message{
+			Role:       stored.Role,
+			Content:    stored.Content,
+			Name:       stored.Name,
+			ToolCallID: stored.ToolCallID,
+		}
+		for _, tc := range stored.ToolCalls {
+			msg.ToolCalls = append(msg.ToolCalls, llm.ToolCall{
+				ID:        tc.ID,
+				Name:      tc.Name,
+				Arguments: tc.Arguments,
+			})
+		}
+		out = append(out, msg)
+	}
+	return out
+}
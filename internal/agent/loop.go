@@ -1,44 +1,53 @@
 package agent
 
 import (
+	"context"
 	"fmt"
 
 	"github.com/yourusername/playground/internal/llm"
 )
 
 // Run executes the agent loop with the given user input
-func (a *Agent) Run(userInput string, config AgentConfig) (string, error) {
-	// Initialize conversation with system prompt
-	messages := []llm.Message{
-		{Role: "system", Content: getSystemPrompt(config.IsAgentMode)},
-		{Role: "user", Content: userInput},
-	}
+// Cancelling ctx stops the current LLM call and ends the loop
+func (a *Agent) Run(ctx context.Context, userInput string, config AgentConfig) (string, error) {
+	// Continue the session's conversation; whatever happens is kept for the next turn
+	messages := a.conversation(userInput, config)
+	defer func() { a.remember(messages) }()
+
+	tools := a.registry().Definitions()
 
-	tools := defineTools()
-	
 	for iteration := 1; iteration <= config.MaxIterations; iteration++ {
 		if config.Verbose {
 			fmt.Printf("\n[Iteration %d/%d]\n", iteration, config.MaxIterations)
 		}
 
 		// Call LLM
-		response, err := a.Provider.Chat(messages, tools)
+		callCtx, cancel := requestContext(ctx, config)
+		response, err := a.Provider.Chat(callCtx, messages, tools, config.Generation)
+		cancel()
 		if err != nil {
 			return "", fmt.Errorf("LLM error: %w", err)
 		}
 
+		if config.Verbose {
+			fmt.Printf("[Tokens: %d prompt, %d completion]\n", response.Usage.PromptTokens, response.Usage.CompletionTokens)
+		}
+
 		// Check if done (stop condition or no tool calls)
 		if response.FinishReason == "stop" || response.FinishReason == "end_turn" || len(response.ToolCalls) == 0 {
 			if config.Verbose {
 				fmt.Println("[Agent finished]")
 			}
+			messages = append(messages, llm.Message{Role: "assistant", Content: response.Content})
 			return response.Content, nil
 		}
 
-		// Add assistant message if present
-		if response.Content != "" {
-			messages = append(messages, llm.Message{Role: "assistant", Content: response.Content})
-		}
+		// Add assistant message with the tool calls it made
+		messages = append(messages, llm.Message{
+			Role:      "assistant",
+			Content:   response.Content,
+			ToolCalls: response.ToolCalls,
+		})
 
 		// Execute tool calls
 		if config.Verbose {
@@ -61,9 +70,10 @@ func (a *Agent) Run(userInput string, config AgentConfig) (string, error) {
 			}
 
 			toolResults = append(toolResults, llm.Message{
-				Role:    "tool",
-				Content: content,
-				Name:    toolCall.Name,
+				Role:       "tool",
+				Content:    content,
+				Name:       toolCall.Name,
+				ToolCallID: toolCall.ID,
 			})
 		}
 
@@ -77,4 +87,4 @@ func (a *Agent) Run(userInput string, config AgentConfig) (string, error) {
 	}
 
 	return "", fmt.Errorf("agent exceeded maximum iterations (%d)", config.MaxIterations)
-}
\ No newline at end of file
+}
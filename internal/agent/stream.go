@@ -1,6 +1,7 @@
 package agent
 
 import (
+	"context"
 	"fmt"
 	"strings"
 	"time"
@@ -9,22 +10,15 @@ import (
 )
 
 // RunStreaming executes the agent loop with streaming responses
-func (a *Agent) RunStreaming(userInput string, config AgentConfig, outputChan chan<- string) error {
+// Cancelling ctx stops generation; outputChan is closed when the turn ends
+func (a *Agent) RunStreaming(ctx context.Context, userInput string, config AgentConfig, outputChan chan<- string) error {
 	defer close(outputChan)
 
-	// Initialize conversation with system prompt
-	messages := []llm.Message{
-		{
-			Role:    "system",
-			Content: getSystemPrompt(config.IsAgentMode),
-		},
-		{
-			Role:    "user",
-			Content: userInput,
-		},
-	}
+	// Continue the session's conversation; whatever happens is kept for the next turn
+	messages := a.conversation(userInput, config)
+	defer func() { a.remember(messages) }()
 
-	tools := defineTools()
+	tools := a.registry().Definitions()
 	iteration := 0
 
 	for {
@@ -37,9 +31,11 @@ func (a *Agent) RunStreaming(userInput string, config AgentConfig, outputChan ch
 		}
 
 		// Get streaming response
-		streamChan, err := a.Provider.ChatStream(messages, tools)
+		callCtx, cancel := requestContext(ctx, config)
+		streamChan, err := a.Provider.ChatStream(callCtx, messages, tools, config.Generation)
 		if err != nil {
-			outputChan <- fmt.Sprintf("\n[Error: %v]", err)
+			cancel()
+			outputChan <- streamErrorMessage(ctx, err)
 			return fmt.Errorf("LLM error: %w", err)
 		}
 
@@ -48,19 +44,29 @@ func (a *Agent) RunStreaming(userInput string, config AgentConfig, outputChan ch
 		var finishReason string
 
 		// Process streaming chunks
+		var streamErr error
+		lineOpen := false // Displayed text not yet ended by a newline
 		for chunk := range streamChan {
 			if chunk.Error != nil {
-				outputChan <- fmt.Sprintf("\n[Error: %v]", chunk.Error)
-				return chunk.Error
+				streamErr = chunk.Error
+				continue // Drain so the provider can finish
 			}
 
 			if chunk.Content != "" {
 				outputChan <- chunk.Content
 				fullContent.WriteString(chunk.Content)
+				lineOpen = !strings.HasSuffix(chunk.Content, "\n")
 			}
 
 			if chunk.ToolCall != nil {
 				toolCalls = append(toolCalls, *chunk.ToolCall)
+
+				// Show what the agent is doing instead of the call's JSON
+				if lineOpen {
+					outputChan <- "\n"
+					lineOpen = false
+				}
+				outputChan <- fmt.Sprintf("🔧 %s…\n", a.registry().Describe(*chunk.ToolCall))
 			}
 
 			if chunk.FinishReason != "" {
@@ -68,28 +74,30 @@ func (a *Agent) RunStreaming(userInput string, config AgentConfig, outputChan ch
 			}
 		}
 
-		// newline after streaming complete
-		if fullContent.Len() > 0 {
-			outputChan <- "\n"
+		cancel()
+
+		if streamErr != nil {
+			outputChan <- streamErrorMessage(ctx, streamErr)
+			return streamErr
 		}
 
-		// Check finish reason
-		if finishReason == "stop" || finishReason == "end_turn" {
-			return nil
+		// newline after streaming complete
+		if lineOpen {
+			outputChan <- "\n"
 		}
 
-		// Check for tool calls
-		if len(toolCalls) == 0 {
+		// Check finish reason and tool calls
+		if finishReason == "stop" || finishReason == "end_turn" || len(toolCalls) == 0 {
+			messages = append(messages, llm.Message{Role: "assistant", Content: fullContent.String()})
 			return nil
 		}
 
-		// Add assistant message to history
-		if fullContent.Len() > 0 {
-			messages = append(messages, llm.Message{
-				Role:    "assistant",
-				Content: fullContent.String(),
-			})
-		}
+		// Add assistant message with the tool calls it made
+		messages = append(messages, llm.Message{
+			Role:      "assistant",
+			Content:   fullContent.String(),
+			ToolCalls: toolCalls,
+		})
 
 		// Execute tool calls
 		for _, toolCall := range toolCalls {
@@ -104,9 +112,10 @@ func (a *Agent) RunStreaming(userInput string, config AgentConfig, outputChan ch
 			}
 
 			messages = append(messages, llm.Message{
-				Role:    "tool",
-				Content: toolResultContent,
-				Name:    toolCall.Name,
+				Role:       "tool",
+				Content:    toolResultContent,
+				Name:       toolCall.Name,
+				ToolCallID: toolCall.ID,
 			})
 		}
 
@@ -119,3 +128,11 @@ func (a *Agent) RunStreaming(userInput string, config AgentConfig, outputChan ch
 		time.Sleep(100 * time.Millisecond)
 	}
 }
+
+// streamErrorMessage formats a stream failure for display
+func streamErrorMessage(ctx context.Context, err error) string {
+	if ctx.Err() == context.Canceled {
+		return "\n[Interrupted]\n"
+	}
+	return fmt.Sprintf("\n[Error: %v]", err)
+}
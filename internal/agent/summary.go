@@ -0,0 +1,103 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/yourusername/playground/internal/llm"
+	"github.com/yourusername/playground/internal/session"
+)
+
+const (
+	summaryMaxTokens    = 400 // Reply limit for a summary
+	summaryExcerptBytes = 600 // Each message is cut to this size in the summarizer's prompt
+)
+
+// Summarize folds all but the latest exchange of the session history into
+// Session.ContextSummary once the history grows past config.HistoryTokens.
+// It reports whether the history was compressed; on error the history is left as it was.
+func (a *Agent) Summarize(ctx context.Context, config AgentConfig) (bool, error) {
+	if config.HistoryTokens <= 0 {
+		return false, nil
+	}
+
+	history := a.Session.History
+	if llm.CountTokens(ctx, a.Provider, renderHistory(history, 0)) <= config.HistoryTokens {
+		return false, nil
+	}
+
+	// The latest exchange stays verbatim; everything before it is summarized
+	cut := -1
+	for i, msg := range history {
+		if msg.Role == "user" {
+			cut = i
+		}
+	}
+	if cut <= 0 {
+		return false, nil
+	}
+
+	var request strings.Builder
+	fmt.Fprintf(&request, "Session goal: %s\n\n", a.Session.Goal)
+	if a.Session.ContextSummary != "" {
+		fmt.Fprintf(&request, "Earlier summary:\n%s\n\n", a.Session.ContextSummary)
+	}
+	fmt.Fprintf(&request, "New conversation:\n%s", renderHistory(history[:cut], summaryExcerptBytes))
+
+	opts := config.Generation
+	if opts.MaxTokens == nil || *opts.MaxTokens > summaryMaxTokens {
+		maxTokens := summaryMaxTokens
+		opts.MaxTokens = &maxTokens
+	}
+
+	callCtx, cancel := requestContext(ctx, config)
+	defer cancel()
+
+	response, err := a.Provider.Chat(callCtx, []llm.Message{
+		{Role: "system", Content: summarySystemPrompt},
+		{Role: "user", Content: request.String()},
+	}, nil, opts)
+	if err != nil {
+		return false, fmt.Errorf("failed to summarize history: %w", err)
+	}
+
+	summary := strings.TrimSpace(response.Content)
+	if summary == "" {
+		return false, fmt.Errorf("failed to summarize history: model returned an empty summary")
+	}
+
+	a.Session.ContextSummary = summary
+	a.Session.History = append([]session.Message(nil), history[cut:]...)
+	return true, nil
+}
+
+// renderHistory writes the conversation as plain text, cutting each message to limit bytes (0 = no limit)
+func renderHistory(history []session.Message, limit int) string {
+	clip := func(text string) string {
+		if limit > 0 && len(text) > limit {
+			return text[:limit] + "..."
+		}
+		return text
+	}
+
+	var out strings.Builder
+	for _, msg := range history {
+		switch msg.Role {
+		case "user":
+			fmt.Fprintf(&out, "User: %s\n", clip(msg.Content))
+		case "assistant":
+			if msg.Content != "" {
+				fmt.Fprintf(&out, "Assistant: %s\n", clip(msg.Content))
+			}
+			for _, tc := range msg.ToolCalls {
+				args, _ := json.Marshal(tc.Arguments)
+				fmt.Fprintf(&out, "Assistant called %s %s\n", tc.Name, clip(string(args)))
+			}
+		case "tool":
+			fmt.Fprintf(&out, "Tool result (%s): %s\n", msg.Name, clip(msg.Content))
+		}
+	}
+	return out.String()
+}
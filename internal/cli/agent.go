@@ -8,7 +8,7 @@ import (
 
 	"github.com/spf13/cobra"
 	"github.com/yourusername/playground/internal/agent"
-	"github.com/yourusername/playground/internal/llm"
+	"github.com/yourusername/playground/internal/model"
 	"github.com/yourusername/playground/internal/session"
 	"github.com/yourusername/playground/internal/workspace"
 )
@@ -28,6 +28,7 @@ own snapshot system for versioning.
 Example:
   pg agent                    # Start new agent session
   pg agent --resume pg-5      # Resume previous session
+  pg agent --seed 42 --temperature 0.2   # Reproducible sampling
 
 In agent mode, you can:
   • Chat naturally with the AI
@@ -105,6 +106,7 @@ In agent mode, you can:
 				ContextSummary: "",
 				PendingPatches: []session.Patch{},
 				ToolHistory:    []session.ToolCall{},
+				History:        []session.Message{},
 				CreatedAt:      time.Now(),
 			}
 
@@ -117,22 +119,32 @@ In agent mode, you can:
 
 		// Load config to get model path
 		config, err := LoadConfig()
-		if err != nil || config == nil || config.ModelPath == "" {
-			return fmt.Errorf("no model configured. Run: pg setup")
+		if err != nil {
+			return fmt.Errorf("failed to load config: %w", err)
 		}
 
-		// Create local LLM provider
-		provider, err := llm.NewLocalProvider(config.ModelPath)
+		// Create the configured LLM provider
+		provider, err := newProvider(config)
 		if err != nil {
 			return fmt.Errorf("failed to load local model: %w", err)
 		}
+		defer closeProvider(provider)
+		enablePromptCache(provider, workspaceRoot, sess.ID)
 
 		fmt.Println("╔════════════════════════════════════════════════════════════╗")
 		fmt.Println("║           PlayGround Agent - Interactive Mode              ║")
 		fmt.Println("╚════════════════════════════════════════════════════════════╝")
 		fmt.Println()
+		// Sampling: config (global, per model, chat role), then command-line flags
+		generation := config.generationOptions(model.RoleChat, config.chatModelKey()).Merge(generationFlags(cmd))
+
 		fmt.Printf("🤖 Model: %s\n", provider.Name())
-		fmt.Printf("📁 Path: %s\n", config.ModelPath)
+		if config != nil && config.ModelPath != "" {
+			fmt.Printf("📁 Path: %s\n", config.ModelPath)
+		}
+		if generation.Seed != nil {
+			fmt.Printf("🎲 Seed: %d\n", *generation.Seed)
+		}
 		fmt.Println()
 
 		// Create agent with agent mode prompt
@@ -148,10 +160,13 @@ In agent mode, you can:
 
 		// Create and run chat session
 		chatSession := agent.NewChatSession(agentInstance, store)
+		chatSession.Config.RequestTimeout = config.requestTimeout()
+		chatSession.Config.Generation = generation
 		return chatSession.Run()
 	},
 }
 
 func init() {
 	agentCmd.Flags().String("resume", "", "Resume a previous agent session by ID")
+	addGenerationFlags(agentCmd)
 }
@@ -4,10 +4,12 @@ import (
 	"bufio"
 	"fmt"
 	"os"
+	"path/filepath"
 	"strings"
 
 	"github.com/spf13/cobra"
 	"github.com/yourusername/playground/internal/patch"
+	"github.com/yourusername/playground/internal/review"
 	"github.com/yourusername/playground/internal/session"
 )
 
@@ -15,11 +17,19 @@ var applyCmd = &cobra.Command{
 	Use:   "apply",
 	Short: "Apply pending patches to the repository",
 	Long: `Apply all validated pending patches to the repository files.
-Requires user confirmation before applying changes.
-All patches are validated before application to ensure safety.
+Requires user confirmation before applying changes, unless --yes is given.
+All patches are validated before application to ensure safety, and nothing
+is written unless every one applies.
+Hunks whose line numbers have drifted are found nearby, and up to --fuzz
+context lines at each end of a hunk may differ from the file.
+--only and --file pick which patches, or which files' changes, to apply;
+the rest stay pending.
 
 Example:
-  pg apply`,
+  pg apply
+  pg apply --fuzz 0
+  pg apply --only 2,4
+  pg apply --file internal/server/handler.go`,
 	RunE: func(cmd *cobra.Command, args []string) error {
 		cwd, err := os.Getwd()
 		if err != nil {
@@ -60,51 +70,81 @@ Example:
 			return nil
 		}
 
-		// Request user confirmation
-		fmt.Printf("About to apply %d patch(es) to the repository.\n", len(sess.PendingPatches))
-		fmt.Printf("Apply all patches? [y/N]: ")
-
-		reader := bufio.NewReader(os.Stdin)
-		response, err := reader.ReadString('\n')
-		if err != nil {
-			return fmt.Errorf("failed to read user input: %w", err)
+		// Narrow the patches to those asked for, splitting any that also change other files
+		indexes := review.All(sess)
+		if only, _ := cmd.Flags().GetString("only"); only != "" {
+			if indexes, err = review.ParseNumbers(only, len(sess.PendingPatches)); err != nil {
+				return err
+			}
 		}
-
-		response = strings.TrimSpace(strings.ToLower(response))
-		if response != "y" && response != "yes" {
-			fmt.Println("Patch application cancelled")
-			return nil
+		if files, _ := cmd.Flags().GetStringSlice("file"); len(files) > 0 {
+			for i, f := range files {
+				if files[i], err = repoPath(repoRoot, cwd, f); err != nil {
+					return err
+				}
+			}
+			if indexes, err = review.SelectFiles(sess, indexes, files); err != nil {
+				return err
+			}
 		}
 
-		// Apply patches
-		applied := 0
-		for i, p := range sess.PendingPatches {
-			fmt.Printf("Applying patch %d/%d: %s... ", i+1, len(sess.PendingPatches), p.FilePath)
-
-			// Convert session.Patch to patch.Patch
-			patchToApply := patch.Patch{
-				FilePath:    p.FilePath,
-				UnifiedDiff: p.UnifiedDiff,
+		// Request user confirmation
+		if yes, _ := cmd.Flags().GetBool("yes"); !yes {
+			fmt.Printf("About to apply %d patch(es) to the repository.\n", len(indexes))
+			fmt.Printf("Apply these patches? [y/N]: ")
+
+			reader := bufio.NewReader(os.Stdin)
+			response, err := reader.ReadString('\n')
+			if err != nil {
+				return fmt.Errorf("failed to read user input: %w", err)
 			}
 
-			if err := patch.Apply(repoRoot, patchToApply); err != nil {
-				fmt.Printf("❌ FAILED\n")
-				fmt.Printf("Error: %v\n", err)
-				fmt.Printf("\nApplied %d/%d patches before failure.\n", applied, len(sess.PendingPatches))
-				return fmt.Errorf("patch application failed")
+			response = strings.TrimSpace(strings.ToLower(response))
+			if response != "y" && response != "yes" {
+				fmt.Println("Patch application cancelled")
+				return nil
 			}
-
-			fmt.Printf("✓\n")
-			applied++
 		}
 
-		// Clear pending patches from session
-		sess.PendingPatches = []session.Patch{}
+		opts := patch.DefaultOptions
+		opts.Fuzz, _ = cmd.Flags().GetInt("fuzz")
+
+		// Nothing is written unless every selected patch applies
+		if err := review.Apply(repoRoot, sess, indexes, opts); err != nil {
+			return err
+		}
 		if err := store.Save(sess); err != nil {
 			return fmt.Errorf("failed to save session: %w", err)
 		}
 
-		fmt.Printf("\n✓ Successfully applied %d patch(es)\n", applied)
+		fmt.Printf("\n✓ Successfully applied %d patch(es)\n", len(indexes))
+		if len(sess.PendingPatches) > 0 {
+			fmt.Printf("%d patch(es) still pending.\n", len(sess.PendingPatches))
+		}
 		return nil
 	},
 }
+
+func init() {
+	applyCmd.Flags().Int("fuzz", patch.DefaultOptions.Fuzz, "Context lines at each end of a hunk that may differ from the file")
+	applyCmd.Flags().String("only", "", "Apply only these patches, by the numbers review shows, e.g. 2,4")
+	applyCmd.Flags().StringSlice("file", nil, "Apply only the changes to this file (repeatable)")
+	applyCmd.Flags().BoolP("yes", "y", false, "Apply without asking for confirmation")
+}
+
+// repoPath turns a path given on the command line, relative to dir, into the
+// slash-separated path from the repository root that diffs use
+func repoPath(repoRoot, dir, path string) (string, error) {
+	if !filepath.IsAbs(path) {
+		// git reports the root with symlinks resolved, so the directory must be too
+		if resolved, err := filepath.EvalSymlinks(dir); err == nil {
+			dir = resolved
+		}
+		path = filepath.Join(dir, path)
+	}
+	rel, err := filepath.Rel(repoRoot, path)
+	if err != nil || !filepath.IsLocal(rel) {
+		return "", fmt.Errorf("%s is outside the repository", path)
+	}
+	return filepath.ToSlash(rel), nil
+}
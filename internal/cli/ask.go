@@ -3,10 +3,11 @@ package cli
 import (
 	"fmt"
 	"os"
+	"os/signal"
 
 	"github.com/spf13/cobra"
 	"github.com/yourusername/playground/internal/agent"
-	"github.com/yourusername/playground/internal/llm"
+	"github.com/yourusername/playground/internal/model"
 	"github.com/yourusername/playground/internal/session"
 )
 
@@ -58,17 +59,19 @@ Example:
 
 		// Load config to get model path
 		config, err := LoadConfig()
-		if err != nil || config == nil || config.ModelPath == "" {
-			return fmt.Errorf("no model configured. Run: pg setup")
+		if err != nil {
+			return fmt.Errorf("failed to load config: %w", err)
 		}
 
-		// Create local LLM provider
-		provider, err := llm.NewLocalProvider(config.ModelPath)
+		// Create the configured LLM provider
+		provider, err := newProvider(config)
 		if err != nil {
 			return fmt.Errorf("failed to load local model: %w", err)
 		}
+		defer closeProvider(provider)
 
 		fmt.Printf("Using: %s\n", provider.Name())
+		enablePromptCache(provider, repoRoot, sess.ID)
 
 		// Create agent
 		agentInstance := &agent.Agent{
@@ -81,14 +84,28 @@ Example:
 		// Run agent
 		fmt.Printf("🤖 Agent working...\n\n")
 
-		response, err := agentInstance.Run(question, agent.DefaultConfig)
+		// Ctrl-C stops generation and still saves the session below
+		ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt)
+		defer stop()
+
+		agentConfig := agent.DefaultConfig
+		agentConfig.RequestTimeout = config.requestTimeout()
+		agentConfig.Generation = config.generationOptions(model.RoleChat, config.chatModelKey())
+
+		response, err := agentInstance.Run(ctx, question, agentConfig)
 		if err != nil {
+			store.Save(sess)
 			return fmt.Errorf("agent error: %w", err)
 		}
 
 		// Display response
 		fmt.Printf("Agent: %s\n", response)
 
+		// Keep the next question's prompt small by summarizing older turns
+		if _, err := agentInstance.Summarize(ctx, agentConfig); err != nil {
+			fmt.Printf("Warning: %v\n", err)
+		}
+
 		// Save final session state
 		if err := store.Save(sess); err != nil {
 			return fmt.Errorf("failed to save session: %w", err)
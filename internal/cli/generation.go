@@ -0,0 +1,69 @@
+package cli
+
+import (
+	"github.com/spf13/cobra"
+	"github.com/yourusername/playground/internal/llm"
+)
+
+// addGenerationFlags registers the sampling overrides shared by model commands
+func addGenerationFlags(cmd *cobra.Command) {
+	cmd.Flags().Float64("temperature", 0, "Sampling temperature")
+	cmd.Flags().Int("top-k", 0, "Top-k sampling")
+	cmd.Flags().Float64("top-p", 0, "Top-p (nucleus) sampling")
+	cmd.Flags().Float64("min-p", 0, "Min-p sampling")
+	cmd.Flags().Float64("repeat-penalty", 0, "Penalty for repeated tokens")
+	cmd.Flags().Int("max-tokens", 0, "Maximum tokens to generate per reply")
+	cmd.Flags().Int("threads", 0, "CPU threads for llama.cpp")
+	cmd.Flags().Int("seed", 0, "Fixed sampling seed, to reproduce a generation")
+	cmd.Flags().StringSlice("stop", nil, "Extra stop sequence (repeatable)")
+	cmd.Flags().Bool("stop-after-tool-call", false, "End each streamed reply at its first tool call")
+}
+
+// generationFlags returns the options given on the command line.
+// Only flags the user actually set are returned, so they layer over config.
+func generationFlags(cmd *cobra.Command) llm.GenerationOptions {
+	var opts llm.GenerationOptions
+	flags := cmd.Flags()
+
+	if flags.Changed("temperature") {
+		v, _ := flags.GetFloat64("temperature")
+		opts.Temperature = &v
+	}
+	if flags.Changed("top-k") {
+		v, _ := flags.GetInt("top-k")
+		opts.TopK = &v
+	}
+	if flags.Changed("top-p") {
+		v, _ := flags.GetFloat64("top-p")
+		opts.TopP = &v
+	}
+	if flags.Changed("min-p") {
+		v, _ := flags.GetFloat64("min-p")
+		opts.MinP = &v
+	}
+	if flags.Changed("repeat-penalty") {
+		v, _ := flags.GetFloat64("repeat-penalty")
+		opts.RepeatPenalty = &v
+	}
+	if flags.Changed("max-tokens") {
+		v, _ := flags.GetInt("max-tokens")
+		opts.MaxTokens = &v
+	}
+	if flags.Changed("threads") {
+		v, _ := flags.GetInt("threads")
+		opts.Threads = &v
+	}
+	if flags.Changed("seed") {
+		v, _ := flags.GetInt("seed")
+		opts.Seed = &v
+	}
+	if flags.Changed("stop") {
+		opts.Stop, _ = flags.GetStringSlice("stop")
+	}
+	if flags.Changed("stop-after-tool-call") {
+		v, _ := flags.GetBool("stop-after-tool-call")
+		opts.StopAfterToolCall = &v
+	}
+
+	return opts
+}
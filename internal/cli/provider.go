@@ -0,0 +1,110 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/yourusername/playground/internal/llm"
+	"github.com/yourusername/playground/internal/model"
+)
+
+// Backend names accepted in the "backend" config field
+const (
+	backendLlamaCLI    = "llama-cli"
+	backendLlamaServer = "llama-server"
+	backendOpenAI      = "openai"
+)
+
+// Environment variables that record a session to a cassette file, or replay one
+// instead of running a model (see llm.RecordingProvider and llm.ReplayProvider)
+const (
+	recordEnv = "PG_RECORD"
+	replayEnv = "PG_REPLAY"
+)
+
+// newProvider creates the LLM provider selected in the config,
+// wrapped for recording or replaced by a replay when requested
+func newProvider(config *Config) (llm.Provider, error) {
+	if path := os.Getenv(replayEnv); path != "" {
+		return llm.NewReplayProvider(path)
+	}
+
+	provider, err := newBackendProvider(config)
+	if err != nil {
+		return nil, err
+	}
+
+	if path := os.Getenv(recordEnv); path != "" {
+		return llm.NewRecordingProvider(provider, path), nil
+	}
+	return provider, nil
+}
+
+// newBackendProvider creates the provider for the configured backend
+func newBackendProvider(config *Config) (llm.Provider, error) {
+	if config == nil {
+		return nil, fmt.Errorf("no model configured. Run: pg setup")
+	}
+
+	// Only the chat role runs from the command line; other roles would be ignored
+	for role := range config.Roles {
+		if role != string(model.RoleChat) {
+			return nil, fmt.Errorf("unsupported role %q in config: only \"chat\" can be configured", role)
+		}
+	}
+
+	switch config.Backend {
+	case "", backendLlamaCLI:
+		if config.ModelPath == "" {
+			return nil, fmt.Errorf("no model configured. Run: pg setup")
+		}
+		return llm.NewLocalProvider(config.ModelPath)
+
+	case backendLlamaServer:
+		if config.ModelPath == "" && config.ServerURL == "" {
+			return nil, fmt.Errorf("no model configured. Run: pg setup")
+		}
+		// Threads are fixed when llama-server starts
+		var threads int
+		if opts := config.generationOptions(model.RoleChat, config.chatModelKey()); opts.Threads != nil {
+			threads = *opts.Threads
+		}
+		return llm.NewServerProvider(llm.ServerConfig{
+			ModelPath: config.ModelPath,
+			URL:       config.ServerURL,
+			Threads:   threads,
+		})
+
+	case backendOpenAI:
+		return llm.NewOpenAIProvider(llm.OpenAIConfig{
+			BaseURL: config.BaseURL,
+			Model:   config.ModelName,
+			APIKey:  config.APIKey,
+		})
+
+	default:
+		return nil, fmt.Errorf("unknown backend %q in %s (expected %q, %q or %q)",
+			config.Backend, GetConfigPath(), backendLlamaCLI, backendLlamaServer, backendOpenAI)
+	}
+}
+
+// enablePromptCache lets providers that support it keep the session's evaluated
+// prompt under .pg/cache, so each agent step only processes what is new
+func enablePromptCache(provider llm.Provider, repoRoot, sessionID string) {
+	cacher, ok := provider.(llm.PromptCacher)
+	if !ok {
+		return
+	}
+	if err := cacher.SetPromptCache(filepath.Join(repoRoot, ".pg", "cache"), sessionID); err != nil {
+		fmt.Printf("Warning: prompt cache disabled: %v\n", err)
+	}
+}
+
+// closeProvider releases any resources held by the provider (e.g. a spawned llama-server)
+func closeProvider(provider llm.Provider) {
+	if closer, ok := provider.(io.Closer); ok {
+		closer.Close()
+	}
+}
@@ -0,0 +1,80 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/yourusername/playground/internal/review"
+	"github.com/yourusername/playground/internal/session"
+)
+
+var rejectCmd = &cobra.Command{
+	Use:   "reject <patch>...",
+	Short: "Reject pending patches",
+	Long: `Drop pending patches, by the numbers review shows, without applying them.
+The agent is told on its next turn which patches were rejected, and why when
+--reason is given.
+
+Example:
+  pg reject 3
+  pg reject 2,4 --reason "keep the public API unchanged"`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cwd, err := os.Getwd()
+		if err != nil {
+			return fmt.Errorf("failed to get current directory: %w", err)
+		}
+
+		// Find Git repository root
+		repoRoot, err := getGitRoot(cwd)
+		if err != nil {
+			return fmt.Errorf("not in a git repository: %w", err)
+		}
+
+		// Create session store
+		store, err := session.NewStore(repoRoot)
+		if err != nil {
+			return fmt.Errorf("failed to create session store: %w", err)
+		}
+
+		// Get active session
+		sessionID, err := store.GetActiveSessionID()
+		if err != nil {
+			return fmt.Errorf("failed to get active session: %w", err)
+		}
+
+		if sessionID == "" {
+			return fmt.Errorf("no active session")
+		}
+
+		// Load session
+		sess, err := store.Load(sessionID)
+		if err != nil {
+			return fmt.Errorf("failed to load session: %w", err)
+		}
+
+		indexes, err := review.ParseNumbers(strings.Join(args, ","), len(sess.PendingPatches))
+		if err != nil {
+			return err
+		}
+		for _, i := range indexes {
+			p := sess.PendingPatches[i]
+			fmt.Printf("Rejected patch %d: %s\n", i+1, strings.Join(p.Paths(), ", "))
+		}
+
+		reason, _ := cmd.Flags().GetString("reason")
+		review.Reject(sess, indexes, reason)
+		if err := store.Save(sess); err != nil {
+			return fmt.Errorf("failed to save session: %w", err)
+		}
+
+		fmt.Printf("%d patch(es) still pending.\n", len(sess.PendingPatches))
+		return nil
+	},
+}
+
+func init() {
+	rejectCmd.Flags().StringP("reason", "m", "", "Why the patches were rejected, for the agent")
+}
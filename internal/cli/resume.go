@@ -57,6 +57,7 @@ Example:
 		fmt.Printf("✓ Resumed session: %s\n", sessionID)
 		fmt.Printf("  Goal: %s\n", sess.Goal)
 		fmt.Printf("  Pending patches: %d\n", len(sess.PendingPatches))
+		fmt.Printf("  Conversation: %d messages\n", len(sess.History))
 
 		return nil
 	},
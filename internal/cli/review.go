@@ -1,21 +1,28 @@
 package cli
 
 import (
+	"bufio"
 	"fmt"
 	"os"
 
 	"github.com/spf13/cobra"
+	"github.com/yourusername/playground/internal/patch"
+	"github.com/yourusername/playground/internal/review"
 	"github.com/yourusername/playground/internal/session"
 )
 
 var reviewCmd = &cobra.Command{
 	Use:   "review",
 	Short: "Review pending patches in the current session",
-	Long: `Display all pending patches that have been proposed by the agent.
-Shows the unified diff for each patch so you can review changes before applying.
+	Long: `Walk through the pending patches that have been proposed by the agent.
+For each patch, or each hunk of it, choose to accept, reject, skip, or edit it
+in $EDITOR. A rejection can carry a reason, which the agent sees on its next
+turn. Accepted patches are applied together when the review ends; skipped ones
+stay pending.
 
 Example:
-  pg review`,
+  pg review
+  pg review --list`,
 	RunE: func(cmd *cobra.Command, args []string) error {
 		cwd, err := os.Getwd()
 		if err != nil {
@@ -59,14 +66,36 @@ Example:
 		fmt.Printf("Session: %s\n", sess.ID)
 		fmt.Printf("Pending patches: %d\n\n", len(sess.PendingPatches))
 
-		for i, patch := range sess.PendingPatches {
-			fmt.Printf("═══ Patch %d/%d ═══\n", i+1, len(sess.PendingPatches))
-			fmt.Printf("File: %s\n", patch.FilePath)
-			fmt.Printf("Created: %s\n\n", patch.CreatedAt.Format("2006-01-02 15:04:05"))
-			fmt.Println(patch.UnifiedDiff)
-			fmt.Println()
+		if list, _ := cmd.Flags().GetBool("list"); list {
+			for i, p := range sess.PendingPatches {
+				review.PrintPatch(p, i+1, len(sess.PendingPatches))
+			}
+			return nil
+		}
+
+		reader := bufio.NewReader(os.Stdin)
+		reviewer := &review.Reviewer{Session: sess, ReadLine: func() (string, bool) {
+			line, err := reader.ReadString('\n')
+			return line, err == nil || line != ""
+		}}
+		accepted := reviewer.Run()
+
+		if len(accepted) > 0 {
+			fmt.Printf("\nApplying %d accepted patch(es)...\n", len(accepted))
+			if err = review.Apply(repoRoot, sess, accepted, patch.DefaultOptions); err == nil {
+				fmt.Printf("\n✓ Successfully applied %d patch(es)\n", len(accepted))
+			}
 		}
+		fmt.Printf("%d patch(es) still pending.\n", len(sess.PendingPatches))
 
-		return nil
+		// Decisions are kept even when applying fails
+		if serr := store.Save(sess); serr != nil {
+			return fmt.Errorf("failed to save session: %w", serr)
+		}
+		return err
 	},
 }
+
+func init() {
+	reviewCmd.Flags().Bool("list", false, "Print the pending patches without reviewing them")
+}
@@ -30,5 +30,6 @@ func init() {
 	rootCmd.AddCommand(askCmd)
 	rootCmd.AddCommand(reviewCmd)
 	rootCmd.AddCommand(applyCmd)
+	rootCmd.AddCommand(rejectCmd)
 	rootCmd.AddCommand(resumeCmd)
 }
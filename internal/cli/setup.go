@@ -7,14 +7,31 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/spf13/cobra"
+	"github.com/yourusername/playground/internal/llm"
 	"github.com/yourusername/playground/internal/model"
 )
 
 // Config represents the PlayGround configuration
 type Config struct {
 	ModelPath string `json:"model_path,omitempty"` // Path to local GGUF model
+	Backend   string `json:"backend,omitempty"`    // "llama-cli" (default), "llama-server" or "openai"
+	ServerURL string `json:"server_url,omitempty"` // llama-server address to attach to or start on
+	BaseURL   string `json:"base_url,omitempty"`   // OpenAI-compatible endpoint (Ollama, LM Studio, vLLM)
+	ModelName string `json:"model_name,omitempty"` // Model name served by the OpenAI-compatible endpoint
+	APIKey    string `json:"api_key,omitempty"`    // Optional key for the OpenAI-compatible endpoint
+
+	// RequestTimeout limits a single model call in seconds; 0 means no limit.
+	// Ctrl-C always stops the current generation.
+	RequestTimeout int `json:"request_timeout_seconds,omitempty"`
+
+	// Generation sets sampling options for every model. Models overrides them per
+	// GGUF file name (or served model name), and Roles["chat"] for pg agent and pg ask.
+	Generation *llm.GenerationOptions           `json:"generation,omitempty"`
+	Models     map[string]llm.GenerationOptions `json:"models,omitempty"`
+	Roles      map[string]llm.GenerationOptions `json:"roles,omitempty"`
 }
 
 var setupCmd = &cobra.Command{
@@ -27,9 +44,20 @@ This wizard will help you:
   2. Validate the model file exists
   3. Check system requirements (RAM)
 
+To use a model already served by Ollama, LM Studio or vLLM instead of a
+GGUF file, pass the endpoint and model name:
+
 Example:
-  pg setup`,
+  pg setup
+  pg setup --base-url http://localhost:11434/v1 --model-name qwen2.5-coder:7b`,
 	RunE: func(cmd *cobra.Command, args []string) error {
+		baseURL, _ := cmd.Flags().GetString("base-url")
+		if baseURL != "" {
+			modelName, _ := cmd.Flags().GetString("model-name")
+			apiKey, _ := cmd.Flags().GetString("api-key")
+			return setupEndpoint(baseURL, modelName, apiKey)
+		}
+
 		reader := bufio.NewReader(os.Stdin)
 
 		fmt.Println("╔════════════════════════════════════════╗")
@@ -169,6 +197,9 @@ Example:
 
 		// Save configuration
 		config.ModelPath = modelPath
+		if config.Backend == backendOpenAI {
+			config.Backend = "" // A GGUF path means a llama.cpp backend again
+		}
 		if err := SaveConfig(config); err != nil {
 			return fmt.Errorf("failed to save configuration: %w", err)
 		}
@@ -185,6 +216,86 @@ Example:
 	},
 }
 
+func init() {
+	setupCmd.Flags().String("base-url", "", "OpenAI-compatible endpoint to use instead of a GGUF model")
+	setupCmd.Flags().String("model-name", "", "Model name served by the endpoint (with --base-url)")
+	setupCmd.Flags().String("api-key", "", "Optional API key for the endpoint (with --base-url)")
+}
+
+// setupEndpoint configures an OpenAI-compatible endpoint and checks that it answers
+func setupEndpoint(baseURL, modelName, apiKey string) error {
+	if modelName == "" {
+		return fmt.Errorf("--model-name is required with --base-url")
+	}
+
+	config, _ := LoadConfig()
+	if config == nil {
+		config = &Config{}
+	}
+
+	config.Backend = backendOpenAI
+	config.BaseURL = baseURL
+	config.ModelName = modelName
+	config.APIKey = apiKey
+
+	provider, err := newProvider(config)
+	if err != nil {
+		return fmt.Errorf("invalid endpoint configuration: %w", err)
+	}
+
+	if err := SaveConfig(config); err != nil {
+		return fmt.Errorf("failed to save configuration: %w", err)
+	}
+
+	fmt.Println("✅ Configuration saved successfully!")
+	fmt.Printf("🤖 Model: %s\n", provider.Name())
+	fmt.Printf("📁 Config location: %s\n", GetConfigPath())
+	fmt.Println()
+	fmt.Println("🚀 You're ready to go! Try:")
+	fmt.Println("   pg agent")
+
+	return nil
+}
+
+// requestTimeout returns the configured per-call model timeout
+func (c *Config) requestTimeout() time.Duration {
+	if c == nil || c.RequestTimeout <= 0 {
+		return 0
+	}
+	return time.Duration(c.RequestTimeout) * time.Second
+}
+
+// generationOptions resolves sampling options for a model in a role:
+// global settings, then the model's overrides, then the role's
+func (c *Config) generationOptions(role model.ModelRole, modelKey string) llm.GenerationOptions {
+	var opts llm.GenerationOptions
+	if c == nil {
+		return opts
+	}
+
+	if c.Generation != nil {
+		opts = opts.Merge(*c.Generation)
+	}
+	if modelOpts, ok := c.Models[modelKey]; ok {
+		opts = opts.Merge(modelOpts)
+	}
+	if roleOpts, ok := c.Roles[string(role)]; ok {
+		opts = opts.Merge(roleOpts)
+	}
+	return opts
+}
+
+// chatModelKey names the configured chat model for per-model overrides
+func (c *Config) chatModelKey() string {
+	if c == nil {
+		return ""
+	}
+	if c.Backend == backendOpenAI {
+		return c.ModelName
+	}
+	return filepath.Base(c.ModelPath)
+}
+
 // LoadConfig loads the configuration from disk
 func LoadConfig() (*Config, error) {
 	configPath := GetConfigPath()
@@ -58,6 +58,7 @@ Example:
 			ContextSummary: "",
 			PendingPatches: []session.Patch{},
 			ToolHistory:    []session.ToolCall{},
+			History:        []session.Message{},
 			CreatedAt:      time.Now(),
 		}
 
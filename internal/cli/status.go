@@ -61,7 +61,14 @@ Example:
 		fmt.Printf("Repository: %s\n", sess.Repo)
 		fmt.Printf("Created: %s\n", sess.CreatedAt.Format("2006-01-02 15:04:05"))
 		fmt.Printf("\nPending Patches: %d\n", len(sess.PendingPatches))
+		fmt.Printf("Applied Patches: %d\n", len(sess.AppliedPatches))
 		fmt.Printf("Tool History: %d calls\n", len(sess.ToolHistory))
+		if len(sess.Stats.ArgumentErrors) > 0 {
+			fmt.Printf("Invalid Arguments: %s\n", sess.Stats.DescribeArgumentErrors())
+		}
+		if sess.Stats.RejectedPatches > 0 {
+			fmt.Printf("Rejected Patches: %d (did not apply; sent back to the agent)\n", sess.Stats.RejectedPatches)
+		}
 
 		// Show recent tool history (last 5)
 		if len(sess.ToolHistory) > 0 {
@@ -0,0 +1,235 @@
+package llm
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strings"
+)
+
+// defaultReplyReserve is how many tokens of the context window are kept free for the reply
+const defaultReplyReserve = 1024
+
+// replyReserve returns the tokens to keep free for a reply with the given options
+func replyReserve(opts GenerationOptions) int {
+	if opts.MaxTokens != nil && *opts.MaxTokens < defaultReplyReserve {
+		return *opts.MaxTokens
+	}
+	return defaultReplyReserve
+}
+
+// toolExcerptBytes is the size a shortened tool result is cut down to
+const toolExcerptBytes = 1200
+
+// omittedToolOutput replaces tool results that had to be dropped entirely
+const omittedToolOutput = "[output omitted to fit the context window]"
+
+// Tokenizer counts tokens the way a model would
+type Tokenizer interface {
+	CountTokens(ctx context.Context, text string) (int, error)
+}
+
+// Usage reports token counts for one LLM call
+type Usage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+}
+
+// Budget fits a conversation into a model's context window.
+//
+// The whole prompt (system prompt, tool schemas, history and tool results) is
+// measured before each call. When it is too large, the following steps are
+// applied in order until it fits:
+//
+//  1. Shorten older tool results, oldest first, to a head/tail excerpt.
+//  2. Replace older tool results, oldest first, with a one-line placeholder.
+//  3. Drop the oldest exchanges: a user turn plus the replies and tool results after it.
+//  4. Shorten, then replace, the newest tool result.
+//
+// System messages and the latest user message are never dropped. If the
+// prompt still does not fit, Fit returns an error rather than letting the
+// backend truncate it silently.
+type Budget struct {
+	ContextSize int // Model context window in tokens
+	Reserve     int // Tokens kept free for the reply
+	Tokenizer   Tokenizer
+}
+
+// Fit trims messages until render(messages) fits the budget.
+// It returns the rendered prompt and its token count. The tokenizer may be
+// slow, so the prompt is measured once; a trimmed prompt is sized from the
+// bytes-per-token ratio of that measurement.
+func (b Budget) Fit(ctx context.Context, messages []Message, render func([]Message) string) (string, int, error) {
+	limit := b.ContextSize - b.Reserve
+	msgs := append([]Message(nil), messages...)
+
+	prompt := render(msgs)
+	tokens, err := b.Tokenizer.CountTokens(ctx, prompt)
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to count prompt tokens: %w", err)
+	}
+	perByte := float64(tokens) / float64(max(len(prompt), 1))
+
+	for tokens > limit {
+		// Size the cut in bytes from the measured ratio
+		excess := int(float64(tokens-limit)/perByte) + 1
+
+		var ok bool
+		msgs, ok = shrinkMessages(msgs, excess)
+		if !ok {
+			return "", tokens, fmt.Errorf("prompt needs %d tokens but only %d fit in the %d-token context window",
+				tokens, limit, b.ContextSize)
+		}
+		prompt = render(msgs)
+		tokens = int(math.Ceil(float64(len(prompt)) * perByte))
+	}
+	return prompt, tokens, nil
+}
+
+// shrinkMessages applies the Budget policy until about excess bytes are removed.
+// ok is false if nothing more could be removed.
+func shrinkMessages(msgs []Message, excess int) ([]Message, bool) {
+	removed := 0
+
+	lastTool := -1
+	for i, msg := range msgs {
+		if msg.Role == "tool" {
+			lastTool = i
+		}
+	}
+
+	// Steps 1 and 2: shorten, then omit, older tool results
+	for _, shorten := range []func(string) string{excerpt, omit} {
+		for i := range msgs {
+			if removed >= excess {
+				return msgs, true
+			}
+			if msgs[i].Role != "tool" || i == lastTool {
+				continue
+			}
+			removed += replaceContent(&msgs[i], shorten)
+		}
+	}
+
+	// Step 3: drop the oldest exchanges, keeping the latest user turn
+	for removed < excess {
+		start, end := oldestExchange(msgs)
+		if start < 0 {
+			break
+		}
+		kept := append([]Message(nil), msgs[:start]...)
+		for _, msg := range msgs[start:end] {
+			if msg.Role == "system" {
+				kept = append(kept, msg)
+				continue
+			}
+			removed += len(msg.Content) + len(formatToolCalls(msg.ToolCalls))
+		}
+		msgs = append(kept, msgs[end:]...)
+	}
+
+	// Step 4: the newest tool result, if it is still present
+	lastTool = -1
+	for i, msg := range msgs {
+		if msg.Role == "tool" {
+			lastTool = i
+		}
+	}
+	if lastTool >= 0 {
+		for _, shorten := range []func(string) string{excerpt, omit} {
+			if removed >= excess {
+				break
+			}
+			removed += replaceContent(&msgs[lastTool], shorten)
+		}
+	}
+
+	return msgs, removed > 0
+}
+
+// replaceContent shortens msg's content and returns how many bytes were saved
+func replaceContent(msg *Message, shorten func(string) string) int {
+	shorter := shorten(msg.Content)
+	if len(shorter) >= len(msg.Content) {
+		return 0
+	}
+	saved := len(msg.Content) - len(shorter)
+	msg.Content = shorter
+	return saved
+}
+
+// oldestExchange finds the first droppable exchange: the messages from the first
+// non-system message up to the next user turn. It returns -1 if there is none.
+func oldestExchange(msgs []Message) (int, int) {
+	lastUser := -1
+	for i, msg := range msgs {
+		if msg.Role == "user" {
+			lastUser = i
+		}
+	}
+
+	start := -1
+	for i, msg := range msgs {
+		if msg.Role != "system" {
+			start = i
+			break
+		}
+	}
+	if start < 0 || start >= lastUser {
+		return -1, -1
+	}
+
+	end := start + 1
+	for end < lastUser && msgs[end].Role != "user" {
+		end++
+	}
+	return start, end
+}
+
+// excerpt keeps the head and tail of long text, cutting at line boundaries
+func excerpt(text string) string {
+	if len(text) <= toolExcerptBytes {
+		return text
+	}
+
+	head := text[:toolExcerptBytes/2]
+	if i := strings.LastIndex(head, "\n"); i > 0 {
+		head = head[:i]
+	}
+	tail := text[len(text)-toolExcerptBytes/2:]
+	if i := strings.Index(tail, "\n"); i >= 0 {
+		tail = tail[i+1:]
+	}
+
+	omitted := strings.Count(text, "\n") - strings.Count(head, "\n") - strings.Count(tail, "\n")
+	return fmt.Sprintf("%s\n[... %d lines omitted to fit the context window ...]\n%s", head, omitted, tail)
+}
+
+// omit replaces text with a placeholder
+func omit(text string) string {
+	return omittedToolOutput
+}
+
+// estimateTokens approximates a token count when no tokenizer is available.
+// Source code averages a little over three bytes per token, so this errs high.
+func estimateTokens(text string) int {
+	return (len(text) + 2) / 3
+}
+
+// CountTokens measures text with p's tokenizer if it has one, and estimates otherwise
+func CountTokens(ctx context.Context, p Provider, text string) int {
+	if tokenizer, ok := p.(Tokenizer); ok {
+		if n, err := tokenizer.CountTokens(ctx, text); err == nil {
+			return n
+		}
+	}
+	return estimateTokens(text)
+}
+
+// estimator is a Tokenizer that uses estimateTokens
+type estimator struct{}
+
+// CountTokens implements Tokenizer
+func (estimator) CountTokens(ctx context.Context, text string) (int, error) {
+	return estimateTokens(text), nil
+}
@@ -0,0 +1,165 @@
+package llm
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// renderPlainPrompt renders messages without a tool preamble, so sizes in tests stay predictable
+func renderPlainPrompt(m []Message) string {
+	return templates[TemplatePlain].Format("", m)
+}
+
+func TestBudgetFit(t *testing.T) {
+	bigOutput := strings.Repeat("line of file content\n", 300) // ~6300 bytes
+
+	tests := []struct {
+		name        string
+		contextSize int
+		messages    []Message
+		contains    []string
+		notContains []string
+		expectError bool
+	}{
+		{
+			name:        "Fits unchanged",
+			contextSize: 1000,
+			messages: []Message{
+				{Role: "system", Content: "You are helpful."},
+				{Role: "user", Content: "hi"},
+			},
+			contains: []string{"You are helpful.", "hi"},
+		},
+		{
+			name:        "Old tool output shortened",
+			contextSize: 1200,
+			messages: []Message{
+				{Role: "system", Content: "You are helpful."},
+				{Role: "user", Content: "read it"},
+				{Role: "tool", Name: "read_file", Content: bigOutput},
+				{Role: "tool", Name: "list_files", Content: "a.go\nb.go"},
+			},
+			contains:    []string{"lines omitted to fit the context window", "a.go\nb.go", "read it"},
+			notContains: []string{omittedToolOutput},
+		},
+		{
+			name:        "Old tool outputs omitted",
+			contextSize: 600,
+			messages: []Message{
+				{Role: "system", Content: "You are helpful."},
+				{Role: "user", Content: "read them"},
+				{Role: "tool", Name: "read_file", Content: bigOutput},
+				{Role: "tool", Name: "read_file", Content: bigOutput},
+				{Role: "tool", Name: "list_files", Content: "a.go\nb.go"},
+			},
+			contains: []string{omittedToolOutput, "a.go\nb.go", "read them"},
+		},
+		{
+			name:        "Oldest exchange dropped",
+			contextSize: 1050,
+			messages: []Message{
+				{Role: "system", Content: "You are helpful."},
+				{Role: "user", Content: "first question " + strings.Repeat("x", 3000)},
+				{Role: "assistant", Content: "first answer"},
+				{Role: "user", Content: "second question"},
+			},
+			contains:    []string{"You are helpful.", "second question"},
+			notContains: []string{"first question", "first answer"},
+		},
+		{
+			name:        "System prompt alone too large",
+			contextSize: 1100,
+			messages: []Message{
+				{Role: "system", Content: strings.Repeat("rules ", 1000)},
+				{Role: "user", Content: "hi"},
+			},
+			expectError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			budget := Budget{ContextSize: tt.contextSize, Reserve: 100, Tokenizer: estimator{}}
+
+			prompt, tokens, err := budget.Fit(context.Background(), tt.messages, renderPlainPrompt)
+			if tt.expectError {
+				if err == nil {
+					t.Errorf("Expected error, got prompt of %d tokens", tokens)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Fit() failed: %v", err)
+			}
+
+			if tokens > tt.contextSize-100 {
+				t.Errorf("Prompt has %d tokens, budget is %d", tokens, tt.contextSize-100)
+			}
+			for _, want := range tt.contains {
+				if !strings.Contains(prompt, want) {
+					t.Errorf("Expected prompt to contain %q", want)
+				}
+			}
+			for _, unwanted := range tt.notContains {
+				if strings.Contains(prompt, unwanted) {
+					t.Errorf("Expected prompt not to contain %q", unwanted)
+				}
+			}
+		})
+	}
+}
+
+func TestBudgetFitKeepsCallerMessages(t *testing.T) {
+	messages := []Message{
+		{Role: "user", Content: "read it"},
+		{Role: "tool", Name: "read_file", Content: strings.Repeat("data\n", 2000)},
+		{Role: "tool", Name: "read_file", Content: "small"},
+	}
+	original := messages[1].Content
+
+	budget := Budget{ContextSize: 800, Reserve: 100, Tokenizer: estimator{}}
+	if _, _, err := budget.Fit(context.Background(), messages, renderPlainPrompt); err != nil {
+		t.Fatalf("Fit() failed: %v", err)
+	}
+
+	if messages[1].Content != original {
+		t.Error("Fit() must not modify the caller's messages")
+	}
+}
+
+// countingTokenizer estimates like estimator and counts how often it is asked
+type countingTokenizer struct{ calls int }
+
+func (c *countingTokenizer) CountTokens(ctx context.Context, text string) (int, error) {
+	c.calls++
+	return estimateTokens(text), nil
+}
+
+// TestBudgetFitTokenizesOnce checks that trimming a long conversation goes by
+// the first measurement instead of running the tokenizer after every cut
+func TestBudgetFitTokenizesOnce(t *testing.T) {
+	var messages []Message
+	for i := 0; i < 20; i++ {
+		messages = append(messages,
+			Message{Role: "user", Content: fmt.Sprintf("question %d", i)},
+			Message{Role: "tool", Name: "read_file", Content: strings.Repeat("data\n", 400)},
+			Message{Role: "assistant", Content: strings.Repeat("answer ", 200)},
+		)
+	}
+	messages = append(messages, Message{Role: "user", Content: "last question"})
+
+	tokenizer := &countingTokenizer{}
+	budget := Budget{ContextSize: 1000, Reserve: 100, Tokenizer: tokenizer}
+	prompt, tokens, err := budget.Fit(context.Background(), messages, renderPlainPrompt)
+	if err != nil {
+		t.Fatalf("Fit() failed: %v", err)
+	}
+	if tokens > 900 || !strings.Contains(prompt, "last question") {
+		t.Errorf("unexpected fit: %d tokens", tokens)
+	}
+	if tokenizer.calls != 1 {
+		t.Errorf("tokenizer ran %d times, want 1", tokenizer.calls)
+	}
+}
@@ -0,0 +1,51 @@
+package llm
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// PromptCacher is implemented by providers that can keep the evaluated prompt
+// between calls, so a shared prefix (system prompt, earlier turns) is not
+// processed again. key identifies the conversation, e.g. the session ID.
+type PromptCacher interface {
+	SetPromptCache(dir, key string) error
+}
+
+// cacheStats describes how much of a prompt was served from the KV cache
+type cacheStats struct {
+	reused int // Prompt tokens whose state was reused
+	total  int // Prompt tokens in the request
+}
+
+func (s cacheStats) String() string {
+	if s.reused == 0 {
+		return fmt.Sprintf("miss: evaluated all %d prompt tokens", s.total)
+	}
+	return fmt.Sprintf("hit: reused %d/%d prompt tokens", s.reused, s.total)
+}
+
+// sessionMatch matches llama-cli's "session file matches 1450 / 1620 tokens of prompt"
+// and "session file has low similarity to prompt (12 / 1620 tokens)"
+var sessionMatch = regexp.MustCompile(`(\d+) / (\d+) tokens`)
+
+// parsePromptCacheLog reads the prompt cache result from llama-cli's stderr.
+// promptTokens is used when llama-cli reports an exact match without counts.
+func parsePromptCacheLog(stderr string, promptTokens int) (cacheStats, bool) {
+	switch {
+	case strings.Contains(stderr, "session file does not exist"):
+		return cacheStats{total: promptTokens}, true
+	case strings.Contains(stderr, "session file has exact match for prompt"):
+		return cacheStats{reused: promptTokens, total: promptTokens}, true
+	}
+
+	m := sessionMatch.FindStringSubmatch(stderr)
+	if m == nil {
+		return cacheStats{}, false
+	}
+	reused, _ := strconv.Atoi(m[1])
+	total, _ := strconv.Atoi(m[2])
+	return cacheStats{reused: reused, total: total}, true
+}
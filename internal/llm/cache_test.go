@@ -0,0 +1,95 @@
+package llm
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"testing"
+)
+
+func TestParsePromptCacheLog(t *testing.T) {
+	tests := []struct {
+		name     string
+		stderr   string
+		expected cacheStats
+		ok       bool
+	}{
+		{
+			name:     "First call",
+			stderr:   "main: attempting to load saved session from '.pg/cache/pg-1.bin'\nmain: session file does not exist, will create.\n",
+			expected: cacheStats{reused: 0, total: 1600},
+			ok:       true,
+		},
+		{
+			name:     "Shared prefix",
+			stderr:   "main: loaded a session with prompt size of 1500 tokens\nmain: session file matches 1450 / 1620 tokens of prompt\n",
+			expected: cacheStats{reused: 1450, total: 1620},
+			ok:       true,
+		},
+		{
+			name:     "Low similarity",
+			stderr:   "main: session file has low similarity to prompt (12 / 1620 tokens); will mostly be reevaluated\n",
+			expected: cacheStats{reused: 12, total: 1620},
+			ok:       true,
+		},
+		{
+			name:     "Exact match",
+			stderr:   "main: session file has exact match for prompt!\n",
+			expected: cacheStats{reused: 1600, total: 1600},
+			ok:       true,
+		},
+		{
+			name:   "Logging disabled",
+			stderr: "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stats, ok := parsePromptCacheLog(tt.stderr, 1600)
+			if ok != tt.ok || stats != tt.expected {
+				t.Errorf("Expected %+v (ok=%v), got %+v (ok=%v)", tt.expected, tt.ok, stats, ok)
+			}
+		})
+	}
+}
+
+func TestLocalProviderPromptCache(t *testing.T) {
+	p, err := NewLocalProvider("/models/qwen2.5-coder-3b-instruct-q4_k_m.gguf")
+	if err != nil {
+		t.Fatalf("NewLocalProvider() failed: %v", err)
+	}
+
+	args := p.args("prompt", nil, GenerationOptions{})
+	for _, arg := range args {
+		if arg == "--prompt-cache" {
+			t.Fatal("Prompt cache should be off until SetPromptCache is called")
+		}
+	}
+
+	dir := filepath.Join(t.TempDir(), ".pg", "cache")
+	if err := p.SetPromptCache(dir, "pg-1"); err != nil {
+		t.Fatalf("SetPromptCache() failed: %v", err)
+	}
+
+	args = p.args("prompt", nil, GenerationOptions{})
+	want := filepath.Join(dir, "pg-1-qwen2.5-coder-3b-instruct-q4_k_m.bin")
+	if args[len(args)-2] != "--prompt-cache" || args[len(args)-1] != want {
+		t.Errorf("Expected --prompt-cache %s, got %v", want, args)
+	}
+}
+
+func TestServerCacheStats(t *testing.T) {
+	var r completionResponse
+	if err := json.Unmarshal([]byte(`{"tokens_evaluated": 1620, "timings": {"prompt_n": 170}}`), &r); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+
+	stats, ok := r.cacheStats()
+	if !ok || stats != (cacheStats{reused: 1450, total: 1620}) {
+		t.Errorf("Expected 1450/1620 reused, got %+v (ok=%v)", stats, ok)
+	}
+
+	if _, ok := (completionResponse{TokensEvaluated: 1620}).cacheStats(); ok {
+		t.Error("Expected no stats without timings")
+	}
+}
@@ -0,0 +1,370 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// ErrCassetteMismatch is returned when a replayed request differs from the recording
+var ErrCassetteMismatch = errors.New("request does not match cassette")
+
+// cassette is the on-disk recording of a provider session
+type cassette struct {
+	Provider     string        `json:"provider"` // Name of the recorded provider
+	Interactions []interaction `json:"interactions"`
+}
+
+// interaction is one Chat or ChatStream call and what the provider returned
+type interaction struct {
+	Request  cassetteRequest `json:"request"`
+	Response *Response       `json:"response,omitempty"` // Chat result
+	Chunks   []cassetteChunk `json:"chunks,omitempty"`   // ChatStream result, in order
+	Error    string          `json:"error,omitempty"`    // Error returned by the call itself
+}
+
+type cassetteRequest struct {
+	Stream   bool              `json:"stream"`
+	Messages []Message         `json:"messages"`
+	Tools    []Tool            `json:"tools,omitempty"`
+	Options  GenerationOptions `json:"options"` // Recorded for reproduction, not compared on replay
+}
+
+// cassetteChunk is a StreamChunk with the error kept as text
+type cassetteChunk struct {
+	Content      string    `json:"content,omitempty"`
+	ToolCall     *ToolCall `json:"tool_call,omitempty"`
+	FinishReason string    `json:"finish_reason,omitempty"`
+	Usage        *Usage    `json:"usage,omitempty"`
+	Error        string    `json:"error,omitempty"`
+}
+
+// RecordingProvider wraps a provider and writes every call to a cassette file
+// The file is rewritten after each call, so an interrupted session is still replayable
+type RecordingProvider struct {
+	inner    Provider
+	path     string
+	mu       sync.Mutex
+	cassette cassette
+}
+
+// NewRecordingProvider records calls to inner into the cassette at path, replacing any existing file
+func NewRecordingProvider(inner Provider, path string) *RecordingProvider {
+	return &RecordingProvider{
+		inner:    inner,
+		path:     path,
+		cassette: cassette{Provider: inner.Name()},
+	}
+}
+
+// Chat forwards to the wrapped provider and records the result
+func (p *RecordingProvider) Chat(ctx context.Context, messages []Message, tools []Tool, opts GenerationOptions) (*Response, error) {
+	response, err := p.inner.Chat(ctx, messages, tools, opts)
+
+	entry := interaction{
+		Request:  newCassetteRequest(false, messages, tools, opts),
+		Response: response,
+	}
+	if err != nil {
+		entry.Error = err.Error()
+	}
+
+	if saveErr := p.record(entry); saveErr != nil && err == nil {
+		return nil, saveErr
+	}
+	return response, err
+}
+
+// ChatStream forwards to the wrapped provider and records the chunks as they pass through
+func (p *RecordingProvider) ChatStream(ctx context.Context, messages []Message, tools []Tool, opts GenerationOptions) (<-chan StreamChunk, error) {
+	entry := interaction{Request: newCassetteRequest(true, messages, tools, opts)}
+
+	stream, err := p.inner.ChatStream(ctx, messages, tools, opts)
+	if err != nil {
+		entry.Error = err.Error()
+		p.record(entry)
+		return nil, err
+	}
+
+	out := make(chan StreamChunk)
+	go func() {
+		defer close(out)
+
+		for chunk := range stream {
+			recorded := cassetteChunk{
+				Content:      chunk.Content,
+				ToolCall:     chunk.ToolCall,
+				FinishReason: chunk.FinishReason,
+				Usage:        chunk.Usage,
+			}
+			if chunk.Error != nil {
+				recorded.Error = chunk.Error.Error()
+			}
+			entry.Chunks = append(entry.Chunks, recorded)
+
+			// A consumer that stopped reading has cancelled ctx; keep draining
+			// the stream so the interaction is still recorded
+			select {
+			case out <- chunk:
+			case <-ctx.Done():
+			}
+		}
+
+		if err := p.record(entry); err != nil {
+			select {
+			case out <- StreamChunk{Error: err}:
+			case <-ctx.Done():
+			}
+		}
+	}()
+
+	return out, nil
+}
+
+// Name returns the wrapped provider's name
+func (p *RecordingProvider) Name() string {
+	return p.inner.Name()
+}
+
+// SetPromptCache enables prompt caching on the wrapped provider, if it supports it
+func (p *RecordingProvider) SetPromptCache(dir, key string) error {
+	if cacher, ok := p.inner.(PromptCacher); ok {
+		return cacher.SetPromptCache(dir, key)
+	}
+	return nil
+}
+
+// Close releases the wrapped provider
+func (p *RecordingProvider) Close() error {
+	if closer, ok := p.inner.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
+
+// record appends an interaction and rewrites the cassette file
+func (p *RecordingProvider) record(entry interaction) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.cassette.Interactions = append(p.cassette.Interactions, entry)
+
+	data, err := json.MarshalIndent(p.cassette, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal cassette: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(p.path), 0755); err != nil {
+		return fmt.Errorf("failed to create cassette directory: %w", err)
+	}
+
+	// Atomic write: write to temp file, then rename
+	tempPath := p.path + ".tmp"
+	if err := os.WriteFile(tempPath, data, 0644); err != nil {
+		return fmt.Errorf("failed to write cassette: %w", err)
+	}
+	if err := os.Rename(tempPath, p.path); err != nil {
+		os.Remove(tempPath)
+		return fmt.Errorf("failed to save cassette: %w", err)
+	}
+
+	return nil
+}
+
+// ReplayProvider serves the responses from a cassette in the order they were recorded
+// Each request must match the recorded one, otherwise the call fails with ErrCassetteMismatch
+type ReplayProvider struct {
+	path     string
+	mu       sync.Mutex
+	cassette cassette
+	next     int
+}
+
+// NewReplayProvider loads the cassette at path
+func NewReplayProvider(path string) (*ReplayProvider, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read cassette: %w", err)
+	}
+
+	var c cassette
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, fmt.Errorf("failed to parse cassette %s: %w", path, err)
+	}
+
+	return &ReplayProvider{path: path, cassette: c}, nil
+}
+
+// Chat returns the next recorded response
+func (p *ReplayProvider) Chat(ctx context.Context, messages []Message, tools []Tool, opts GenerationOptions) (*Response, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	entry, err := p.take(newCassetteRequest(false, messages, tools, opts))
+	if err != nil {
+		return nil, err
+	}
+	if entry.Error != "" {
+		return nil, errors.New(entry.Error)
+	}
+	return entry.Response, nil
+}
+
+// ChatStream replays the next recorded stream chunk by chunk
+func (p *ReplayProvider) ChatStream(ctx context.Context, messages []Message, tools []Tool, opts GenerationOptions) (<-chan StreamChunk, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	entry, err := p.take(newCassetteRequest(true, messages, tools, opts))
+	if err != nil {
+		return nil, err
+	}
+	if entry.Error != "" {
+		return nil, errors.New(entry.Error)
+	}
+
+	out := make(chan StreamChunk)
+	go func() {
+		defer close(out)
+
+		for _, recorded := range entry.Chunks {
+			chunk := StreamChunk{
+				Content:      recorded.Content,
+				ToolCall:     recorded.ToolCall,
+				FinishReason: recorded.FinishReason,
+				Usage:        recorded.Usage,
+			}
+			if recorded.Error != "" {
+				chunk.Error = errors.New(recorded.Error)
+			}
+
+			select {
+			case out <- chunk:
+			case <-ctx.Done():
+				// Report the cancellation only if someone is still reading
+				select {
+				case out <- StreamChunk{Error: ctx.Err()}:
+				default:
+				}
+				return
+			}
+		}
+	}()
+
+	return out, nil
+}
+
+// Name returns the recorded provider's name
+func (p *ReplayProvider) Name() string {
+	return p.cassette.Provider + " (replay)"
+}
+
+// Remaining returns how many recorded interactions have not been served yet
+func (p *ReplayProvider) Remaining() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return len(p.cassette.Interactions) - p.next
+}
+
+// take checks req against the next recorded interaction and advances past it
+func (p *ReplayProvider) take(req cassetteRequest) (interaction, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.next >= len(p.cassette.Interactions) {
+		return interaction{}, fmt.Errorf("%w: %s has %d interactions, got request %d",
+			ErrCassetteMismatch, p.path, len(p.cassette.Interactions), p.next+1)
+	}
+
+	entry := p.cassette.Interactions[p.next]
+	if diff := diffRequests(entry.Request, req); diff != "" {
+		return interaction{}, fmt.Errorf("%w: %s request %d: %s", ErrCassetteMismatch, p.path, p.next+1, diff)
+	}
+
+	p.next++
+	return entry, nil
+}
+
+// newCassetteRequest normalises a request through JSON, so it compares equal to one read from disk
+func newCassetteRequest(stream bool, messages []Message, tools []Tool, opts GenerationOptions) cassetteRequest {
+	req := cassetteRequest{Stream: stream, Messages: messages, Tools: tools, Options: opts}
+
+	data, err := json.Marshal(req)
+	if err != nil {
+		return req
+	}
+
+	var normalised cassetteRequest
+	if err := json.Unmarshal(data, &normalised); err != nil {
+		return req
+	}
+	return normalised
+}
+
+// diffRequests describes the first difference between a recorded and an actual request
+func diffRequests(recorded, actual cassetteRequest) string {
+	if recorded.Stream != actual.Stream {
+		return fmt.Sprintf("recorded stream=%v, got stream=%v", recorded.Stream, actual.Stream)
+	}
+
+	for i := 0; i < len(recorded.Messages) && i < len(actual.Messages); i++ {
+		want, got := recorded.Messages[i], actual.Messages[i]
+		if want.Role != got.Role {
+			return fmt.Sprintf("message %d: recorded role %q, got %q", i+1, want.Role, got.Role)
+		}
+		if want.Content != got.Content {
+			return fmt.Sprintf("message %d (%s) content differs:\n%s", i+1, want.Role, diffText(want.Content, got.Content))
+		}
+		if a, b := jsonText(want), jsonText(got); a != b {
+			return fmt.Sprintf("message %d (%s) differs:\n%s", i+1, want.Role, diffText(a, b))
+		}
+	}
+	if len(recorded.Messages) != len(actual.Messages) {
+		return fmt.Sprintf("recorded %d messages, got %d", len(recorded.Messages), len(actual.Messages))
+	}
+
+	if a, b := jsonText(recorded.Tools), jsonText(actual.Tools); a != b {
+		return fmt.Sprintf("tool definitions differ:\n%s", diffText(a, b))
+	}
+
+	return ""
+}
+
+// diffText shows both strings around the first byte where they differ
+func diffText(recorded, actual string) string {
+	const context = 40
+
+	at := 0
+	for at < len(recorded) && at < len(actual) && recorded[at] == actual[at] {
+		at++
+	}
+
+	window := func(s string) string {
+		start := at - context
+		prefix := "..."
+		if start <= 0 {
+			start, prefix = 0, ""
+		}
+		end := at + context
+		suffix := "..."
+		if end >= len(s) {
+			end, suffix = len(s), ""
+		}
+		return prefix + s[start:end] + suffix
+	}
+
+	return fmt.Sprintf("  at byte %d\n  recorded: %q\n  actual:   %q", at, window(recorded), window(actual))
+}
+
+func jsonText(v interface{}) string {
+	data, _ := json.Marshal(v)
+	return string(data)
+}
@@ -0,0 +1,237 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// scriptedProvider returns canned responses in order, standing in for a model
+type scriptedProvider struct {
+	responses []Response
+	calls     int
+}
+
+func (p *scriptedProvider) Chat(ctx context.Context, messages []Message, tools []Tool, opts GenerationOptions) (*Response, error) {
+	response := p.responses[p.calls]
+	p.calls++
+	return &response, nil
+}
+
+func (p *scriptedProvider) ChatStream(ctx context.Context, messages []Message, tools []Tool, opts GenerationOptions) (<-chan StreamChunk, error) {
+	response := p.responses[p.calls]
+	p.calls++
+
+	words := strings.SplitAfter(response.Content, " ")
+	out := make(chan StreamChunk, len(words)+len(response.ToolCalls)+1)
+	for _, word := range words {
+		out <- StreamChunk{Content: word}
+	}
+	for i := range response.ToolCalls {
+		out <- StreamChunk{ToolCall: &response.ToolCalls[i]}
+	}
+	out <- StreamChunk{FinishReason: response.FinishReason, Usage: &response.Usage}
+	close(out)
+	return out, nil
+}
+
+func (p *scriptedProvider) Name() string {
+	return "scripted"
+}
+
+func TestCassetteRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "session.json")
+	ctx := context.Background()
+	seed := 7
+
+	conversation := []Message{
+		{Role: "system", Content: "You are a coding assistant."},
+		{Role: "user", Content: "What does main.go do?"},
+	}
+	call := ToolCall{ID: "call_1", Name: "read_file", Arguments: map[string]interface{}{"path": "main.go", "limit": 10}}
+	followUp := append(conversation,
+		Message{Role: "assistant", ToolCalls: []ToolCall{call}},
+		Message{Role: "tool", Name: "read_file", ToolCallID: "call_1", Content: "package main"},
+	)
+
+	recorder := NewRecordingProvider(&scriptedProvider{responses: []Response{
+		{ToolCalls: []ToolCall{call}, FinishReason: "tool_calls"},
+		{Content: "It declares package main.", FinishReason: "stop", Usage: Usage{PromptTokens: 40, CompletionTokens: 6}},
+	}}, path)
+
+	if _, err := recorder.Chat(ctx, conversation, goldenTools, GenerationOptions{Seed: &seed}); err != nil {
+		t.Fatalf("Record Chat: %v", err)
+	}
+	stream, err := recorder.ChatStream(ctx, followUp, goldenTools, GenerationOptions{})
+	if err != nil {
+		t.Fatalf("Record ChatStream: %v", err)
+	}
+	for range stream {
+	}
+
+	replay, err := NewReplayProvider(path)
+	if err != nil {
+		t.Fatalf("NewReplayProvider: %v", err)
+	}
+	if replay.Name() != "scripted (replay)" {
+		t.Errorf("Unexpected name %q", replay.Name())
+	}
+
+	response, err := replay.Chat(ctx, conversation, goldenTools, GenerationOptions{})
+	if err != nil {
+		t.Fatalf("Replay Chat: %v", err)
+	}
+	if len(response.ToolCalls) != 1 || response.ToolCalls[0].Arguments["path"] != "main.go" {
+		t.Errorf("Expected recorded read_file call, got %+v", response.ToolCalls)
+	}
+
+	stream, err = replay.ChatStream(ctx, followUp, goldenTools, GenerationOptions{})
+	if err != nil {
+		t.Fatalf("Replay ChatStream: %v", err)
+	}
+	var content strings.Builder
+	var chunks int
+	var usage *Usage
+	for chunk := range stream {
+		if chunk.Error != nil {
+			t.Fatalf("Replay chunk error: %v", chunk.Error)
+		}
+		content.WriteString(chunk.Content)
+		if chunk.Usage != nil {
+			usage = chunk.Usage
+		}
+		chunks++
+	}
+	if content.String() != "It declares package main." || chunks != 5 {
+		t.Errorf("Expected the recorded chunks, got %d chunks %q", chunks, content.String())
+	}
+	if usage == nil || usage.CompletionTokens != 6 {
+		t.Errorf("Expected recorded usage, got %+v", usage)
+	}
+
+	if replay.Remaining() != 0 {
+		t.Errorf("Expected cassette to be used up, %d left", replay.Remaining())
+	}
+	if _, err := replay.Chat(ctx, conversation, goldenTools, GenerationOptions{}); !errors.Is(err, ErrCassetteMismatch) {
+		t.Errorf("Expected mismatch past the end of the cassette, got %v", err)
+	}
+}
+
+func TestCassetteMismatch(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "session.json")
+	ctx := context.Background()
+
+	conversation := []Message{
+		{Role: "system", Content: "You are a coding assistant."},
+		{Role: "user", Content: "Fix the login handler"},
+	}
+	recorder := NewRecordingProvider(&scriptedProvider{responses: []Response{{Content: "Done", FinishReason: "stop"}}}, path)
+	if _, err := recorder.Chat(ctx, conversation, goldenTools, GenerationOptions{}); err != nil {
+		t.Fatalf("Record Chat: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		stream   bool
+		messages []Message
+		tools    []Tool
+		expected string
+	}{
+		{
+			name:     "Different user message",
+			messages: []Message{conversation[0], {Role: "user", Content: "Fix the logout handler"}},
+			tools:    goldenTools,
+			expected: `message 2 (user) content differs`,
+		},
+		{
+			name:     "Extra message",
+			messages: append(conversation, Message{Role: "assistant", Content: "On it"}),
+			tools:    goldenTools,
+			expected: "recorded 2 messages, got 3",
+		},
+		{
+			name:     "Different tools",
+			messages: conversation,
+			tools:    nil,
+			expected: "tool definitions differ",
+		},
+		{
+			name:     "Streaming instead of Chat",
+			stream:   true,
+			messages: conversation,
+			tools:    goldenTools,
+			expected: "recorded stream=false",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			replay, err := NewReplayProvider(path)
+			if err != nil {
+				t.Fatalf("NewReplayProvider: %v", err)
+			}
+
+			if tt.stream {
+				_, err = replay.ChatStream(ctx, tt.messages, tt.tools, GenerationOptions{})
+			} else {
+				_, err = replay.Chat(ctx, tt.messages, tt.tools, GenerationOptions{})
+			}
+
+			if !errors.Is(err, ErrCassetteMismatch) {
+				t.Fatalf("Expected ErrCassetteMismatch, got %v", err)
+			}
+			if !strings.Contains(err.Error(), tt.expected) {
+				t.Errorf("Expected error to mention %q, got: %v", tt.expected, err)
+			}
+			if replay.Remaining() != 1 {
+				t.Errorf("A mismatched request must not consume the recording")
+			}
+		})
+	}
+}
+
+// TestCassetteStreamCancelled checks that a consumer that stops reading
+// leaves neither provider's goroutine blocked, and the stream still recorded
+func TestCassetteStreamCancelled(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "session.json")
+	messages := []Message{{Role: "user", Content: "hi"}}
+
+	recorder := NewRecordingProvider(&scriptedProvider{responses: []Response{
+		{Content: "one two three four", FinishReason: "stop"},
+	}}, path)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stream, err := recorder.ChatStream(ctx, messages, nil, GenerationOptions{})
+	if err != nil {
+		t.Fatalf("Record ChatStream: %v", err)
+	}
+	<-stream
+	cancel()
+
+	var replay *ReplayProvider
+	for deadline := time.Now().Add(2 * time.Second); replay == nil; {
+		if replay, err = NewReplayProvider(path); err != nil && time.Now().After(deadline) {
+			t.Fatalf("Cassette was not written after the consumer stopped: %v", err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if got := len(replay.cassette.Interactions[0].Chunks); got != 5 {
+		t.Errorf("Expected all 5 chunks recorded, got %d", got)
+	}
+
+	ctx, cancel = context.WithCancel(context.Background())
+	stream, err = replay.ChatStream(ctx, messages, nil, GenerationOptions{})
+	if err != nil {
+		t.Fatalf("Replay ChatStream: %v", err)
+	}
+	cancel()
+
+	// Without a reader the goroutine gives up instead of waiting to report the cancellation
+	time.Sleep(50 * time.Millisecond)
+	for chunk := range stream {
+		t.Errorf("Expected the stream to close unread, got %+v", chunk)
+	}
+}
@@ -0,0 +1,160 @@
+package llm
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+)
+
+// ggufMagic is "GGUF" read as a little-endian uint32
+const ggufMagic = 0x46554747
+
+// GGUF metadata value types
+const (
+	ggufUint8 uint32 = iota
+	ggufInt8
+	ggufUint16
+	ggufInt16
+	ggufUint32
+	ggufInt32
+	ggufFloat32
+	ggufBool
+	ggufString
+	ggufArray
+	ggufUint64
+	ggufInt64
+	ggufFloat64
+)
+
+// ggufChatTemplateKey is the metadata key holding the model's Jinja chat template
+const ggufChatTemplateKey = "tokenizer.chat_template"
+
+// readGGUFChatTemplate returns the chat template embedded in a GGUF file's metadata.
+// It returns "" without error if the model has none.
+func readGGUFChatTemplate(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	return findGGUFString(bufio.NewReader(f), ggufChatTemplateKey)
+}
+
+// findGGUFString scans the GGUF header for a string value under key.
+// Only the metadata section is read; tensor data is never touched.
+func findGGUFString(r io.Reader, key string) (string, error) {
+	var header struct {
+		Magic       uint32
+		Version     uint32
+		TensorCount uint64
+		KVCount     uint64
+	}
+	if err := binary.Read(r, binary.LittleEndian, &header); err != nil {
+		return "", fmt.Errorf("failed to read GGUF header: %w", err)
+	}
+	if header.Magic != ggufMagic {
+		return "", fmt.Errorf("not a GGUF file")
+	}
+	if header.Version < 2 {
+		return "", fmt.Errorf("unsupported GGUF version %d", header.Version)
+	}
+
+	for i := uint64(0); i < header.KVCount; i++ {
+		name, err := readGGUFString(r)
+		if err != nil {
+			return "", err
+		}
+
+		var valueType uint32
+		if err := binary.Read(r, binary.LittleEndian, &valueType); err != nil {
+			return "", fmt.Errorf("failed to read GGUF value type: %w", err)
+		}
+
+		if name == key && valueType == ggufString {
+			return readGGUFString(r)
+		}
+
+		if err := skipGGUFValue(r, valueType); err != nil {
+			return "", fmt.Errorf("failed to skip GGUF key %s: %w", name, err)
+		}
+	}
+
+	return "", nil
+}
+
+// readGGUFString reads a length-prefixed GGUF string
+func readGGUFString(r io.Reader) (string, error) {
+	var n uint64
+	if err := binary.Read(r, binary.LittleEndian, &n); err != nil {
+		return "", fmt.Errorf("failed to read GGUF string length: %w", err)
+	}
+	if n > 1<<24 {
+		return "", fmt.Errorf("GGUF string too long: %d bytes", n)
+	}
+
+	buf := make([]byte, n)
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return "", fmt.Errorf("failed to read GGUF string: %w", err)
+	}
+	return string(buf), nil
+}
+
+// skipGGUFValue discards one metadata value of the given type
+func skipGGUFValue(r io.Reader, valueType uint32) error {
+	switch valueType {
+	case ggufString:
+		var n uint64
+		if err := binary.Read(r, binary.LittleEndian, &n); err != nil {
+			return err
+		}
+		_, err := io.CopyN(io.Discard, r, int64(n))
+		return err
+
+	case ggufArray:
+		var elemType uint32
+		var count uint64
+		if err := binary.Read(r, binary.LittleEndian, &elemType); err != nil {
+			return err
+		}
+		if err := binary.Read(r, binary.LittleEndian, &count); err != nil {
+			return err
+		}
+
+		// Fixed-size elements can be skipped in one go
+		if size := ggufScalarSize(elemType); size > 0 {
+			_, err := io.CopyN(io.Discard, r, int64(size)*int64(count))
+			return err
+		}
+		for i := uint64(0); i < count; i++ {
+			if err := skipGGUFValue(r, elemType); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	size := ggufScalarSize(valueType)
+	if size == 0 {
+		return fmt.Errorf("unknown GGUF value type %d", valueType)
+	}
+	_, err := io.CopyN(io.Discard, r, int64(size))
+	return err
+}
+
+// ggufScalarSize returns the byte size of a fixed-size value type, or 0
+func ggufScalarSize(valueType uint32) int {
+	switch valueType {
+	case ggufUint8, ggufInt8, ggufBool:
+		return 1
+	case ggufUint16, ggufInt16:
+		return 2
+	case ggufUint32, ggufInt32, ggufFloat32:
+		return 4
+	case ggufUint64, ggufInt64, ggufFloat64:
+		return 8
+	}
+	return 0
+}
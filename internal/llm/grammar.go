@@ -0,0 +1,217 @@
+package llm
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// gbnfPrimitives are the JSON building blocks shared by every generated grammar.
+// Strings may not contain raw control characters, so a JSON string never spans lines.
+const gbnfPrimitives = `string ::= "\"" ( [^"\\\x7F\x00-\x1F] | "\\" ( ["\\/bfnrt] | "u" [0-9a-fA-F] [0-9a-fA-F] [0-9a-fA-F] [0-9a-fA-F] ) )* "\""
+integer ::= "-"? ( "0" | [1-9] [0-9]* )
+number ::= integer ( "." [0-9]+ )? ( [eE] [-+]? [0-9]+ )?
+boolean ::= "true" | "false"
+null ::= "null"
+value ::= object | array | string | number | boolean | null
+object ::= "{" ws ( string ws ":" ws value ( ws "," ws string ws ":" ws value )* )? ws "}"
+array ::= "[" ws ( value ( ws "," ws value )* )? ws "]"`
+
+// ToolGrammar returns a GBNF grammar that lets the model either answer in free
+// text or end its reply with tool calls that match the tools' JSON schemas.
+//
+// Each tool call is a single line in the {"tool": ..., "args": {...}} format
+// that ParseToolCalls reads. Free-text lines may not start with "{", so a
+// malformed call cannot be produced.
+func ToolGrammar(tools []Tool) string {
+	g := newGrammarBuilder(`[ \t]*`)
+
+	var calls []string
+	for _, tool := range tools {
+		name := "tool-" + ruleName(tool.Name)
+		args := g.schema(name+"-args", tool.Parameters)
+		calls = append(calls, g.add(name, fmt.Sprintf(`"{" ws %s ws ":" ws %s ws "," ws %s ws ":" ws %s ws "}"`,
+			gbnfLiteral(`"tool"`), gbnfLiteral(jsonString(tool.Name)), gbnfLiteral(`"args"`), args)))
+	}
+
+	var root string
+	if len(calls) == 0 {
+		root = `( line "\n" )* line`
+	} else {
+		g.add("call", strings.Join(calls, " | "))
+		root = `( line "\n" )* ( line | call ( "\n" call )* )`
+	}
+	g.add("line", `( [^{\n] [^\n]* )?`)
+
+	return g.build(root)
+}
+
+// SchemaGrammar returns a GBNF grammar whose only valid output is a JSON value
+// matching schema. Whitespace, including newlines, is allowed between tokens.
+func SchemaGrammar(schema map[string]interface{}) string {
+	g := newGrammarBuilder(`[ \t\n]*`)
+	return g.build(g.schema("doc", schema) + " ws")
+}
+
+// grammarBuilder collects GBNF rules while walking JSON schemas
+type grammarBuilder struct {
+	ws    string
+	rules []string
+	names map[string]bool
+}
+
+// newGrammarBuilder creates a builder using ws as the whitespace rule
+func newGrammarBuilder(ws string) *grammarBuilder {
+	return &grammarBuilder{ws: ws, names: map[string]bool{}}
+}
+
+// add defines a rule once and returns its name
+func (g *grammarBuilder) add(name, body string) string {
+	if !g.names[name] {
+		g.names[name] = true
+		g.rules = append(g.rules, fmt.Sprintf("%s ::= %s", name, body))
+	}
+	return name
+}
+
+// build returns the finished grammar with the given root rule
+func (g *grammarBuilder) build(root string) string {
+	var sb strings.Builder
+	sb.WriteString("root ::= " + root + "\n")
+	for _, rule := range g.rules {
+		sb.WriteString(rule + "\n")
+	}
+	sb.WriteString("ws ::= " + g.ws + "\n")
+	sb.WriteString(gbnfPrimitives + "\n")
+	return sb.String()
+}
+
+// schema returns a rule reference matching a JSON schema
+func (g *grammarBuilder) schema(name string, schema map[string]interface{}) string {
+	if enum, ok := schema["enum"].([]interface{}); ok && len(enum) > 0 {
+		alts := make([]string, 0, len(enum))
+		for _, v := range enum {
+			data, _ := json.Marshal(v)
+			alts = append(alts, gbnfLiteral(string(data)))
+		}
+		return g.add(name, strings.Join(alts, " | "))
+	}
+	if enum, ok := schema["enum"].([]string); ok && len(enum) > 0 {
+		alts := make([]string, 0, len(enum))
+		for _, v := range enum {
+			alts = append(alts, gbnfLiteral(jsonString(v)))
+		}
+		return g.add(name, strings.Join(alts, " | "))
+	}
+
+	switch schema["type"] {
+	case "string":
+		return "string"
+	case "integer":
+		return "integer"
+	case "number":
+		return "number"
+	case "boolean":
+		return "boolean"
+	case "array":
+		items, _ := schema["items"].(map[string]interface{})
+		item := g.schema(name+"-item", items)
+		return g.add(name, fmt.Sprintf(`"[" ws ( %s ( ws "," ws %s )* )? ws "]"`, item, item))
+	case "object":
+		return g.object(name, schema)
+	}
+
+	return "value"
+}
+
+// object returns a rule for an object schema.
+// Required properties come first in declaration order, then optional ones alphabetically.
+func (g *grammarBuilder) object(name string, schema map[string]interface{}) string {
+	props, _ := schema["properties"].(map[string]interface{})
+	if len(props) == 0 {
+		return g.add(name, `"{" ws "}"`)
+	}
+
+	required := schemaRequired(schema)
+	isRequired := map[string]bool{}
+	for _, r := range required {
+		isRequired[r] = true
+	}
+
+	var optional []string
+	for prop := range props {
+		if !isRequired[prop] {
+			optional = append(optional, prop)
+		}
+	}
+	sort.Strings(optional)
+
+	pair := func(prop string) string {
+		propSchema, _ := props[prop].(map[string]interface{})
+		value := g.schema(name+"-"+ruleName(prop), propSchema)
+		return fmt.Sprintf(`%s ws ":" ws %s`, gbnfLiteral(jsonString(prop)), value)
+	}
+
+	if len(required) == 0 {
+		// Any subset of optional properties, in any order
+		alts := make([]string, 0, len(optional))
+		for _, prop := range optional {
+			alts = append(alts, pair(prop))
+		}
+		kv := g.add(name+"-kv", strings.Join(alts, " | "))
+		return g.add(name, fmt.Sprintf(`"{" ws ( %s ( ws "," ws %s )* )? ws "}"`, kv, kv))
+	}
+
+	parts := make([]string, 0, len(props))
+	for i, prop := range required {
+		if i > 0 {
+			parts = append(parts, `ws "," ws`)
+		}
+		parts = append(parts, pair(prop))
+	}
+	for _, prop := range optional {
+		parts = append(parts, fmt.Sprintf(`( ws "," ws %s )?`, pair(prop)))
+	}
+
+	return g.add(name, fmt.Sprintf(`"{" ws %s ws "}"`, strings.Join(parts, " ")))
+}
+
+// schemaRequired returns the schema's required property names
+func schemaRequired(schema map[string]interface{}) []string {
+	switch required := schema["required"].(type) {
+	case []string:
+		return required
+	case []interface{}:
+		names := make([]string, 0, len(required))
+		for _, r := range required {
+			if s, ok := r.(string); ok {
+				names = append(names, s)
+			}
+		}
+		return names
+	}
+	return nil
+}
+
+// ruleName converts an identifier into a valid GBNF rule name
+func ruleName(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' {
+			return r
+		}
+		return '-'
+	}, s)
+}
+
+// jsonString encodes s as a JSON string literal
+func jsonString(s string) string {
+	data, _ := json.Marshal(s)
+	return string(data)
+}
+
+// gbnfLiteral quotes s as a GBNF string literal
+func gbnfLiteral(s string) string {
+	r := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "\t", `\t`)
+	return `"` + r.Replace(s) + `"`
+}
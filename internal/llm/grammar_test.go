@@ -0,0 +1,369 @@
+package llm
+
+import (
+	"strings"
+	"testing"
+)
+
+// grammarTestTools mirrors the shape of the agent's tool definitions
+var grammarTestTools = []Tool{
+	{
+		Name: "read_file",
+		Parameters: map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"path": map[string]interface{}{"type": "string"},
+			},
+			"required": []string{"path"},
+		},
+	},
+	{
+		Name: "git_status",
+		Parameters: map[string]interface{}{
+			"type":       "object",
+			"properties": map[string]interface{}{},
+		},
+	},
+	{
+		Name: "propose_patch",
+		Parameters: map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"file_path":    map[string]interface{}{"type": "string"},
+				"unified_diff": map[string]interface{}{"type": "string"},
+			},
+			"required": []interface{}{"file_path", "unified_diff"},
+		},
+	},
+	{
+		Name: "search",
+		Parameters: map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"query": map[string]interface{}{"type": "string"},
+				"limit": map[string]interface{}{"type": "integer"},
+				"mode":  map[string]interface{}{"type": "string", "enum": []string{"regex", "literal"}},
+			},
+			"required": []string{"query"},
+		},
+	},
+}
+
+func TestToolGrammar(t *testing.T) {
+	grammar := ToolGrammar(grammarTestTools)
+
+	tests := []struct {
+		name   string
+		output string
+		valid  bool
+	}{
+		{"Free text", "The project uses JWT auth.", true},
+		{"Free text with code", "Try this:\nfunc main() {\n\tfmt.Println(\"hi\")\n}", true},
+		{"Empty reply", "", true},
+		{"Tool call", `{"tool": "read_file", "args": {"path": "main.go"}}`, true},
+		{"Text then tool call", "Let me look.\n" + `{"tool": "read_file", "args": {"path": "main.go"}}`, true},
+		{"Two tool calls", `{"tool": "git_status", "args": {}}` + "\n" + `{"tool": "read_file", "args": {"path": "a.go"}}`, true},
+		{"Tool without args", `{"tool":"git_status","args":{}}`, true},
+		{"Escaped newlines in diff", `{"tool": "propose_patch", "args": {"file_path": "a.go", "unified_diff": "--- a.go\n+++ a.go\n@@ -1 +1 @@\n-a\n+b\n"}}`, true},
+		{"Optional and enum args", `{"tool": "search", "args": {"query": "TODO", "limit": 5, "mode": "literal"}}`, true},
+		{"Missing required arg", `{"tool": "read_file", "args": {}}`, false},
+		{"Unknown tool", `{"tool": "delete_file", "args": {"path": "main.go"}}`, false},
+		{"Wrong arg type", `{"tool": "search", "args": {"query": "TODO", "limit": "five"}}`, false},
+		{"Bad enum value", `{"tool": "search", "args": {"query": "TODO", "mode": "fuzzy"}}`, false},
+		{"Call spread over lines", "{\"tool\": \"read_file\",\n \"args\": {\"path\": \"main.go\"}}", false},
+		{"Text after tool call", `{"tool": "git_status", "args": {}}` + "\nDone.", false},
+		{"Broken JSON", `{"tool": "read_file", "args": {"path": "main.go"}`, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := gbnfMatch(t, grammar, tt.output); got != tt.valid {
+				t.Errorf("Expected valid=%v for %q, got %v", tt.valid, tt.output, got)
+			}
+		})
+	}
+}
+
+func TestSchemaGrammar(t *testing.T) {
+	grammar := SchemaGrammar(map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"goal":  map[string]interface{}{"type": "string"},
+			"steps": map[string]interface{}{"type": "array", "items": map[string]interface{}{"type": "string"}},
+		},
+		"required": []string{"goal", "steps"},
+	})
+
+	tests := []struct {
+		name   string
+		output string
+		valid  bool
+	}{
+		{"Compact", `{"goal":"add auth","steps":["read","patch"]}`, true},
+		{"Pretty printed", "{\n  \"goal\": \"add auth\",\n  \"steps\": [\n    \"read\"\n  ]\n}\n", true},
+		{"Empty array", `{"goal": "x", "steps": []}`, true},
+		{"Missing field", `{"goal": "x"}`, false},
+		{"Prose", "Here is the plan: read then patch", false},
+		{"Wrong item type", `{"goal": "x", "steps": [1]}`, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := gbnfMatch(t, grammar, tt.output); got != tt.valid {
+				t.Errorf("Expected valid=%v for %q, got %v", tt.valid, tt.output, got)
+			}
+		})
+	}
+}
+
+// gbnfNode is a parsed GBNF expression, enough of the format to check our generated grammars
+type gbnfNode struct {
+	op    byte // '"' literal, '[' class, 'r' rule ref, 's' sequence, '|' alternation, '*', '+', '?'
+	lit   []rune
+	class func(rune) bool
+	ref   string
+	kids  []*gbnfNode
+}
+
+// gbnfMatch reports whether grammar accepts input in full
+func gbnfMatch(t *testing.T, grammar, input string) bool {
+	t.Helper()
+
+	rules := map[string]*gbnfNode{}
+	for _, line := range strings.Split(strings.TrimSpace(grammar), "\n") {
+		name, body, ok := strings.Cut(line, " ::= ")
+		if !ok {
+			t.Fatalf("Malformed grammar line: %q", line)
+		}
+		p := &gbnfParser{src: []rune(body)}
+		rules[name] = p.alt()
+		if p.pos != len(p.src) {
+			t.Fatalf("Trailing input in rule %s at %d: %q", name, p.pos, body)
+		}
+	}
+
+	m := &gbnfMatcher{rules: rules, in: []rune(input), memo: map[string]map[int][]int{}}
+	for _, end := range m.match(&gbnfNode{op: 'r', ref: "root"}, 0) {
+		if end == len(m.in) {
+			return true
+		}
+	}
+	return false
+}
+
+type gbnfParser struct {
+	src []rune
+	pos int
+}
+
+func (p *gbnfParser) skipSpace() {
+	for p.pos < len(p.src) && p.src[p.pos] == ' ' {
+		p.pos++
+	}
+}
+
+func (p *gbnfParser) alt() *gbnfNode {
+	node := &gbnfNode{op: '|', kids: []*gbnfNode{p.seq()}}
+	for p.skipSpace(); p.pos < len(p.src) && p.src[p.pos] == '|'; p.skipSpace() {
+		p.pos++
+		node.kids = append(node.kids, p.seq())
+	}
+	return node
+}
+
+func (p *gbnfParser) seq() *gbnfNode {
+	node := &gbnfNode{op: 's'}
+	for {
+		p.skipSpace()
+		if p.pos >= len(p.src) || p.src[p.pos] == '|' || p.src[p.pos] == ')' {
+			return node
+		}
+		atom := p.atom()
+		if p.pos < len(p.src) && strings.ContainsRune("*+?", p.src[p.pos]) {
+			atom = &gbnfNode{op: byte(p.src[p.pos]), kids: []*gbnfNode{atom}}
+			p.pos++
+		}
+		node.kids = append(node.kids, atom)
+	}
+}
+
+func (p *gbnfParser) atom() *gbnfNode {
+	switch c := p.src[p.pos]; {
+	case c == '"':
+		p.pos++
+		var lit []rune
+		for p.src[p.pos] != '"' {
+			lit = append(lit, p.char())
+		}
+		p.pos++
+		return &gbnfNode{op: '"', lit: lit}
+	case c == '[':
+		p.pos++
+		negate := p.src[p.pos] == '^'
+		if negate {
+			p.pos++
+		}
+		type span struct{ lo, hi rune }
+		var spans []span
+		for p.src[p.pos] != ']' {
+			lo := p.char()
+			hi := lo
+			if p.src[p.pos] == '-' && p.src[p.pos+1] != ']' {
+				p.pos++
+				hi = p.char()
+			}
+			spans = append(spans, span{lo, hi})
+		}
+		p.pos++
+		return &gbnfNode{op: '[', class: func(r rune) bool {
+			for _, s := range spans {
+				if r >= s.lo && r <= s.hi {
+					return !negate
+				}
+			}
+			return negate
+		}}
+	case c == '(':
+		p.pos++
+		node := p.alt()
+		p.pos++ // ')'
+		return node
+	default:
+		start := p.pos
+		for p.pos < len(p.src) && (p.src[p.pos] == '-' || p.src[p.pos] >= 'a' && p.src[p.pos] <= 'z' ||
+			p.src[p.pos] >= 'A' && p.src[p.pos] <= 'Z' || p.src[p.pos] >= '0' && p.src[p.pos] <= '9') {
+			p.pos++
+		}
+		if start == p.pos {
+			panic("unexpected character in grammar: " + string(c))
+		}
+		return &gbnfNode{op: 'r', ref: string(p.src[start:p.pos])}
+	}
+}
+
+// char reads one possibly escaped character inside a literal or class
+func (p *gbnfParser) char() rune {
+	c := p.src[p.pos]
+	p.pos++
+	if c != '\\' {
+		return c
+	}
+	e := p.src[p.pos]
+	p.pos++
+	switch e {
+	case 'n':
+		return '\n'
+	case 't':
+		return '\t'
+	case 'x':
+		var v rune
+		for i := 0; i < 2; i++ {
+			d := p.src[p.pos]
+			p.pos++
+			switch {
+			case d >= '0' && d <= '9':
+				v = v*16 + d - '0'
+			case d >= 'a' && d <= 'f':
+				v = v*16 + d - 'a' + 10
+			case d >= 'A' && d <= 'F':
+				v = v*16 + d - 'A' + 10
+			}
+		}
+		return v
+	}
+	return e
+}
+
+// gbnfMatcher returns every end position an expression can reach, so ambiguity needs no backtracking
+type gbnfMatcher struct {
+	rules map[string]*gbnfNode
+	in    []rune
+	memo  map[string]map[int][]int
+}
+
+func (m *gbnfMatcher) match(n *gbnfNode, pos int) []int {
+	switch n.op {
+	case '"':
+		if pos+len(n.lit) <= len(m.in) && string(m.in[pos:pos+len(n.lit)]) == string(n.lit) {
+			return []int{pos + len(n.lit)}
+		}
+		return nil
+	case '[':
+		if pos < len(m.in) && n.class(m.in[pos]) {
+			return []int{pos + 1}
+		}
+		return nil
+	case 'r':
+		if ends, ok := m.memo[n.ref][pos]; ok {
+			return ends
+		}
+		rule, ok := m.rules[n.ref]
+		if !ok {
+			panic("undefined grammar rule: " + n.ref)
+		}
+		if m.memo[n.ref] == nil {
+			m.memo[n.ref] = map[int][]int{}
+		}
+		ends := m.match(rule, pos)
+		m.memo[n.ref][pos] = ends
+		return ends
+	case 's':
+		cur := []int{pos}
+		for _, kid := range n.kids {
+			cur = m.matchAll(kid, cur)
+		}
+		return cur
+	case '|':
+		var ends []int
+		for _, kid := range n.kids {
+			ends = union(ends, m.match(kid, pos))
+		}
+		return ends
+	case '?':
+		return union([]int{pos}, m.match(n.kids[0], pos))
+	case '*', '+':
+		seen := []int{}
+		if n.op == '*' {
+			seen = []int{pos}
+		}
+		frontier := []int{pos}
+		for len(frontier) > 0 {
+			var next []int
+			for _, end := range m.matchAll(n.kids[0], frontier) {
+				if !contains(seen, end) && end != pos {
+					seen = append(seen, end)
+					next = append(next, end)
+				}
+			}
+			frontier = next
+		}
+		return seen
+	}
+	panic("unknown grammar node")
+}
+
+func (m *gbnfMatcher) matchAll(n *gbnfNode, starts []int) []int {
+	var ends []int
+	for _, start := range starts {
+		ends = union(ends, m.match(n, start))
+	}
+	return ends
+}
+
+func union(a, b []int) []int {
+	for _, v := range b {
+		if !contains(a, v) {
+			a = append(a, v)
+		}
+	}
+	return a
+}
+
+func contains(list []int, v int) bool {
+	for _, x := range list {
+		if x == v {
+			return true
+		}
+	}
+	return false
+}
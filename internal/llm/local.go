@@ -9,15 +9,15 @@ import (
 	"os/exec"
 	"path/filepath"
 	"strings"
-	"time"
 )
 
 // LocalProvider implements the Provider interface using llama.cpp CLI
 type LocalProvider struct {
 	modelPath   string
 	contextSize int
-	debugMode   bool
-	logDir      string
+	template    *ChatTemplate
+	promptCache string // llama-cli --prompt-cache file, empty when caching is off
+	debugLogger
 }
 
 // NewLocalProvider creates a new local LLM provider
@@ -26,63 +26,52 @@ func NewLocalProvider(modelPath string) (*LocalProvider, error) {
 		return nil, fmt.Errorf("model path is required")
 	}
 
-	// Check for debug mode
-	debugMode := os.Getenv("PG_DEBUG") == "1"
-
-	// Set up log directory
-	homeDir, err := os.UserHomeDir()
-	if err != nil {
-		homeDir = "."
-	}
-	logDir := filepath.Join(homeDir, ".playground", "logs")
-
 	return &LocalProvider{
 		modelPath:   modelPath,
 		contextSize: 4096,
-		debugMode:   debugMode,
-		logDir:      logDir,
+		template:    TemplateForModel(modelPath),
+		debugLogger: newDebugLogger(),
 	}, nil
 }
 
 // Chat implements the Provider interface
-func (p *LocalProvider) Chat(messages []Message, tools []Tool) (*Response, error) {
-	// Build prompt from messages and tools
-	prompt := p.buildPrompt(messages, tools)
+func (p *LocalProvider) Chat(ctx context.Context, messages []Message, tools []Tool, opts GenerationOptions) (*Response, error) {
+	opts = DefaultGenerationOptions().Merge(opts)
 
-	// Call llama.cpp CLI (llama-cli or main executable)
-	ctx, cancel := context.WithTimeout(context.Background(), 120*time.Second)
-	defer cancel()
+	// Build prompt from messages and tools, trimmed to the context window
+	prompt, promptTokens, err := p.fitPrompt(ctx, messages, tools, opts)
+	if err != nil {
+		return nil, err
+	}
 
-	cmd := exec.CommandContext(ctx, "llama-cli",
-		"--model", p.modelPath,
-		"--prompt", prompt,
-		"--ctx-size", fmt.Sprintf("%d", p.contextSize),
-		"--n-predict", "2048",
-		"--temp", "0.1",
-		"--top-k", "40",
-		"--top-p", "0.9",
-		"--threads", "4",
-		"--no-display-prompt",
-	)
+	// Call llama.cpp CLI (llama-cli or main executable)
+	// The process is killed if ctx is cancelled
+	cmd := exec.CommandContext(ctx, "llama-cli", p.args(prompt, tools, opts)...)
 
 	var stdout, stderr bytes.Buffer
 	cmd.Stdout = &stdout
 	cmd.Stderr = &stderr
 
 	if err := cmd.Run(); err != nil {
+		if ctx.Err() != nil {
+			return nil, fmt.Errorf("generation stopped: %w", ctx.Err())
+		}
 		return nil, fmt.Errorf("llama.cpp execution failed: %w\nStderr: %s", err, stderr.String())
 	}
+	p.logPromptCache(stderr.String(), promptTokens)
 
-	result := strings.TrimSpace(stdout.String())
+	// llama-cli has no stop-sequence flag, so cut the reply where the model's turn ends
+	result := strings.TrimSpace(trimAtStop(stdout.String(), stopSequences(p.template, opts)))
 
 	// Parse response for tool calls
 	response := &Response{
 		Content:      result,
 		FinishReason: "stop",
+		Usage:        p.usage(ctx, promptTokens, result),
 	}
 
 	// Try to extract tool calls from response
-	toolCalls := p.extractToolCalls(result)
+	toolCalls := ParseToolCalls(result)
 	if len(toolCalls) > 0 {
 		response.ToolCalls = toolCalls
 		response.FinishReason = "tool_calls"
@@ -92,68 +81,109 @@ func (p *LocalProvider) Chat(messages []Message, tools []Tool) (*Response, error
 }
 
 // ChatStream implements streaming chat
-func (p *LocalProvider) ChatStream(messages []Message, tools []Tool) (<-chan StreamChunk, error) {
+func (p *LocalProvider) ChatStream(ctx context.Context, messages []Message, tools []Tool, opts GenerationOptions) (<-chan StreamChunk, error) {
+	opts = DefaultGenerationOptions().Merge(opts)
+
+	// Build prompt, trimmed to the context window
+	prompt, promptTokens, err := p.fitPrompt(ctx, messages, tools, opts)
+	if err != nil {
+		return nil, err
+	}
+
 	ch := make(chan StreamChunk, 10)
 
 	go func() {
 		defer close(ch)
 
-		// Build prompt
-		prompt := p.buildPrompt(messages, tools)
+		// genCtx lets us stop llama-cli as soon as the model ends its turn
+		genCtx, stopGeneration := context.WithCancel(ctx)
+		defer stopGeneration()
 
 		// Call llama.cpp CLI with streaming
-		ctx, cancel := context.WithTimeout(context.Background(), 120*time.Second)
-		defer cancel()
-
-		cmd := exec.CommandContext(ctx, "llama-cli",
-			"--model", p.modelPath,
-			"--prompt", prompt,
-			"--ctx-size", fmt.Sprintf("%d", p.contextSize),
-			"--n-predict", "2048",
-			"--temp", "0.1",
-			"--top-k", "40",
-			"--top-p", "0.9",
-			"--threads", "4",
-			"--no-display-prompt",
-		)
+		cmd := exec.CommandContext(genCtx, "llama-cli", p.args(prompt, tools, opts)...)
 
 		stdout, err := cmd.StdoutPipe()
 		if err != nil {
 			ch <- StreamChunk{Error: fmt.Errorf("failed to create stdout pipe: %w", err)}
 			return
 		}
+		var stderr bytes.Buffer
+		cmd.Stderr = &stderr
 
 		if err := cmd.Start(); err != nil {
 			ch <- StreamChunk{Error: fmt.Errorf("failed to start llama.cpp: %w", err)}
 			return
 		}
 
-		// Stream output
+		// Stream output, holding back anything that may be a stop sequence
+		// and turning tool call JSON into ToolCall chunks as each object closes
 		buf := make([]byte, 1024)
 		var fullResponse strings.Builder
+		filter := &stopFilter{stops: stopSequences(p.template, opts)}
+		scanner := newToolCallScanner()
+		calls := 0
+		stoppedAtCall := false
+
+		emit := func(text string) (stop bool) {
+			fullResponse.WriteString(text)
+			display, toolCalls := scanner.push(text)
+			if display != "" {
+				ch <- StreamChunk{Content: display}
+			}
+			for i := range toolCalls {
+				ch <- StreamChunk{ToolCall: &toolCalls[i], FinishReason: "tool_calls"}
+			}
+			calls += len(toolCalls)
+			return len(toolCalls) > 0 && opts.stopAfterToolCall()
+		}
 
 		for {
 			n, err := stdout.Read(buf)
 			if n > 0 {
-				chunk := string(buf[:n])
-				fullResponse.WriteString(chunk)
-				ch <- StreamChunk{Content: chunk}
+				chunk, stopped := filter.push(string(buf[:n]))
+				if chunk != "" && emit(chunk) {
+					stopped, stoppedAtCall = true, true
+				}
+				if stopped {
+					stopGeneration()
+					break
+				}
 			}
 			if err != nil {
+				if rest := filter.flush(); rest != "" {
+					emit(rest)
+				}
 				break
 			}
 		}
 
 		cmd.Wait()
+		p.logPromptCache(stderr.String(), promptTokens)
+
+		if ctx.Err() != nil {
+			ch <- StreamChunk{Error: fmt.Errorf("generation stopped: %w", ctx.Err())}
+			return
+		}
+
+		// Release anything held back that never became a tool call,
+		// unless generation was cut short at a call
+		if rest := scanner.flush(); rest != "" && !stoppedAtCall {
+			ch <- StreamChunk{Content: rest}
+		}
 
-		// Check for tool calls in final response
-		toolCalls := p.extractToolCalls(fullResponse.String())
-		if len(toolCalls) > 0 {
-			for _, tc := range toolCalls {
-				ch <- StreamChunk{ToolCall: &tc, FinishReason: "tool_calls"}
+		// Calls the scanner cannot spot as they stream, e.g. after prose on the same line
+		if calls == 0 {
+			for _, call := range ParseToolCalls(fullResponse.String()) {
+				ch <- StreamChunk{ToolCall: &call, FinishReason: "tool_calls"}
+				calls++
 			}
+		}
+
+		usage := p.usage(ctx, promptTokens, fullResponse.String())
+		if calls > 0 {
+			ch <- StreamChunk{FinishReason: "tool_calls", Usage: &usage}
 		} else {
-			ch <- StreamChunk{FinishReason: "stop"}
+			ch <- StreamChunk{FinishReason: "stop", Usage: &usage}
 		}
 	}()
 
@@ -165,116 +195,16 @@ func (p *LocalProvider) Name() string {
 	return "DeepSeek-Coder-7B-Instruct-v1.5 (local)"
 }
 
-// buildPrompt constructs the prompt for DeepSeek-Coder
-func (p *LocalProvider) buildPrompt(messages []Message, tools []Tool) string {
-	var prompt strings.Builder
-
-	// System message with tool definitions
-	prompt.WriteString("You are a coding assistant. You can use tools by outputting JSON.\n\n")
-
-	if len(tools) > 0 {
-		prompt.WriteString("Available tools:\n")
-		for _, tool := range tools {
-			prompt.WriteString(fmt.Sprintf("- %s: %s\n", tool.Name, tool.Description))
-		}
-		prompt.WriteString("\nTo use a tool, output JSON in this format:\n")
-		prompt.WriteString(`{"tool": "tool_name", "args": {"param": "value"}}` + "\n\n")
-	}
-
-	prompt.WriteString("CRITICAL RULES:\n")
-	prompt.WriteString("1. NEVER write files directly\n")
-	prompt.WriteString("2. ONLY propose unified diffs\n")
-	prompt.WriteString("3. Explain intent BEFORE proposing changes\n")
-	prompt.WriteString("4. Ask clarifying questions if ambiguous\n")
-	prompt.WriteString("5. One logical change per patch\n\n")
-
-	// Add conversation history
-	for _, msg := range messages {
-		switch msg.Role {
-		case "system":
-			prompt.WriteString(fmt.Sprintf("System: %s\n\n", msg.Content))
-		case "user":
-			prompt.WriteString(fmt.Sprintf("User: %s\n\n", msg.Content))
-		case "assistant":
-			prompt.WriteString(fmt.Sprintf("Assistant: %s\n\n", msg.Content))
-		case "tool":
-			prompt.WriteString(fmt.Sprintf("Tool Result (%s): %s\n\n", msg.Name, msg.Content))
-		}
-	}
-
-	prompt.WriteString("Assistant: ")
-
-	return prompt.String()
-}
-
-// extractToolCalls attempts to parse tool calls from the response
-func (p *LocalProvider) extractToolCalls(response string) []ToolCall {
-	var toolCalls []ToolCall
-
-	// Look for JSON tool call patterns
-	// Pattern: {"tool": "name", "args": {...}}
-	lines := strings.Split(response, "\n")
-	for _, line := range lines {
-		line = strings.TrimSpace(line)
-		if !strings.HasPrefix(line, "{") {
-			continue
-		}
-
-		var toolCall struct {
-			Tool string                 `json:"tool"`
-			Args map[string]interface{} `json:"args"`
-		}
-
-		if err := json.Unmarshal([]byte(line), &toolCall); err == nil {
-			if toolCall.Tool != "" {
-				toolCalls = append(toolCalls, ToolCall{
-					ID:        fmt.Sprintf("call_%d", time.Now().UnixNano()),
-					Name:      toolCall.Tool,
-					Arguments: toolCall.Args,
-				})
-			}
-		}
-	}
-
-	return toolCalls
-}
-
-// logToFile writes content to a log file if debug mode is enabled
-func (p *LocalProvider) logToFile(filename, content string) error {
-	if !p.debugMode {
-		return nil // Silent in production
-	}
-
-	// Create log directory if it doesn't exist
-	if err := os.MkdirAll(p.logDir, 0755); err != nil {
-		return fmt.Errorf("failed to create log directory: %w", err)
-	}
-
-	logPath := filepath.Join(p.logDir, filename)
-
-	// Append timestamp
-	timestamp := time.Now().Format("2006-01-02 15:04:05")
-	logContent := fmt.Sprintf("=== %s ===\n%s\n\n", timestamp, content)
+// ChatSilent is like Chat but suppresses all output in production mode
+// In debug mode (PG_DEBUG=1), logs are written to ~/.playground/logs/
+func (p *LocalProvider) ChatSilent(ctx context.Context, messages []Message, tools []Tool, opts GenerationOptions, logName string) (*Response, error) {
+	opts = DefaultGenerationOptions().Merge(opts)
 
-	// Append to file
-	f, err := os.OpenFile(logPath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	// Build prompt, trimmed to the context window
+	prompt, promptTokens, err := p.fitPrompt(ctx, messages, tools, opts)
 	if err != nil {
-		return fmt.Errorf("failed to open log file: %w", err)
+		return nil, err
 	}
-	defer f.Close()
-
-	if _, err := f.WriteString(logContent); err != nil {
-		return fmt.Errorf("failed to write to log file: %w", err)
-	}
-
-	return nil
-}
-
-// ChatSilent is like Chat but suppresses all output in production mode
-// In debug mode (PG_DEBUG=1), logs are written to ~/.playground/logs/
-func (p *LocalProvider) ChatSilent(messages []Message, tools []Tool, logName string) (*Response, error) {
-	// Build prompt
-	prompt := p.buildPrompt(messages, tools)
 
 	// Log prompt in debug mode
 	if err := p.logToFile(logName+"_prompt.txt", prompt); err != nil {
@@ -283,21 +213,9 @@ func (p *LocalProvider) ChatSilent(messages []Message, tools []Tool, logName str
 	}
 
 	// Call llama.cpp CLI
-	ctx, cancel := context.WithTimeout(context.Background(), 120*time.Second)
-	defer cancel()
-
-	cmd := exec.CommandContext(ctx, "llama-cli",
-		"--model", p.modelPath,
-		"--prompt", prompt,
-		"--ctx-size", fmt.Sprintf("%d", p.contextSize),
-		"--n-predict", "2048",
-		"--temp", "0.1",
-		"--top-k", "40",
-		"--top-p", "0.9",
-		"--threads", "4",
-		"--no-display-prompt",
+	cmd := exec.CommandContext(ctx, "llama-cli", append(p.args(prompt, tools, opts),
 		"--log-disable", // Disable llama.cpp's own logging
-	)
+	)...)
 
 	// Capture output
 	var stdout, stderr bytes.Buffer
@@ -312,7 +230,8 @@ func (p *LocalProvider) ChatSilent(messages []Message, tools []Tool, logName str
 		return nil, fmt.Errorf("llama-cli failed: %w", err)
 	}
 
-	output := stdout.String()
+	p.logPromptCache(stderr.String(), promptTokens)
+	output := trimAtStop(stdout.String(), stopSequences(p.template, opts))
 
 	// Log output in debug mode
 	if err := p.logToFile(logName+"_output.txt", output); err != nil {
@@ -325,10 +244,111 @@ func (p *LocalProvider) ChatSilent(messages []Message, tools []Tool, logName str
 	}
 
 	// Parse tool calls
-	toolCalls := p.extractToolCalls(output)
+	toolCalls := ParseToolCalls(output)
 
 	return &Response{
 		Content:   output,
 		ToolCalls: toolCalls,
+		Usage:     p.usage(ctx, promptTokens, output),
 	}, nil
 }
+
+// args builds the llama-cli arguments for a prompt.
+// With tools, a grammar limits output to free text or valid tool calls.
+func (p *LocalProvider) args(prompt string, tools []Tool, opts GenerationOptions) []string {
+	args := []string{
+		"--model", p.modelPath,
+		"--prompt", prompt,
+		"--ctx-size", fmt.Sprintf("%d", p.contextSize),
+		"--no-display-prompt",
+	}
+	args = append(args, opts.LlamaCLIArgs()...)
+	if p.promptCache != "" {
+		args = append(args, "--prompt-cache", p.promptCache)
+	}
+	if len(tools) > 0 {
+		args = append(args, "--grammar", ToolGrammar(tools))
+	}
+	return args
+}
+
+// SetPromptCache implements PromptCacher. llama-cli saves the evaluated prompt
+// to a file per conversation and model, and on the next call only evaluates
+// the part of the prompt that differs from it.
+func (p *LocalProvider) SetPromptCache(dir, key string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create prompt cache directory: %w", err)
+	}
+
+	modelName := strings.TrimSuffix(filepath.Base(p.modelPath), filepath.Ext(p.modelPath))
+	p.promptCache = filepath.Join(dir, key+"-"+modelName+".bin")
+	return nil
+}
+
+// logPromptCache records how much of the prompt llama-cli reused, in debug mode
+func (p *LocalProvider) logPromptCache(stderr string, promptTokens int) {
+	if p.promptCache == "" {
+		return
+	}
+	if stats, ok := parsePromptCacheLog(stderr, promptTokens); ok {
+		p.logToFile("cache.log", fmt.Sprintf("%s: %s", filepath.Base(p.promptCache), stats))
+	}
+}
+
+// CountTokens implements Tokenizer with llama-tokenize, which loads only the
+// model's vocabulary. Without it the count is estimated. The text goes in on
+// stdin, since a whole prompt can be longer than a command line may be.
+func (p *LocalProvider) CountTokens(ctx context.Context, text string) (int, error) {
+	if _, err := exec.LookPath("llama-tokenize"); err != nil {
+		return estimateTokens(text), nil
+	}
+
+	cmd := exec.CommandContext(ctx, "llama-tokenize",
+		"--model", p.modelPath,
+		"--stdin",
+		"--ids",
+		"--log-disable",
+	)
+	cmd.Stdin = strings.NewReader(text)
+
+	output, err := cmd.Output()
+	if err != nil {
+		if ctx.Err() != nil {
+			return 0, fmt.Errorf("generation stopped: %w", ctx.Err())
+		}
+		return estimateTokens(text), nil
+	}
+
+	// --ids prints the token IDs as a single list, e.g. [1, 15043, 3186]
+	lines := strings.Split(strings.TrimSpace(string(output)), "\n")
+	var ids []int
+	if err := json.Unmarshal([]byte(lines[len(lines)-1]), &ids); err != nil {
+		return estimateTokens(text), nil
+	}
+
+	return len(ids), nil
+}
+
+// fitPrompt renders the prompt, trimming history to fit the context window
+func (p *LocalProvider) fitPrompt(ctx context.Context, messages []Message, tools []Tool, opts GenerationOptions) (string, int, error) {
+	budget := Budget{ContextSize: p.contextSize, Reserve: replyReserve(opts), Tokenizer: p}
+
+	prompt, tokens, err := budget.Fit(ctx, messages, func(m []Message) string {
+		return buildPrompt(p.template, m, tools)
+	})
+	if err != nil {
+		return "", 0, err
+	}
+
+	p.logToFile("budget.log", fmt.Sprintf("prompt: %d/%d tokens", tokens, p.contextSize))
+	return prompt, tokens, nil
+}
+
+// usage counts the completion and pairs it with the measured prompt size
+func (p *LocalProvider) usage(ctx context.Context, promptTokens int, completion string) Usage {
+	completionTokens, err := p.CountTokens(ctx, completion)
+	if err != nil {
+		completionTokens = estimateTokens(completion)
+	}
+	return Usage{PromptTokens: promptTokens, CompletionTokens: completionTokens}
+}
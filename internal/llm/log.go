@@ -0,0 +1,63 @@
+package llm
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// debugLogger writes provider diagnostics to ~/.playground/logs
+// Logging is only enabled when PG_DEBUG=1
+type debugLogger struct {
+	debugMode bool
+	logDir    string
+}
+
+// newDebugLogger creates a logger configured from the environment
+func newDebugLogger() debugLogger {
+	// Check for debug mode
+	debugMode := os.Getenv("PG_DEBUG") == "1"
+
+	// Set up log directory
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		homeDir = "."
+	}
+
+	return debugLogger{
+		debugMode: debugMode,
+		logDir:    filepath.Join(homeDir, ".playground", "logs"),
+	}
+}
+
+// logToFile writes content to a log file if debug mode is enabled
+func (l debugLogger) logToFile(filename, content string) error {
+	if !l.debugMode {
+		return nil // Silent in production
+	}
+
+	// Create log directory if it doesn't exist
+	if err := os.MkdirAll(l.logDir, 0755); err != nil {
+		return fmt.Errorf("failed to create log directory: %w", err)
+	}
+
+	logPath := filepath.Join(l.logDir, filename)
+
+	// Append timestamp
+	timestamp := time.Now().Format("2006-01-02 15:04:05")
+	logContent := fmt.Sprintf("=== %s ===\n%s\n\n", timestamp, content)
+
+	// Append to file
+	f, err := os.OpenFile(logPath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open log file: %w", err)
+	}
+	defer f.Close()
+
+	if _, err := f.WriteString(logContent); err != nil {
+		return fmt.Errorf("failed to write to log file: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,403 @@
+package llm
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// OpenAIConfig holds the settings for an OpenAI-compatible local endpoint
+type OpenAIConfig struct {
+	BaseURL string // e.g. http://localhost:11434/v1 (Ollama) or http://localhost:1234/v1 (LM Studio)
+	Model   string // Model name as known to the server, e.g. qwen2.5-coder:7b
+	APIKey  string // Optional; most local servers ignore it
+}
+
+// OpenAIProvider implements the Provider interface against an OpenAI-style
+// /v1/chat/completions endpoint, using native tools and tool_calls
+type OpenAIProvider struct {
+	endpoint string
+	model    string
+	apiKey   string
+	client   *http.Client
+	debugLogger
+}
+
+// oaiMessage is a chat message in the OpenAI wire format
+type oaiMessage struct {
+	Role       string        `json:"role"`
+	Content    string        `json:"content"`
+	Name       string        `json:"name,omitempty"`
+	ToolCalls  []oaiToolCall `json:"tool_calls,omitempty"`
+	ToolCallID string        `json:"tool_call_id,omitempty"`
+}
+
+// oaiToolCall is a tool invocation in the OpenAI wire format.
+// Index is only set on streamed deltas.
+type oaiToolCall struct {
+	Index    int    `json:"index,omitempty"`
+	ID       string `json:"id,omitempty"`
+	Type     string `json:"type,omitempty"`
+	Function struct {
+		Name      string `json:"name,omitempty"`
+		Arguments string `json:"arguments"`
+	} `json:"function"`
+}
+
+// oaiTool is a tool definition in the OpenAI wire format
+type oaiTool struct {
+	Type     string `json:"type"`
+	Function Tool   `json:"function"`
+}
+
+// oaiRequest is the body of a /chat/completions request
+type oaiRequest struct {
+	Model         string       `json:"model"`
+	Messages      []oaiMessage `json:"messages"`
+	Tools         []oaiTool    `json:"tools,omitempty"`
+	Stream        bool         `json:"stream"`
+	StreamOptions *oaiStream   `json:"stream_options,omitempty"`
+	Temperature   *float64     `json:"temperature,omitempty"`
+	TopP          *float64     `json:"top_p,omitempty"`
+	MaxTokens     *int         `json:"max_tokens,omitempty"`
+	Seed          *int         `json:"seed,omitempty"`
+	Stop          []string     `json:"stop,omitempty"`
+
+	// Not part of the OpenAI API, but accepted by Ollama, vLLM and llama.cpp
+	TopK          *int     `json:"top_k,omitempty"`
+	MinP          *float64 `json:"min_p,omitempty"`
+	RepeatPenalty *float64 `json:"repeat_penalty,omitempty"`
+}
+
+// oaiStream asks for the token usage as a final chunk of a streamed response
+type oaiStream struct {
+	IncludeUsage bool `json:"include_usage"`
+}
+
+// oaiResponse is a /chat/completions response or one streamed chunk
+type oaiResponse struct {
+	Choices []struct {
+		Message      oaiMessage `json:"message"`
+		Delta        oaiMessage `json:"delta"`
+		FinishReason string     `json:"finish_reason"`
+	} `json:"choices"`
+	Usage *Usage `json:"usage"` // Only on the final chunk when streaming, if at all
+}
+
+// NewOpenAIProvider creates a provider for an OpenAI-compatible local server
+func NewOpenAIProvider(cfg OpenAIConfig) (*OpenAIProvider, error) {
+	if cfg.BaseURL == "" {
+		return nil, fmt.Errorf("base URL is required")
+	}
+	if cfg.Model == "" {
+		return nil, fmt.Errorf("model name is required")
+	}
+
+	if _, err := url.Parse(cfg.BaseURL); err != nil {
+		return nil, fmt.Errorf("invalid base URL %q: %w", cfg.BaseURL, err)
+	}
+
+	// Accept both http://host:port and http://host:port/v1
+	base := strings.TrimSuffix(cfg.BaseURL, "/")
+	if !strings.HasSuffix(base, "/v1") {
+		base += "/v1"
+	}
+
+	return &OpenAIProvider{
+		endpoint:    base + "/chat/completions",
+		model:       cfg.Model,
+		apiKey:      cfg.APIKey,
+		client:      &http.Client{},
+		debugLogger: newDebugLogger(),
+	}, nil
+}
+
+// Chat implements the Provider interface
+func (p *OpenAIProvider) Chat(ctx context.Context, messages []Message, tools []Tool, opts GenerationOptions) (*Response, error) {
+	resp, err := p.post(ctx, p.newRequest(messages, tools, opts, false))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var result oaiResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	if len(result.Choices) == 0 {
+		return nil, fmt.Errorf("response contained no choices")
+	}
+
+	choice := result.Choices[0]
+	response := &Response{
+		Content:      strings.TrimSpace(choice.Message.Content),
+		ToolCalls:    fromOAIToolCalls(choice.Message.ToolCalls),
+		FinishReason: choice.FinishReason,
+	}
+	if result.Usage != nil {
+		response.Usage = *result.Usage
+	}
+
+	// Models without native tool support fall back to our JSON text format
+	if len(response.ToolCalls) == 0 {
+		response.ToolCalls = ParseToolCalls(response.Content)
+	}
+
+	if len(response.ToolCalls) > 0 {
+		response.FinishReason = "tool_calls"
+	} else if response.FinishReason == "" {
+		response.FinishReason = "stop"
+	}
+
+	return response, nil
+}
+
+// ChatStream implements streaming chat over server-sent events
+func (p *OpenAIProvider) ChatStream(ctx context.Context, messages []Message, tools []Tool, opts GenerationOptions) (<-chan StreamChunk, error) {
+	resp, err := p.post(ctx, p.newRequest(messages, tools, opts, true))
+	if err != nil {
+		return nil, err
+	}
+
+	ch := make(chan StreamChunk, 10)
+
+	go func() {
+		defer close(ch)
+		defer resp.Body.Close()
+
+		var fullResponse strings.Builder
+		partial := map[int]*oaiToolCall{} // Tool calls arrive in fragments keyed by index
+		reason := ""
+		var usage *Usage
+
+		scanner := bufio.NewScanner(resp.Body)
+		scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+		for scanner.Scan() {
+			line := scanner.Text()
+			if !strings.HasPrefix(line, "data: ") {
+				continue
+			}
+
+			payload := strings.TrimPrefix(line, "data: ")
+			if payload == "[DONE]" {
+				break
+			}
+
+			var event oaiResponse
+			if err := json.Unmarshal([]byte(payload), &event); err != nil {
+				ch <- StreamChunk{Error: fmt.Errorf("malformed stream event: %w", err)}
+				return
+			}
+
+			if event.Usage != nil {
+				usage = event.Usage
+			}
+
+			for _, choice := range event.Choices {
+				if choice.Delta.Content != "" {
+					fullResponse.WriteString(choice.Delta.Content)
+					ch <- StreamChunk{Content: choice.Delta.Content}
+				}
+
+				for _, delta := range choice.Delta.ToolCalls {
+					tc, ok := partial[delta.Index]
+					if !ok {
+						tc = &oaiToolCall{Index: delta.Index}
+						partial[delta.Index] = tc
+					}
+					if delta.ID != "" {
+						tc.ID = delta.ID
+					}
+					if delta.Function.Name != "" {
+						tc.Function.Name = delta.Function.Name
+					}
+					tc.Function.Arguments += delta.Function.Arguments
+				}
+
+				if choice.FinishReason != "" {
+					reason = choice.FinishReason
+				}
+			}
+		}
+
+		if err := scanner.Err(); err != nil {
+			if ctx.Err() != nil {
+				err = ctx.Err()
+			}
+			ch <- StreamChunk{Error: fmt.Errorf("stream read failed: %w", err)}
+			return
+		}
+
+		// Reassemble tool calls in index order
+		indexes := make([]int, 0, len(partial))
+		for i := range partial {
+			indexes = append(indexes, i)
+		}
+		sort.Ints(indexes)
+
+		assembled := make([]oaiToolCall, 0, len(indexes))
+		for _, i := range indexes {
+			assembled = append(assembled, *partial[i])
+		}
+
+		toolCalls := fromOAIToolCalls(assembled)
+		if len(toolCalls) == 0 {
+			toolCalls = ParseToolCalls(fullResponse.String())
+		}
+
+		if len(toolCalls) > 0 {
+			for _, tc := range toolCalls {
+				ch <- StreamChunk{ToolCall: &tc, FinishReason: "tool_calls"}
+			}
+			ch <- StreamChunk{FinishReason: "tool_calls", Usage: usage}
+			return
+		}
+
+		if reason == "" {
+			reason = "stop"
+		}
+		ch <- StreamChunk{FinishReason: reason, Usage: usage}
+	}()
+
+	return ch, nil
+}
+
+// Name returns the provider name
+func (p *OpenAIProvider) Name() string {
+	host := p.endpoint
+	if u, err := url.Parse(p.endpoint); err == nil {
+		host = u.Host
+	}
+	return fmt.Sprintf("%s (%s)", p.model, host)
+}
+
+// newRequest converts messages, tools and options into an OpenAI request body.
+// The server applies the model's chat template, so only the configured stops are sent.
+func (p *OpenAIProvider) newRequest(messages []Message, tools []Tool, opts GenerationOptions, stream bool) oaiRequest {
+	opts = DefaultGenerationOptions().Merge(opts)
+
+	req := oaiRequest{
+		Model:         p.model,
+		Messages:      make([]oaiMessage, 0, len(messages)),
+		Stream:        stream,
+		Temperature:   opts.Temperature,
+		TopP:          opts.TopP,
+		MaxTokens:     opts.MaxTokens,
+		Seed:          opts.Seed,
+		Stop:          opts.Stop,
+		TopK:          opts.TopK,
+		MinP:          opts.MinP,
+		RepeatPenalty: opts.RepeatPenalty,
+	}
+	if stream {
+		req.StreamOptions = &oaiStream{IncludeUsage: true}
+	}
+
+	for _, msg := range messages {
+		out := oaiMessage{
+			Role:       msg.Role,
+			Content:    msg.Content,
+			Name:       msg.Name,
+			ToolCallID: msg.ToolCallID,
+		}
+		for _, tc := range msg.ToolCalls {
+			out.ToolCalls = append(out.ToolCalls, toOAIToolCall(tc))
+		}
+		req.Messages = append(req.Messages, out)
+	}
+
+	for _, tool := range tools {
+		req.Tools = append(req.Tools, oaiTool{Type: "function", Function: tool})
+	}
+
+	return req
+}
+
+// post sends a chat completion request and checks the HTTP status
+func (p *OpenAIProvider) post(ctx context.Context, body oaiRequest) (*http.Response, error) {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode request: %w", err)
+	}
+
+	p.logToFile("openai_request.json", string(data))
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.endpoint, bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if p.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+p.apiKey)
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, fmt.Errorf("generation stopped: %w", ctx.Err())
+		}
+		return nil, fmt.Errorf("request to %s failed: %w", p.endpoint, err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return nil, fmt.Errorf("%s returned %s: %s", p.endpoint, resp.Status, strings.TrimSpace(string(msg)))
+	}
+
+	return resp, nil
+}
+
+// toOAIToolCall converts a ToolCall into the OpenAI wire format
+func toOAIToolCall(tc ToolCall) oaiToolCall {
+	out := oaiToolCall{ID: tc.ID, Type: "function"}
+	out.Function.Name = tc.Name
+
+	args, err := json.Marshal(tc.Arguments)
+	if err != nil || tc.Arguments == nil {
+		args = []byte("{}")
+	}
+	out.Function.Arguments = string(args)
+
+	return out
+}
+
+// fromOAIToolCalls converts OpenAI tool calls, decoding their JSON-encoded arguments
+func fromOAIToolCalls(calls []oaiToolCall) []ToolCall {
+	var toolCalls []ToolCall
+
+	for i, call := range calls {
+		if call.Function.Name == "" {
+			continue
+		}
+
+		// Arguments that fail to decode are left empty; argument checks report them
+		var args map[string]interface{}
+		if strings.TrimSpace(call.Function.Arguments) != "" {
+			json.Unmarshal([]byte(call.Function.Arguments), &args)
+		}
+
+		id := call.ID
+		if id == "" {
+			id = fmt.Sprintf("call_%d_%d", time.Now().UnixNano(), i)
+		}
+
+		toolCalls = append(toolCalls, ToolCall{
+			ID:        id,
+			Name:      call.Function.Name,
+			Arguments: args,
+		})
+	}
+
+	return toolCalls
+}
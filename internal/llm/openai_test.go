@@ -0,0 +1,166 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// newFakeOpenAIServer returns an httptest stand-in for an OpenAI-compatible endpoint.
+// Non-streaming requests get body; streaming requests get each of events as an SSE line.
+func newFakeOpenAIServer(t *testing.T, body string, events []string) (*httptest.Server, *oaiRequest) {
+	t.Helper()
+
+	var last oaiRequest
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/chat/completions" {
+			http.NotFound(w, r)
+			return
+		}
+		if err := json.NewDecoder(r.Body).Decode(&last); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		if !last.Stream {
+			w.Write([]byte(body))
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, event := range events {
+			fmt.Fprintf(w, "data: %s\n\n", event)
+		}
+		fmt.Fprint(w, "data: [DONE]\n\n")
+	}))
+	t.Cleanup(srv.Close)
+
+	return srv, &last
+}
+
+func TestOpenAIProviderChatToolCalls(t *testing.T) {
+	body := `{"choices":[{"message":{"role":"assistant","content":"","tool_calls":[
+		{"id":"call_1","type":"function","function":{"name":"read_file","arguments":"{\"path\":\"main.go\"}"}}
+	]},"finish_reason":"tool_calls"}]}`
+	srv, last := newFakeOpenAIServer(t, body, nil)
+
+	p, err := NewOpenAIProvider(OpenAIConfig{BaseURL: srv.URL, Model: "qwen2.5-coder:7b"})
+	if err != nil {
+		t.Fatalf("NewOpenAIProvider() failed: %v", err)
+	}
+
+	tools := []Tool{{Name: "read_file", Description: "Read a file", Parameters: map[string]interface{}{"type": "object"}}}
+	messages := []Message{
+		{Role: "user", Content: "show main"},
+		{Role: "assistant", ToolCalls: []ToolCall{{ID: "call_0", Name: "list_files", Arguments: map[string]interface{}{"path": "."}}}},
+		{Role: "tool", Name: "list_files", ToolCallID: "call_0", Content: "[]"},
+	}
+
+	resp, err := p.Chat(context.Background(), messages, tools, GenerationOptions{})
+	if err != nil {
+		t.Fatalf("Chat() failed: %v", err)
+	}
+
+	if resp.FinishReason != "tool_calls" {
+		t.Errorf("Expected finish reason tool_calls, got %q", resp.FinishReason)
+	}
+	if len(resp.ToolCalls) != 1 {
+		t.Fatalf("Expected 1 tool call, got %d", len(resp.ToolCalls))
+	}
+	if resp.ToolCalls[0].ID != "call_1" || resp.ToolCalls[0].Arguments["path"] != "main.go" {
+		t.Errorf("Unexpected tool call: %+v", resp.ToolCalls[0])
+	}
+
+	// The request must carry the model, native tools and the prior tool exchange
+	if last.Model != "qwen2.5-coder:7b" {
+		t.Errorf("Expected model qwen2.5-coder:7b, got %q", last.Model)
+	}
+	if len(last.Tools) != 1 || last.Tools[0].Type != "function" || last.Tools[0].Function.Name != "read_file" {
+		t.Errorf("Unexpected tools in request: %+v", last.Tools)
+	}
+	if got := last.Messages[1].ToolCalls; len(got) != 1 || got[0].Function.Arguments != `{"path":"."}` {
+		t.Errorf("Assistant tool calls not encoded: %+v", got)
+	}
+	if last.Messages[2].ToolCallID != "call_0" {
+		t.Errorf("Tool message missing tool_call_id: %+v", last.Messages[2])
+	}
+}
+
+func TestOpenAIProviderChatTextFallback(t *testing.T) {
+	body := `{"choices":[{"message":{"role":"assistant","content":"{\"tool\": \"git_status\", \"args\": {}}"},"finish_reason":"stop"}]}`
+	srv, _ := newFakeOpenAIServer(t, body, nil)
+
+	p, err := NewOpenAIProvider(OpenAIConfig{BaseURL: srv.URL + "/v1/", Model: "local"})
+	if err != nil {
+		t.Fatalf("NewOpenAIProvider() failed: %v", err)
+	}
+
+	resp, err := p.Chat(context.Background(), []Message{{Role: "user", Content: "status?"}}, nil, GenerationOptions{})
+	if err != nil {
+		t.Fatalf("Chat() failed: %v", err)
+	}
+
+	if len(resp.ToolCalls) != 1 || resp.ToolCalls[0].Name != "git_status" {
+		t.Errorf("Expected git_status parsed from text, got %+v", resp.ToolCalls)
+	}
+}
+
+func TestOpenAIProviderChatStream(t *testing.T) {
+	events := []string{
+		`{"choices":[{"delta":{"role":"assistant","content":"Let me "}}]}`,
+		`{"choices":[{"delta":{"content":"check."}}]}`,
+		`{"choices":[{"delta":{"tool_calls":[{"index":0,"id":"call_9","type":"function","function":{"name":"read_file","arguments":""}}]}}]}`,
+		`{"choices":[{"delta":{"tool_calls":[{"index":0,"function":{"arguments":"{\"path\":"}}]}}]}`,
+		`{"choices":[{"delta":{"tool_calls":[{"index":0,"function":{"arguments":"\"go.mod\"}"}}]}}]}`,
+		`{"choices":[{"delta":{},"finish_reason":"tool_calls"}]}`,
+		`{"choices":[],"usage":{"prompt_tokens":42,"completion_tokens":7}}`,
+	}
+	srv, last := newFakeOpenAIServer(t, "", events)
+
+	p, err := NewOpenAIProvider(OpenAIConfig{BaseURL: srv.URL, Model: "local"})
+	if err != nil {
+		t.Fatalf("NewOpenAIProvider() failed: %v", err)
+	}
+
+	ch, err := p.ChatStream(context.Background(), []Message{{Role: "user", Content: "read go.mod"}}, nil, GenerationOptions{})
+	if err != nil {
+		t.Fatalf("ChatStream() failed: %v", err)
+	}
+
+	var text strings.Builder
+	var toolCalls []ToolCall
+	var usage *Usage
+	for chunk := range ch {
+		if chunk.Error != nil {
+			t.Fatalf("Stream error: %v", chunk.Error)
+		}
+		text.WriteString(chunk.Content)
+		if chunk.ToolCall != nil {
+			toolCalls = append(toolCalls, *chunk.ToolCall)
+		}
+		if chunk.Usage != nil {
+			usage = chunk.Usage
+		}
+	}
+
+	if last.StreamOptions == nil || !last.StreamOptions.IncludeUsage {
+		t.Error("Expected the request to ask for usage in the stream")
+	}
+	if usage == nil || usage.PromptTokens != 42 || usage.CompletionTokens != 7 {
+		t.Errorf("Expected usage from the final chunk, got %+v", usage)
+	}
+
+	if text.String() != "Let me check." {
+		t.Errorf("Expected streamed text %q, got %q", "Let me check.", text.String())
+	}
+	if len(toolCalls) != 1 {
+		t.Fatalf("Expected 1 tool call, got %d", len(toolCalls))
+	}
+	if toolCalls[0].ID != "call_9" || toolCalls[0].Arguments["path"] != "go.mod" {
+		t.Errorf("Tool call not reassembled from deltas: %+v", toolCalls[0])
+	}
+}
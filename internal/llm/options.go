@@ -0,0 +1,113 @@
+package llm
+
+import "strconv"
+
+// GenerationOptions controls sampling and output length for one call.
+// Nil fields are unset, so option sets can be layered with Merge.
+type GenerationOptions struct {
+	Temperature   *float64 `json:"temperature,omitempty"`
+	TopK          *int     `json:"top_k,omitempty"`
+	TopP          *float64 `json:"top_p,omitempty"`
+	MinP          *float64 `json:"min_p,omitempty"`
+	RepeatPenalty *float64 `json:"repeat_penalty,omitempty"`
+	MaxTokens     *int     `json:"max_tokens,omitempty"`
+	Threads       *int     `json:"threads,omitempty"`
+	Seed          *int     `json:"seed,omitempty"` // Fixed seed to reproduce a generation
+	Stop          []string `json:"stop,omitempty"` // Extra stop sequences, on top of the chat template's
+
+	// StopAfterToolCall ends a streamed reply at its first tool call, so the tool runs right away
+	StopAfterToolCall *bool `json:"stop_after_tool_call,omitempty"`
+}
+
+// DefaultGenerationOptions returns the settings used when nothing is configured
+func DefaultGenerationOptions() GenerationOptions {
+	temperature, topK, topP := 0.1, 40, 0.9
+	maxTokens, threads := 2048, 4
+
+	return GenerationOptions{
+		Temperature: &temperature,
+		TopK:        &topK,
+		TopP:        &topP,
+		MaxTokens:   &maxTokens,
+		Threads:     &threads,
+	}
+}
+
+// Merge returns o with every field that is set in override replaced
+func (o GenerationOptions) Merge(override GenerationOptions) GenerationOptions {
+	if override.Temperature != nil {
+		o.Temperature = override.Temperature
+	}
+	if override.TopK != nil {
+		o.TopK = override.TopK
+	}
+	if override.TopP != nil {
+		o.TopP = override.TopP
+	}
+	if override.MinP != nil {
+		o.MinP = override.MinP
+	}
+	if override.RepeatPenalty != nil {
+		o.RepeatPenalty = override.RepeatPenalty
+	}
+	if override.MaxTokens != nil {
+		o.MaxTokens = override.MaxTokens
+	}
+	if override.Threads != nil {
+		o.Threads = override.Threads
+	}
+	if override.Seed != nil {
+		o.Seed = override.Seed
+	}
+	if override.Stop != nil {
+		o.Stop = override.Stop
+	}
+	if override.StopAfterToolCall != nil {
+		o.StopAfterToolCall = override.StopAfterToolCall
+	}
+	return o
+}
+
+// LlamaCLIArgs returns the llama-cli flags for the options that are set.
+// Stop sequences are not included; llama-cli output is cut by the caller.
+func (o GenerationOptions) LlamaCLIArgs() []string {
+	var args []string
+
+	if o.MaxTokens != nil {
+		args = append(args, "--n-predict", strconv.Itoa(*o.MaxTokens))
+	}
+	if o.Temperature != nil {
+		args = append(args, "--temp", strconv.FormatFloat(*o.Temperature, 'g', -1, 64))
+	}
+	if o.TopK != nil {
+		args = append(args, "--top-k", strconv.Itoa(*o.TopK))
+	}
+	if o.TopP != nil {
+		args = append(args, "--top-p", strconv.FormatFloat(*o.TopP, 'g', -1, 64))
+	}
+	if o.MinP != nil {
+		args = append(args, "--min-p", strconv.FormatFloat(*o.MinP, 'g', -1, 64))
+	}
+	if o.RepeatPenalty != nil {
+		args = append(args, "--repeat-penalty", strconv.FormatFloat(*o.RepeatPenalty, 'g', -1, 64))
+	}
+	if o.Threads != nil {
+		args = append(args, "--threads", strconv.Itoa(*o.Threads))
+	}
+	if o.Seed != nil {
+		args = append(args, "--seed", strconv.Itoa(*o.Seed))
+	}
+
+	return args
+}
+
+// stopAfterToolCall reports whether a stream should end at its first tool call
+func (o GenerationOptions) stopAfterToolCall() bool {
+	return o.StopAfterToolCall != nil && *o.StopAfterToolCall
+}
+
+// stopSequences combines the template's stop sequences with the configured ones
+func stopSequences(tmpl *ChatTemplate, opts GenerationOptions) []string {
+	stops := append([]string(nil), tmpl.Stop...)
+	return append(stops, opts.Stop...)
+}
@@ -0,0 +1,59 @@
+package llm
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestGenerationOptionsMerge(t *testing.T) {
+	global := GenerationOptions{Temperature: float64Ptr(0.3), Seed: intPtr(1)}
+	perModel := GenerationOptions{TopK: intPtr(20), Stop: []string{"<END>"}}
+	perRole := GenerationOptions{Temperature: float64Ptr(0), Seed: intPtr(42)}
+
+	opts := DefaultGenerationOptions().Merge(global).Merge(perModel).Merge(perRole)
+
+	if *opts.Temperature != 0 {
+		t.Errorf("Expected role temperature 0 to win, got %v", *opts.Temperature)
+	}
+	if *opts.Seed != 42 {
+		t.Errorf("Expected role seed 42, got %d", *opts.Seed)
+	}
+	if *opts.TopK != 20 {
+		t.Errorf("Expected model top_k 20, got %d", *opts.TopK)
+	}
+	if *opts.TopP != 0.9 || *opts.MaxTokens != 2048 {
+		t.Errorf("Expected defaults for unset fields, got top_p=%v max_tokens=%d", *opts.TopP, *opts.MaxTokens)
+	}
+	if !reflect.DeepEqual(opts.Stop, []string{"<END>"}) {
+		t.Errorf("Expected model stop sequences, got %v", opts.Stop)
+	}
+	if opts.MinP != nil {
+		t.Errorf("Expected min_p to stay unset, got %v", *opts.MinP)
+	}
+}
+
+func TestGenerationOptionsLlamaCLIArgs(t *testing.T) {
+	opts := GenerationOptions{
+		Temperature:   float64Ptr(0.2),
+		MinP:          float64Ptr(0.05),
+		RepeatPenalty: float64Ptr(1.1),
+		MaxTokens:     intPtr(256),
+		Seed:          intPtr(7),
+		Stop:          []string{"\n\n"},
+	}
+
+	expected := []string{
+		"--n-predict", "256",
+		"--temp", "0.2",
+		"--min-p", "0.05",
+		"--repeat-penalty", "1.1",
+		"--seed", "7",
+	}
+	if got := opts.LlamaCLIArgs(); !reflect.DeepEqual(got, expected) {
+		t.Errorf("Expected %v, got %v", expected, got)
+	}
+}
+
+func float64Ptr(v float64) *float64 { return &v }
+
+func intPtr(v int) *int { return &v }
@@ -0,0 +1,51 @@
+package llm
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// buildPrompt renders the conversation in the model's chat template
+// Shared by every provider that talks to a raw completion backend
+func buildPrompt(tmpl *ChatTemplate, messages []Message, tools []Tool) string {
+	return tmpl.Format(toolPreamble(tools), messages)
+}
+
+// toolPreamble describes the available tools and the rules for using them
+func toolPreamble(tools []Tool) string {
+	var prompt strings.Builder
+
+	prompt.WriteString("You are a coding assistant. You can use tools by outputting JSON.\n\n")
+
+	if len(tools) > 0 {
+		prompt.WriteString("Available tools:\n")
+		for _, tool := range tools {
+			prompt.WriteString(fmt.Sprintf("- %s: %s\n", tool.Name, tool.Description))
+		}
+		prompt.WriteString("\nTo use a tool, output JSON in this format:\n")
+		prompt.WriteString(`{"tool": "tool_name", "args": {"param": "value"}}` + "\n\n")
+	}
+
+	prompt.WriteString("CRITICAL RULES:\n")
+	prompt.WriteString("1. NEVER write files directly\n")
+	prompt.WriteString("2. ONLY propose unified diffs\n")
+	prompt.WriteString("3. Explain intent BEFORE proposing changes\n")
+	prompt.WriteString("4. Ask clarifying questions if ambiguous\n")
+	prompt.WriteString("5. One logical change per patch")
+
+	return prompt.String()
+}
+
+// formatToolCalls renders tool calls in the {"tool": ..., "args": ...} text format
+func formatToolCalls(toolCalls []ToolCall) string {
+	lines := make([]string, 0, len(toolCalls))
+	for _, tc := range toolCalls {
+		data, err := json.Marshal(map[string]interface{}{"tool": tc.Name, "args": tc.Arguments})
+		if err != nil {
+			continue
+		}
+		lines = append(lines, string(data))
+	}
+	return strings.Join(lines, "\n")
+}
@@ -1,12 +1,17 @@
 package llm
 
-import "fmt"
+import (
+	"context"
+	"fmt"
+)
 
 // Message represents a chat message
 type Message struct {
-	Role    string `json:"role"`           // "system", "user", "assistant", "tool"
-	Content string `json:"content"`        // Message content
-	Name    string `json:"name,omitempty"` // For tool responses
+	Role       string     `json:"role"`                   // "system", "user", "assistant", "tool"
+	Content    string     `json:"content"`                // Message content
+	Name       string     `json:"name,omitempty"`         // For tool responses
+	ToolCalls  []ToolCall `json:"tool_calls,omitempty"`   // Tool invocations made by an assistant message
+	ToolCallID string     `json:"tool_call_id,omitempty"` // For tool responses: the call being answered
 }
 
 // Tool represents a tool that the LLM can call
@@ -28,6 +33,7 @@ type Response struct {
 	Content      string     `json:"content"`       // Text response
 	ToolCalls    []ToolCall `json:"tool_calls"`    // Requested tool invocations
 	FinishReason string     `json:"finish_reason"` // "stop", "tool_calls", "length", etc.
+	Usage        Usage      `json:"usage"`         // Prompt and completion token counts
 }
 
 // StreamChunk represents a chunk of streaming response
@@ -35,18 +41,22 @@ type StreamChunk struct {
 	Content      string    // Text content delta
 	ToolCall     *ToolCall // Tool call if present
 	FinishReason string    // Finish reason if stream is ending
+	Usage        *Usage    // Token counts, set on the final chunk when known
 	Error        error     // Error if something went wrong
 }
 
 // Provider is the interface that all LLM providers must implement
-// Currently only local llama.cpp-based provider is supported
+// Implementations: llama-cli, llama-server and OpenAI-compatible local endpoints
 type Provider interface {
 	// Chat sends messages to the LLM and receives a response
 	// Tools are optional - pass nil if not using tools
-	Chat(messages []Message, tools []Tool) (*Response, error)
+	// Unset fields in opts fall back to DefaultGenerationOptions
+	// Cancelling ctx stops generation and returns ctx's error
+	Chat(ctx context.Context, messages []Message, tools []Tool, opts GenerationOptions) (*Response, error)
 
 	// ChatStream sends messages and returns a streaming response channel
-	ChatStream(messages []Message, tools []Tool) (<-chan StreamChunk, error)
+	// Cancelling ctx stops generation; the channel then yields a chunk with the error and closes
+	ChatStream(ctx context.Context, messages []Message, tools []Tool, opts GenerationOptions) (<-chan StreamChunk, error)
 
 	// Name returns the provider name (for logging)
 	Name() string
@@ -0,0 +1,545 @@
+package llm
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// DefaultServerURL is where PlayGround starts llama-server when no URL is configured
+const DefaultServerURL = "http://127.0.0.1:8089"
+
+// ServerConfig holds the settings for a llama-server backed provider
+type ServerConfig struct {
+	ModelPath      string        // GGUF model to load when spawning llama-server
+	URL            string        // Server address to attach to (or spawn on)
+	Binary         string        // llama-server executable (default: llama-server)
+	ContextSize    int           // Context window passed to a spawned server
+	Threads        int           // CPU threads for a spawned server (default: 4)
+	StartupTimeout time.Duration // How long to wait for the model to load
+}
+
+// ServerProvider implements the Provider interface using a long-lived llama-server process.
+// The model is loaded once and every request goes over the local HTTP API.
+type ServerProvider struct {
+	baseURL     string
+	modelPath   string
+	contextSize int
+	template    *ChatTemplate
+	client      *http.Client
+	cmd         *exec.Cmd     // nil when attached to an already running server
+	exited      chan struct{} // closed when a spawned server process exits
+	stderr      bytes.Buffer
+	debugLogger
+}
+
+// completionRequest is the body of a llama-server /completion request.
+// Unset sampling fields are omitted so the server's defaults apply.
+type completionRequest struct {
+	Prompt        string   `json:"prompt"`
+	NPredict      *int     `json:"n_predict,omitempty"`
+	Temperature   *float64 `json:"temperature,omitempty"`
+	TopK          *int     `json:"top_k,omitempty"`
+	TopP          *float64 `json:"top_p,omitempty"`
+	MinP          *float64 `json:"min_p,omitempty"`
+	RepeatPenalty *float64 `json:"repeat_penalty,omitempty"`
+	Seed          *int     `json:"seed,omitempty"`
+	Stop          []string `json:"stop,omitempty"`
+	Grammar       string   `json:"grammar,omitempty"`
+	CachePrompt   bool     `json:"cache_prompt"` // Reuse the slot's KV state for the shared prompt prefix
+	Stream        bool     `json:"stream"`
+}
+
+// completionResponse is a llama-server /completion response (or one streamed event)
+type completionResponse struct {
+	Content         string `json:"content"`
+	Stop            bool   `json:"stop"`
+	StoppedLimit    bool   `json:"stopped_limit"`
+	TokensPredicted int    `json:"tokens_predicted"`
+	TokensEvaluated int    `json:"tokens_evaluated"`
+	Timings         *struct {
+		PromptN int `json:"prompt_n"` // Prompt tokens actually processed for this request
+	} `json:"timings,omitempty"`
+}
+
+// NewServerProvider attaches to a running llama-server at cfg.URL, or starts one
+// with cfg.ModelPath if nothing is listening there yet
+func NewServerProvider(cfg ServerConfig) (*ServerProvider, error) {
+	if cfg.URL == "" {
+		cfg.URL = DefaultServerURL
+	}
+	if cfg.Binary == "" {
+		cfg.Binary = "llama-server"
+	}
+	if cfg.ContextSize == 0 {
+		cfg.ContextSize = 4096
+	}
+	if cfg.Threads == 0 {
+		cfg.Threads = 4
+	}
+	if cfg.StartupTimeout == 0 {
+		cfg.StartupTimeout = 120 * time.Second
+	}
+
+	p := &ServerProvider{
+		baseURL:     strings.TrimSuffix(cfg.URL, "/"),
+		modelPath:   cfg.ModelPath,
+		contextSize: cfg.ContextSize,
+		client:      &http.Client{},
+		debugLogger: newDebugLogger(),
+	}
+
+	// Attach if a server is already up
+	if p.healthy() {
+		p.loadProps()
+		return p, nil
+	}
+
+	if cfg.ModelPath == "" {
+		return nil, fmt.Errorf("no llama-server reachable at %s and no model path to start one", p.baseURL)
+	}
+
+	if err := p.start(cfg); err != nil {
+		return nil, err
+	}
+
+	p.template = TemplateForModel(cfg.ModelPath)
+	return p, nil
+}
+
+// loadProps reads the chat template and context size of an attached server from /props.
+// A known model path takes precedence for the template.
+func (p *ServerProvider) loadProps() {
+	p.template = templates[TemplatePlain]
+	if p.modelPath != "" {
+		p.template = TemplateForModel(p.modelPath)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.baseURL+"/props", nil)
+	if err != nil {
+		return
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return
+	}
+	defer resp.Body.Close()
+
+	var props struct {
+		ChatTemplate string `json:"chat_template"`
+		Settings     struct {
+			NCtx int `json:"n_ctx"`
+		} `json:"default_generation_settings"`
+	}
+	if resp.StatusCode != http.StatusOK || json.NewDecoder(resp.Body).Decode(&props) != nil {
+		return
+	}
+
+	if props.Settings.NCtx > 0 {
+		p.contextSize = props.Settings.NCtx
+	}
+	if p.modelPath == "" {
+		if tmpl := detectTemplate(props.ChatTemplate); tmpl != nil {
+			p.template = tmpl
+		}
+	}
+}
+
+// start spawns llama-server and waits until the model has finished loading
+func (p *ServerProvider) start(cfg ServerConfig) error {
+	u, err := url.Parse(p.baseURL)
+	if err != nil {
+		return fmt.Errorf("invalid server URL %q: %w", p.baseURL, err)
+	}
+
+	host, port, err := net.SplitHostPort(u.Host)
+	if err != nil {
+		return fmt.Errorf("server URL must include a port: %s", p.baseURL)
+	}
+
+	cmd := exec.Command(cfg.Binary,
+		"--model", cfg.ModelPath,
+		"--host", host,
+		"--port", port,
+		"--ctx-size", fmt.Sprintf("%d", cfg.ContextSize),
+		"--threads", fmt.Sprintf("%d", cfg.Threads),
+	)
+	cmd.Stderr = &p.stderr
+
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("failed to start %s: %w", cfg.Binary, err)
+	}
+
+	p.cmd = cmd
+	p.exited = make(chan struct{})
+	go func() {
+		cmd.Wait()
+		close(p.exited)
+	}()
+
+	// Poll /health until the model is loaded
+	deadline := time.Now().Add(cfg.StartupTimeout)
+	for time.Now().Before(deadline) {
+		select {
+		case <-p.exited:
+			p.logToFile("llama-server.log", p.stderr.String())
+			return fmt.Errorf("llama-server exited during startup\nStderr: %s", tail(p.stderr.String(), 2000))
+		case <-time.After(250 * time.Millisecond):
+		}
+
+		if p.healthy() {
+			return nil
+		}
+	}
+
+	p.Close()
+	return fmt.Errorf("llama-server did not become ready within %s", cfg.StartupTimeout)
+}
+
+// healthy reports whether the server is up and has a model loaded
+func (p *ServerProvider) healthy() bool {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.baseURL+"/health", nil)
+	if err != nil {
+		return false
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return false
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+
+	// llama-server answers 503 while the model is still loading
+	return resp.StatusCode == http.StatusOK
+}
+
+// Chat implements the Provider interface
+func (p *ServerProvider) Chat(ctx context.Context, messages []Message, tools []Tool, opts GenerationOptions) (*Response, error) {
+	opts = DefaultGenerationOptions().Merge(opts)
+
+	prompt, promptTokens, err := p.fitPrompt(ctx, messages, tools, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := p.post(ctx, p.newRequest(prompt, tools, opts, false))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var result completionResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("failed to decode llama-server response: %w", err)
+	}
+	p.logPromptCache(result)
+
+	content := strings.TrimSpace(result.Content)
+
+	response := &Response{
+		Content:      content,
+		FinishReason: finishReason(result),
+		Usage:        result.usage(promptTokens),
+	}
+
+	// Try to extract tool calls from response
+	toolCalls := ParseToolCalls(content)
+	if len(toolCalls) > 0 {
+		response.ToolCalls = toolCalls
+		response.FinishReason = "tool_calls"
+	}
+
+	return response, nil
+}
+
+// ChatStream implements streaming chat using llama-server's server-sent events
+func (p *ServerProvider) ChatStream(ctx context.Context, messages []Message, tools []Tool, opts GenerationOptions) (<-chan StreamChunk, error) {
+	opts = DefaultGenerationOptions().Merge(opts)
+
+	prompt, promptTokens, err := p.fitPrompt(ctx, messages, tools, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := p.post(ctx, p.newRequest(prompt, tools, opts, true))
+	if err != nil {
+		return nil, err
+	}
+
+	ch := make(chan StreamChunk, 10)
+
+	go func() {
+		defer close(ch)
+		defer resp.Body.Close()
+
+		var final completionResponse
+		reason := "stop"
+		scanner := newToolCallScanner()
+		var content strings.Builder
+		calls := 0
+		stoppedAtCall := false
+
+		lines := bufio.NewScanner(resp.Body)
+		lines.Buffer(make([]byte, 64*1024), 1024*1024)
+
+		for lines.Scan() {
+			line := lines.Text()
+			if !strings.HasPrefix(line, "data: ") {
+				continue // Blank separators and comments
+			}
+
+			var event completionResponse
+			if err := json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &event); err != nil {
+				ch <- StreamChunk{Error: fmt.Errorf("malformed stream event: %w", err)}
+				return
+			}
+
+			// Tool call JSON becomes ToolCall chunks as each object closes
+			content.WriteString(event.Content)
+			display, toolCalls := scanner.push(event.Content)
+			if display != "" {
+				ch <- StreamChunk{Content: display}
+			}
+			for i := range toolCalls {
+				ch <- StreamChunk{ToolCall: &toolCalls[i], FinishReason: "tool_calls"}
+			}
+			calls += len(toolCalls)
+
+			if event.Stop {
+				final = event
+				reason = finishReason(event)
+				break
+			}
+			if len(toolCalls) > 0 && opts.stopAfterToolCall() {
+				// Closing the body makes llama-server stop generating
+				stoppedAtCall = true
+				break
+			}
+		}
+
+		if err := lines.Err(); err != nil {
+			if ctx.Err() != nil {
+				err = ctx.Err()
+			}
+			ch <- StreamChunk{Error: fmt.Errorf("stream read failed: %w", err)}
+			return
+		}
+
+		p.logPromptCache(final)
+
+		// Release anything held back that never became a tool call,
+		// unless generation was cut short at a call
+		if rest := scanner.flush(); rest != "" && !stoppedAtCall {
+			ch <- StreamChunk{Content: rest}
+		}
+
+		// Calls the scanner cannot spot as they stream, e.g. after prose on the same line
+		if calls == 0 {
+			for _, call := range ParseToolCalls(content.String()) {
+				ch <- StreamChunk{ToolCall: &call, FinishReason: "tool_calls"}
+				calls++
+			}
+		}
+
+		usage := final.usage(promptTokens)
+		if calls > 0 {
+			ch <- StreamChunk{FinishReason: "tool_calls", Usage: &usage}
+		} else {
+			ch <- StreamChunk{FinishReason: reason, Usage: &usage}
+		}
+	}()
+
+	return ch, nil
+}
+
+// Name returns the provider name
+func (p *ServerProvider) Name() string {
+	if p.modelPath == "" {
+		return fmt.Sprintf("llama-server at %s", p.baseURL)
+	}
+	return fmt.Sprintf("%s (llama-server)", filepath.Base(p.modelPath))
+}
+
+// Close stops the llama-server process if this provider started it.
+// Attached servers are left running.
+func (p *ServerProvider) Close() error {
+	if p.cmd == nil || p.cmd.Process == nil {
+		return nil
+	}
+
+	select {
+	case <-p.exited:
+		return nil // Already gone
+	default:
+	}
+
+	// Ask politely first, then force
+	if err := p.cmd.Process.Signal(os.Interrupt); err != nil {
+		p.cmd.Process.Kill()
+	}
+
+	select {
+	case <-p.exited:
+	case <-time.After(5 * time.Second):
+		p.cmd.Process.Kill()
+		<-p.exited
+	}
+
+	p.logToFile("llama-server.log", p.stderr.String())
+	return nil
+}
+
+// CountTokens implements Tokenizer using the server's /tokenize endpoint.
+// Servers without it fall back to an estimate.
+func (p *ServerProvider) CountTokens(ctx context.Context, text string) (int, error) {
+	data, err := json.Marshal(map[string]string{"content": text})
+	if err != nil {
+		return 0, fmt.Errorf("failed to encode tokenize request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.baseURL+"/tokenize", bytes.NewReader(data))
+	if err != nil {
+		return 0, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		if ctx.Err() != nil {
+			return 0, fmt.Errorf("generation stopped: %w", ctx.Err())
+		}
+		return estimateTokens(text), nil
+	}
+	defer resp.Body.Close()
+
+	var result struct {
+		Tokens []json.RawMessage `json:"tokens"`
+	}
+	if resp.StatusCode != http.StatusOK || json.NewDecoder(resp.Body).Decode(&result) != nil {
+		return estimateTokens(text), nil
+	}
+
+	return len(result.Tokens), nil
+}
+
+// fitPrompt renders the prompt, trimming history to fit the server's context window
+func (p *ServerProvider) fitPrompt(ctx context.Context, messages []Message, tools []Tool, opts GenerationOptions) (string, int, error) {
+	budget := Budget{ContextSize: p.contextSize, Reserve: replyReserve(opts), Tokenizer: p}
+
+	return budget.Fit(ctx, messages, func(m []Message) string {
+		return buildPrompt(p.template, m, tools)
+	})
+}
+
+// newRequest builds a completion request from the generation options.
+// Threads are fixed when the server starts and are not sent per request.
+// With tools, a grammar limits output to free text or valid tool calls.
+func (p *ServerProvider) newRequest(prompt string, tools []Tool, opts GenerationOptions, stream bool) completionRequest {
+	req := completionRequest{
+		Prompt:        prompt,
+		NPredict:      opts.MaxTokens,
+		Temperature:   opts.Temperature,
+		TopK:          opts.TopK,
+		TopP:          opts.TopP,
+		MinP:          opts.MinP,
+		RepeatPenalty: opts.RepeatPenalty,
+		Seed:          opts.Seed,
+		Stop:          stopSequences(p.template, opts),
+		CachePrompt:   true,
+		Stream:        stream,
+	}
+	if len(tools) > 0 {
+		req.Grammar = ToolGrammar(tools)
+	}
+	return req
+}
+
+// post sends a completion request and checks the HTTP status
+func (p *ServerProvider) post(ctx context.Context, body completionRequest) (*http.Response, error) {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode request: %w", err)
+	}
+
+	p.logToFile("server_prompt.txt", body.Prompt)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.baseURL+"/completion", bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, fmt.Errorf("generation stopped: %w", ctx.Err())
+		}
+		return nil, fmt.Errorf("llama-server request failed: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return nil, fmt.Errorf("llama-server returned %s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+
+	return resp, nil
+}
+
+// usage reports token counts, using the measured prompt size if the server sent none
+func (r completionResponse) usage(promptTokens int) Usage {
+	if r.TokensEvaluated > 0 {
+		promptTokens = r.TokensEvaluated
+	}
+	return Usage{PromptTokens: promptTokens, CompletionTokens: r.TokensPredicted}
+}
+
+// cacheStats reports how much of the prompt came from the slot's cache, if the server sent timings
+func (r completionResponse) cacheStats() (cacheStats, bool) {
+	if r.Timings == nil || r.TokensEvaluated == 0 {
+		return cacheStats{}, false
+	}
+	return cacheStats{reused: r.TokensEvaluated - r.Timings.PromptN, total: r.TokensEvaluated}, true
+}
+
+// logPromptCache records the prompt cache outcome of a completion in debug mode
+func (p *ServerProvider) logPromptCache(r completionResponse) {
+	if stats, ok := r.cacheStats(); ok {
+		p.logToFile("cache.log", fmt.Sprintf("llama-server: %s", stats))
+	}
+}
+
+// finishReason maps llama-server stop flags onto provider finish reasons
+func finishReason(r completionResponse) string {
+	if r.StoppedLimit {
+		return "length"
+	}
+	return "stop"
+}
+
+// tail returns at most the last n bytes of s
+func tail(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[len(s)-n:]
+}
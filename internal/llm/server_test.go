@@ -0,0 +1,328 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// newFakeLlamaServer returns an httptest stand-in for llama-server's HTTP API
+func newFakeLlamaServer(t *testing.T, content string) *httptest.Server {
+	t.Helper()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"status":"ok"}`))
+	})
+	mux.HandleFunc("/tokenize", func(w http.ResponseWriter, r *http.Request) {
+		// One token per word is close enough for a fake
+		var req struct {
+			Content string `json:"content"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		tokens := make([]int, len(strings.Fields(req.Content)))
+		json.NewEncoder(w).Encode(map[string]interface{}{"tokens": tokens})
+	})
+	mux.HandleFunc("/completion", func(w http.ResponseWriter, r *http.Request) {
+		var req completionRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if req.Prompt == "" {
+			http.Error(w, "empty prompt", http.StatusBadRequest)
+			return
+		}
+
+		if !req.Stream {
+			json.NewEncoder(w).Encode(completionResponse{Content: content, Stop: true, TokensPredicted: 5})
+			return
+		}
+
+		// Stream one event per word, then a final stop event
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, word := range strings.SplitAfter(content, " ") {
+			data, _ := json.Marshal(completionResponse{Content: word})
+			fmt.Fprintf(w, "data: %s\n\n", data)
+			w.(http.Flusher).Flush()
+		}
+		data, _ := json.Marshal(completionResponse{Stop: true, TokensPredicted: 5})
+		fmt.Fprintf(w, "data: %s\n\n", data)
+	})
+
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestServerProviderAttach(t *testing.T) {
+	srv := newFakeLlamaServer(t, "hello")
+
+	p, err := NewServerProvider(ServerConfig{URL: srv.URL})
+	if err != nil {
+		t.Fatalf("NewServerProvider() failed: %v", err)
+	}
+
+	if p.cmd != nil {
+		t.Error("Expected provider to attach, not spawn a process")
+	}
+
+	// Closing an attached provider must not fail or stop the server
+	if err := p.Close(); err != nil {
+		t.Errorf("Close() failed: %v", err)
+	}
+	if !p.healthy() {
+		t.Error("Attached server should still be running after Close()")
+	}
+}
+
+func TestServerProviderUnreachable(t *testing.T) {
+	srv := httptest.NewServer(http.NotFoundHandler())
+	url := srv.URL
+	srv.Close()
+
+	if _, err := NewServerProvider(ServerConfig{URL: url}); err == nil {
+		t.Error("Expected error when no server is reachable and no model is configured")
+	}
+
+	_, err := NewServerProvider(ServerConfig{
+		URL:       url,
+		ModelPath: "/models/missing.gguf",
+		Binary:    "pg-test-no-such-llama-server",
+	})
+	if err == nil {
+		t.Error("Expected error when llama-server binary is missing")
+	}
+}
+
+func TestServerProviderChat(t *testing.T) {
+	tests := []struct {
+		name           string
+		content        string
+		expectedReason string
+		expectedTools  int
+	}{
+		{
+			name:           "Plain text",
+			content:        "The project uses JWT auth.",
+			expectedReason: "stop",
+		},
+		{
+			name:           "Tool call",
+			content:        "Let me look.\n{\"tool\": \"read_file\", \"args\": {\"path\": \"main.go\"}}",
+			expectedReason: "tool_calls",
+			expectedTools:  1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := newFakeLlamaServer(t, tt.content)
+
+			p, err := NewServerProvider(ServerConfig{URL: srv.URL})
+			if err != nil {
+				t.Fatalf("NewServerProvider() failed: %v", err)
+			}
+
+			resp, err := p.Chat(context.Background(), []Message{{Role: "user", Content: "hi"}}, nil, GenerationOptions{})
+			if err != nil {
+				t.Fatalf("Chat() failed: %v", err)
+			}
+
+			if resp.FinishReason != tt.expectedReason {
+				t.Errorf("Expected finish reason %q, got %q", tt.expectedReason, resp.FinishReason)
+			}
+			if len(resp.ToolCalls) != tt.expectedTools {
+				t.Errorf("Expected %d tool call(s), got %d", tt.expectedTools, len(resp.ToolCalls))
+			}
+			if resp.Usage.PromptTokens == 0 || resp.Usage.CompletionTokens != 5 {
+				t.Errorf("Expected measured prompt tokens and 5 completion tokens, got %+v", resp.Usage)
+			}
+		})
+	}
+}
+
+func TestServerProviderChatStream(t *testing.T) {
+	content := "Reading the file now.\n{\"tool\": \"list_files\", \"args\": {\"path\": \".\"}}"
+	srv := newFakeLlamaServer(t, content)
+
+	p, err := NewServerProvider(ServerConfig{URL: srv.URL})
+	if err != nil {
+		t.Fatalf("NewServerProvider() failed: %v", err)
+	}
+
+	ch, err := p.ChatStream(context.Background(), []Message{{Role: "user", Content: "list files"}}, nil, GenerationOptions{})
+	if err != nil {
+		t.Fatalf("ChatStream() failed: %v", err)
+	}
+
+	var text strings.Builder
+	var chunks int
+	var toolCalls []ToolCall
+	for chunk := range ch {
+		if chunk.Error != nil {
+			t.Fatalf("Stream error: %v", chunk.Error)
+		}
+		if chunk.Content != "" {
+			chunks++
+			text.WriteString(chunk.Content)
+		}
+		if chunk.ToolCall != nil {
+			toolCalls = append(toolCalls, *chunk.ToolCall)
+		}
+	}
+
+	// The tool call JSON is held back from the displayed text
+	if want := "Reading the file now.\n"; text.String() != want {
+		t.Errorf("Expected streamed content %q, got %q", want, text.String())
+	}
+	if chunks < 2 {
+		t.Errorf("Expected content to arrive in several chunks, got %d", chunks)
+	}
+	if len(toolCalls) != 1 || toolCalls[0].Name != "list_files" {
+		t.Errorf("Expected one list_files tool call, got %+v", toolCalls)
+	}
+}
+
+func TestServerProviderStopAfterToolCall(t *testing.T) {
+	content := `{"tool": "read_file", "args": {"path": "a.go"}}` + "\n" + `{"tool": "read_file", "args": {"path": "b.go"}}`
+	srv := newFakeLlamaServer(t, content)
+
+	p, err := NewServerProvider(ServerConfig{URL: srv.URL})
+	if err != nil {
+		t.Fatalf("NewServerProvider() failed: %v", err)
+	}
+
+	stop := true
+	ch, err := p.ChatStream(context.Background(), []Message{{Role: "user", Content: "read both"}}, nil, GenerationOptions{StopAfterToolCall: &stop})
+	if err != nil {
+		t.Fatalf("ChatStream() failed: %v", err)
+	}
+
+	var toolCalls []ToolCall
+	var reason string
+	for chunk := range ch {
+		if chunk.Error != nil {
+			t.Fatalf("Stream error: %v", chunk.Error)
+		}
+		if chunk.Content != "" {
+			t.Errorf("Expected no displayed text, got %q", chunk.Content)
+		}
+		if chunk.ToolCall != nil {
+			toolCalls = append(toolCalls, *chunk.ToolCall)
+		}
+		if chunk.FinishReason != "" {
+			reason = chunk.FinishReason
+		}
+	}
+
+	if len(toolCalls) != 1 || toolCalls[0].Arguments["path"] != "a.go" {
+		t.Errorf("Expected the stream to end after the first call, got %+v", toolCalls)
+	}
+	if reason != "tool_calls" {
+		t.Errorf("Expected finish reason tool_calls, got %q", reason)
+	}
+}
+
+func TestServerProviderCountTokens(t *testing.T) {
+	srv := newFakeLlamaServer(t, "")
+
+	p, err := NewServerProvider(ServerConfig{URL: srv.URL})
+	if err != nil {
+		t.Fatalf("NewServerProvider() failed: %v", err)
+	}
+
+	n, err := p.CountTokens(context.Background(), "one two three")
+	if err != nil {
+		t.Fatalf("CountTokens() failed: %v", err)
+	}
+	if n != 3 {
+		t.Errorf("Expected 3 tokens from /tokenize, got %d", n)
+	}
+}
+
+func TestServerProviderCancel(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {})
+	mux.HandleFunc("/completion", func(w http.ResponseWriter, r *http.Request) {
+		// Send one token, then hang like a slow model until the client goes away
+		data, _ := json.Marshal(completionResponse{Content: "Thinking"})
+		fmt.Fprintf(w, "data: %s\n\n", data)
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	p, err := NewServerProvider(ServerConfig{URL: srv.URL})
+	if err != nil {
+		t.Fatalf("NewServerProvider() failed: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	ch, err := p.ChatStream(ctx, []Message{{Role: "user", Content: "hi"}}, nil, GenerationOptions{})
+	if err != nil {
+		t.Fatalf("ChatStream() failed: %v", err)
+	}
+
+	var streamErr error
+	for chunk := range ch {
+		if chunk.Content != "" {
+			cancel() // Simulate Ctrl-C after the first token
+		}
+		if chunk.Error != nil {
+			streamErr = chunk.Error
+		}
+	}
+
+	if !errors.Is(streamErr, context.Canceled) {
+		t.Errorf("Expected context.Canceled error after cancel, got %v", streamErr)
+	}
+}
+
+func TestServerProviderGenerationOptions(t *testing.T) {
+	var got map[string]interface{}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {})
+	mux.HandleFunc("/completion", func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&got)
+		json.NewEncoder(w).Encode(completionResponse{Content: "ok", Stop: true})
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	p, err := NewServerProvider(ServerConfig{URL: srv.URL})
+	if err != nil {
+		t.Fatalf("NewServerProvider() failed: %v", err)
+	}
+
+	opts := GenerationOptions{Seed: intPtr(42), MinP: float64Ptr(0.05), Stop: []string{"<END>"}}
+	if _, err := p.Chat(context.Background(), []Message{{Role: "user", Content: "hi"}}, nil, opts); err != nil {
+		t.Fatalf("Chat() failed: %v", err)
+	}
+
+	if got["seed"] != float64(42) || got["min_p"] != 0.05 {
+		t.Errorf("Expected seed and min_p in request, got %v", got)
+	}
+	if got["temperature"] != 0.1 || got["n_predict"] != float64(2048) {
+		t.Errorf("Expected defaults for unset options, got %v", got)
+	}
+	if got["cache_prompt"] != true {
+		t.Errorf("Expected cache_prompt so the server reuses the shared prefix, got %v", got["cache_prompt"])
+	}
+	if _, ok := got["repeat_penalty"]; ok {
+		t.Errorf("Unset repeat_penalty should be omitted, got %v", got["repeat_penalty"])
+	}
+
+	stops, _ := got["stop"].([]interface{})
+	if len(stops) == 0 || stops[len(stops)-1] != "<END>" {
+		t.Errorf("Expected configured stop after the template's, got %v", got["stop"])
+	}
+}
@@ -0,0 +1,293 @@
+package llm
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// ChatTemplate formats a conversation the way a model family was trained to see it
+type ChatTemplate struct {
+	Name string   // Registry key, e.g. "chatml"
+	Stop []string // Sequences that end the assistant's turn
+
+	// render formats the merged system prompt and the remaining turns,
+	// ending with the opening of a new assistant turn
+	render func(system string, turns []Message) string
+}
+
+// Template names used in model.ModelSpec.ChatTemplate and config
+const (
+	TemplateChatML        = "chatml"         // Qwen family
+	TemplateZephyr        = "zephyr"         // TinyLlama chat
+	TemplateDeepSeekCoder = "deepseek-coder" // DeepSeek-Coder instruct (### Instruction / ### Response)
+	TemplateDeepSeekLLM   = "deepseek-llm"   // DeepSeek-Coder v1.5 (User: / Assistant:)
+	TemplatePlain         = "plain"          // Base models without a chat format
+)
+
+// templates holds every known chat template by name
+var templates = map[string]*ChatTemplate{
+	TemplateChatML: {
+		Name:   TemplateChatML,
+		Stop:   []string{"<|im_end|>", "<|im_start|>", "<|endoftext|>"},
+		render: renderChatML,
+	},
+	TemplateZephyr: {
+		Name:   TemplateZephyr,
+		Stop:   []string{"</s>", "<|user|>", "<|system|>"},
+		render: renderZephyr,
+	},
+	TemplateDeepSeekCoder: {
+		Name:   TemplateDeepSeekCoder,
+		Stop:   []string{"<|EOT|>", "### Instruction:"},
+		render: renderDeepSeekCoder,
+	},
+	TemplateDeepSeekLLM: {
+		Name:   TemplateDeepSeekLLM,
+		Stop:   []string{"<｜end▁of▁sentence｜>", "\nUser:"},
+		render: renderDeepSeekLLM,
+	},
+	TemplatePlain: {
+		Name:   TemplatePlain,
+		Stop:   []string{"\nUser:", "\nSystem:", "\nTool Result"},
+		render: renderPlain,
+	},
+}
+
+// GetTemplate returns the chat template registered under name
+func GetTemplate(name string) (*ChatTemplate, error) {
+	tmpl, ok := templates[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown chat template: %s", name)
+	}
+	return tmpl, nil
+}
+
+// TemplateForModel picks the chat template for a GGUF model.
+// The template embedded in the GGUF metadata wins; otherwise the file name decides.
+func TemplateForModel(modelPath string) *ChatTemplate {
+	if embedded, err := readGGUFChatTemplate(modelPath); err == nil && embedded != "" {
+		if tmpl := detectTemplate(embedded); tmpl != nil {
+			return tmpl
+		}
+	}
+
+	return templateForFileName(filepath.Base(modelPath))
+}
+
+// detectTemplate recognises a Jinja chat template by its distinctive markers
+func detectTemplate(jinja string) *ChatTemplate {
+	switch {
+	case strings.Contains(jinja, "<|im_start|>"):
+		return templates[TemplateChatML]
+	case strings.Contains(jinja, "<|user|>") && strings.Contains(jinja, "<|assistant|>"):
+		return templates[TemplateZephyr]
+	case strings.Contains(jinja, "### Instruction"):
+		return templates[TemplateDeepSeekCoder]
+	case strings.Contains(jinja, "User: ") && strings.Contains(jinja, "Assistant:"):
+		return templates[TemplateDeepSeekLLM]
+	}
+	return nil
+}
+
+// templateForFileName guesses the template from a GGUF file name
+func templateForFileName(name string) *ChatTemplate {
+	name = strings.ToLower(name)
+
+	switch {
+	case strings.Contains(name, "qwen"):
+		return templates[TemplateChatML]
+	case strings.Contains(name, "tinyllama") && strings.Contains(name, "chat"):
+		return templates[TemplateZephyr]
+	case strings.Contains(name, "deepseek-coder") && strings.Contains(name, "v1.5"):
+		return templates[TemplateDeepSeekLLM]
+	case strings.Contains(name, "deepseek-coder") && strings.Contains(name, "instruct"):
+		return templates[TemplateDeepSeekCoder]
+	}
+
+	return templates[TemplatePlain]
+}
+
+// Format renders messages into a prompt ready for completion
+func (t *ChatTemplate) Format(system string, messages []Message) string {
+	var systemParts []string
+	if system != "" {
+		systemParts = append(systemParts, system)
+	}
+
+	// System messages are merged into one block; tool results become user turns
+	var turns []Message
+	for _, msg := range messages {
+		switch msg.Role {
+		case "system":
+			systemParts = append(systemParts, msg.Content)
+		case "tool":
+			turns = append(turns, Message{
+				Role:    "user",
+				Content: fmt.Sprintf("Tool Result (%s):\n%s", msg.Name, msg.Content),
+			})
+		case "assistant":
+			content := msg.Content
+			if len(msg.ToolCalls) > 0 && len(ParseToolCalls(content)) == 0 {
+				// Native and streamed tool calls are not in the text; show them in the JSON form we ask for
+				content = strings.TrimSpace(content + "\n" + formatToolCalls(msg.ToolCalls))
+			}
+			turns = append(turns, Message{Role: "assistant", Content: content})
+		default:
+			turns = append(turns, msg)
+		}
+	}
+
+	return t.render(strings.Join(systemParts, "\n\n"), turns)
+}
+
+// TrimAtStop cuts text at the first stop sequence, if any.
+// extra adds stop sequences to the template's own.
+func (t *ChatTemplate) TrimAtStop(text string, extra ...string) string {
+	return trimAtStop(text, append(append([]string(nil), t.Stop...), extra...))
+}
+
+// trimAtStop cuts text at the first of stops, if any
+func trimAtStop(text string, stops []string) string {
+	cut := len(text)
+	for _, stop := range stops {
+		if i := strings.Index(text, stop); i >= 0 && i < cut {
+			cut = i
+		}
+	}
+	return text[:cut]
+}
+
+// renderChatML formats Qwen-style <|im_start|> turns
+func renderChatML(system string, turns []Message) string {
+	var sb strings.Builder
+
+	if system != "" {
+		sb.WriteString("<|im_start|>system\n" + system + "<|im_end|>\n")
+	}
+	for _, msg := range turns {
+		sb.WriteString("<|im_start|>" + msg.Role + "\n" + msg.Content + "<|im_end|>\n")
+	}
+	sb.WriteString("<|im_start|>assistant\n")
+
+	return sb.String()
+}
+
+// renderZephyr formats TinyLlama/Zephyr-style <|user|> turns
+func renderZephyr(system string, turns []Message) string {
+	var sb strings.Builder
+
+	if system != "" {
+		sb.WriteString("<|system|>\n" + system + "</s>\n")
+	}
+	for _, msg := range turns {
+		sb.WriteString("<|" + msg.Role + "|>\n" + msg.Content + "</s>\n")
+	}
+	sb.WriteString("<|assistant|>\n")
+
+	return sb.String()
+}
+
+// renderDeepSeekCoder formats DeepSeek-Coder instruct turns
+func renderDeepSeekCoder(system string, turns []Message) string {
+	var sb strings.Builder
+
+	if system != "" {
+		sb.WriteString(system + "\n")
+	}
+	for _, msg := range turns {
+		if msg.Role == "assistant" {
+			sb.WriteString("### Response:\n" + msg.Content + "\n<|EOT|>\n")
+		} else {
+			sb.WriteString("### Instruction:\n" + msg.Content + "\n")
+		}
+	}
+	sb.WriteString("### Response:\n")
+
+	return sb.String()
+}
+
+// renderDeepSeekLLM formats DeepSeek-Coder v1.5 User:/Assistant: turns
+func renderDeepSeekLLM(system string, turns []Message) string {
+	var sb strings.Builder
+
+	if system != "" {
+		sb.WriteString(system + "\n\n")
+	}
+	for _, msg := range turns {
+		if msg.Role == "assistant" {
+			sb.WriteString("Assistant: " + msg.Content + "<｜end▁of▁sentence｜>")
+		} else {
+			sb.WriteString("User: " + msg.Content + "\n\n")
+		}
+	}
+	sb.WriteString("Assistant:")
+
+	return sb.String()
+}
+
+// renderPlain formats turns as labelled paragraphs for base models
+func renderPlain(system string, turns []Message) string {
+	var sb strings.Builder
+
+	if system != "" {
+		sb.WriteString("System: " + system + "\n\n")
+	}
+	for _, msg := range turns {
+		if msg.Role == "assistant" {
+			sb.WriteString("Assistant: " + msg.Content + "\n\n")
+		} else {
+			sb.WriteString("User: " + msg.Content + "\n\n")
+		}
+	}
+	sb.WriteString("Assistant: ")
+
+	return sb.String()
+}
+
+// stopFilter passes streamed text through until a stop sequence appears,
+// holding back any tail that could be the start of one
+type stopFilter struct {
+	stops   []string
+	pending string
+}
+
+// push adds streamed text and returns what is safe to show.
+// stopped is true once a stop sequence has been seen; later text must be discarded.
+func (f *stopFilter) push(text string) (out string, stopped bool) {
+	f.pending += text
+
+	cut := -1
+	for _, stop := range f.stops {
+		if i := strings.Index(f.pending, stop); i >= 0 && (cut < 0 || i < cut) {
+			cut = i
+		}
+	}
+	if cut >= 0 {
+		out = f.pending[:cut]
+		f.pending = ""
+		return out, true
+	}
+
+	// Keep back the longest suffix that is a prefix of some stop sequence
+	hold := 0
+	for _, stop := range f.stops {
+		for n := len(stop) - 1; n > hold; n-- {
+			if strings.HasSuffix(f.pending, stop[:n]) {
+				hold = n
+				break
+			}
+		}
+	}
+
+	out = f.pending[:len(f.pending)-hold]
+	f.pending = f.pending[len(f.pending)-hold:]
+	return out, false
+}
+
+// flush returns any held-back text once the stream has ended
+func (f *stopFilter) flush() string {
+	out := f.pending
+	f.pending = ""
+	return out
+}
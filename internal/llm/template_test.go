@@ -0,0 +1,212 @@
+package llm
+
+import (
+	"bytes"
+	"encoding/binary"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/yourusername/playground/internal/model"
+)
+
+var update = flag.Bool("update", false, "rewrite golden files in testdata")
+
+// goldenConversation exercises every message role a provider can send
+var goldenConversation = []Message{
+	{Role: "system", Content: "Project: playground (Go)"},
+	{Role: "user", Content: "What does main.go do?"},
+	{Role: "assistant", ToolCalls: []ToolCall{{ID: "call_1", Name: "read_file", Arguments: map[string]interface{}{"path": "main.go"}}}},
+	{Role: "tool", Name: "read_file", ToolCallID: "call_1", Content: "package main\n\nfunc main() {}"},
+	{Role: "assistant", Content: "It defines an empty main function."},
+	{Role: "user", Content: "Thanks"},
+}
+
+var goldenTools = []Tool{
+	{Name: "read_file", Description: "Read the contents of a file"},
+}
+
+func TestTemplateGolden(t *testing.T) {
+	// Every registered model plus the default model from `pg setup`
+	files := []string{"deepseek-coder-7b-instruct-v1.5.Q4_K_M.gguf"}
+	for _, spec := range model.ModelRegistry {
+		files = append(files, spec.FileName)
+	}
+
+	for _, file := range files {
+		t.Run(file, func(t *testing.T) {
+			tmpl := TemplateForModel(filepath.Join(t.TempDir(), file))
+			got := buildPrompt(tmpl, goldenConversation, goldenTools)
+
+			golden := filepath.Join("testdata", "templates", strings.TrimSuffix(file, ".gguf")+".golden")
+			if *update {
+				if err := os.WriteFile(golden, []byte(got), 0644); err != nil {
+					t.Fatalf("Failed to update golden file: %v", err)
+				}
+			}
+
+			want, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatalf("Failed to read golden file (run with -update to create): %v", err)
+			}
+			if got != string(want) {
+				t.Errorf("Prompt for %s (%s template) does not match %s\nGot:\n%s", file, tmpl.Name, golden, got)
+			}
+		})
+	}
+}
+
+func TestRegistryTemplates(t *testing.T) {
+	for _, spec := range model.ModelRegistry {
+		tmpl, err := GetTemplate(spec.ChatTemplate)
+		if err != nil {
+			t.Errorf("%s: %v", spec.Name, err)
+			continue
+		}
+
+		// The file name heuristic must agree with the registry for models without metadata
+		if detected := templateForFileName(spec.FileName); detected != tmpl {
+			t.Errorf("%s: registry says %s, file name suggests %s", spec.Name, tmpl.Name, detected.Name)
+		}
+	}
+}
+
+func TestDetectTemplate(t *testing.T) {
+	tests := []struct {
+		name     string
+		jinja    string
+		expected string
+	}{
+		{"Qwen", "{% for message in messages %}{{'<|im_start|>' + message['role'] + '\n'}}", TemplateChatML},
+		{"TinyLlama", "{% if message['role'] == 'user' %}{{ '<|user|>\n' }}{% endif %}{{ '<|assistant|>' }}", TemplateZephyr},
+		{"DeepSeek-Coder", "{{'### Instruction:\\n' + message['content'] + '\\n'}}", TemplateDeepSeekCoder},
+		{"DeepSeek v1.5", "{{ 'User: ' + message['content'] + '\n\n' }}{{ 'Assistant:' }}", TemplateDeepSeekLLM},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpl := detectTemplate(tt.jinja)
+			if tmpl == nil || tmpl.Name != tt.expected {
+				t.Errorf("Expected %s, got %+v", tt.expected, tmpl)
+			}
+		})
+	}
+
+	if tmpl := detectTemplate("{{ messages }}"); tmpl != nil {
+		t.Errorf("Expected no match for unknown template, got %s", tmpl.Name)
+	}
+}
+
+func TestTemplateForModelGGUF(t *testing.T) {
+	// A GGUF whose file name says nothing, but whose metadata carries a ChatML template
+	var buf bytes.Buffer
+	le := binary.LittleEndian
+	writeString := func(s string) {
+		binary.Write(&buf, le, uint64(len(s)))
+		buf.WriteString(s)
+	}
+
+	binary.Write(&buf, le, uint32(ggufMagic))
+	binary.Write(&buf, le, uint32(3)) // version
+	binary.Write(&buf, le, uint64(0)) // tensors
+	binary.Write(&buf, le, uint64(3)) // metadata entries
+
+	writeString("general.architecture")
+	binary.Write(&buf, le, ggufString)
+	writeString("qwen2")
+
+	writeString("tokenizer.ggml.tokens")
+	binary.Write(&buf, le, ggufArray)
+	binary.Write(&buf, le, ggufString)
+	binary.Write(&buf, le, uint64(2))
+	writeString("<|im_start|>")
+	writeString("hello")
+
+	writeString(ggufChatTemplateKey)
+	binary.Write(&buf, le, ggufString)
+	writeString("{{'<|im_start|>' + message['role'] + '\n' + message['content'] + '<|im_end|>'}}")
+
+	path := filepath.Join(t.TempDir(), "my-finetune.gguf")
+	if err := os.WriteFile(path, buf.Bytes(), 0644); err != nil {
+		t.Fatalf("Failed to write GGUF: %v", err)
+	}
+
+	if tmpl := TemplateForModel(path); tmpl.Name != TemplateChatML {
+		t.Errorf("Expected chatml from GGUF metadata, got %s", tmpl.Name)
+	}
+
+	// Without metadata the same name falls back to plain
+	if tmpl := TemplateForModel(filepath.Join(t.TempDir(), "my-finetune.gguf")); tmpl.Name != TemplatePlain {
+		t.Errorf("Expected plain for unknown model, got %s", tmpl.Name)
+	}
+}
+
+func TestStopFilter(t *testing.T) {
+	tests := []struct {
+		name     string
+		chunks   []string
+		expected string
+		stopped  bool
+	}{
+		{
+			name:     "No stop sequence",
+			chunks:   []string{"Hello ", "world"},
+			expected: "Hello world",
+		},
+		{
+			name:     "Stop inside one chunk",
+			chunks:   []string{"Done.<|im_end|>\n<|im_start|>user"},
+			expected: "Done.",
+			stopped:  true,
+		},
+		{
+			name:     "Stop split across chunks",
+			chunks:   []string{"Done.<|im", "_end|>more"},
+			expected: "Done.",
+			stopped:  true,
+		},
+		{
+			name:     "Partial match that is not a stop",
+			chunks:   []string{"a <|im", " b"},
+			expected: "a <|im b",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filter := &stopFilter{stops: templates[TemplateChatML].Stop}
+
+			var out strings.Builder
+			stopped := false
+			for _, chunk := range tt.chunks {
+				text, done := filter.push(chunk)
+				out.WriteString(text)
+				if done {
+					stopped = true
+					break
+				}
+			}
+			if !stopped {
+				out.WriteString(filter.flush())
+			}
+
+			if out.String() != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, out.String())
+			}
+			if stopped != tt.stopped {
+				t.Errorf("Expected stopped=%v, got %v", tt.stopped, stopped)
+			}
+		})
+	}
+}
+
+func TestTrimAtStop(t *testing.T) {
+	tmpl := templates[TemplateDeepSeekLLM]
+
+	got := tmpl.TrimAtStop("Here is the fix.\nUser: thanks, now do the tests")
+	if got != "Here is the fix." {
+		t.Errorf("Expected reply cut before fake user turn, got %q", got)
+	}
+}
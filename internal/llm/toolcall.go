@@ -0,0 +1,242 @@
+package llm
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// callSeq keeps IDs unique when one reply holds several calls
+var callSeq atomic.Int64
+
+// maxScanPasses bounds how many times over ParseToolCalls may scan a reply.
+// Each bracket is a candidate start, and one that never closes is read to the
+// end, so without a bound unclosed or deeply nested brackets take quadratic time.
+const maxScanPasses = 16
+
+// ParseToolCalls finds the tool calls in a model's text reply.
+//
+// It accepts every shape local models produce in practice:
+//
+//	{"tool": "read_file", "args": {...}}            the format toolPreamble asks for
+//	{"name": "read_file", "arguments": {...}}       OpenAI and Hermes style; arguments may be a JSON string
+//	{"function": {"name": ..., "arguments": ...}}   an OpenAI tool_calls entry
+//	<tool_call>{"name": ...}</tool_call>            as emitted by Qwen
+//
+// Objects may span lines, sit in ``` fences or arrays, or follow prose on the
+// same line. Common defects are repaired; see repairJSON. Anything that does not
+// decode to a call, such as Go code or other JSON, is ignored. Past
+// maxScanPasses reads of the text, the rest of it is not searched.
+func ParseToolCalls(text string) []ToolCall {
+	var calls []ToolCall
+	budget := maxScanPasses * (len(text) + 1)
+
+	for i := 0; i < len(text) && budget > 0; i++ {
+		if text[i] != '{' && text[i] != '[' {
+			continue
+		}
+
+		repaired, end, ok := repairJSON(text, i)
+		budget -= end - i
+		if !ok {
+			continue
+		}
+		var value interface{}
+		if err := json.Unmarshal([]byte(repaired), &value); err != nil {
+			continue
+		}
+		if found := decodeToolCalls(value); len(found) > 0 {
+			calls = append(calls, found...)
+			i = end - 1 // Resume after the object
+		}
+	}
+
+	return calls
+}
+
+// decodeToolCalls reads the calls out of a decoded JSON value
+func decodeToolCalls(value interface{}) []ToolCall {
+	switch v := value.(type) {
+	case []interface{}:
+		var calls []ToolCall
+		for _, item := range v {
+			calls = append(calls, decodeToolCalls(item)...)
+		}
+		return calls
+
+	case map[string]interface{}:
+		if function, ok := v["function"].(map[string]interface{}); ok {
+			return decodeToolCalls(function)
+		}
+		if list, ok := v["tool_calls"].([]interface{}); ok {
+			return decodeToolCalls(list)
+		}
+
+		if name, ok := v["tool"].(string); ok && name != "" {
+			if args, ok := decodeArguments(firstPresent(v, "args", "arguments", "parameters")); ok {
+				return []ToolCall{newToolCall(name, args)}
+			}
+		}
+		// A bare {"name": ...} is too common in ordinary JSON to count as a call
+		if name, ok := v["name"].(string); ok && name != "" {
+			raw := firstPresent(v, "arguments", "parameters", "args")
+			if raw == nil {
+				return nil
+			}
+			if args, ok := decodeArguments(raw); ok {
+				return []ToolCall{newToolCall(name, args)}
+			}
+		}
+	}
+	return nil
+}
+
+// decodeArguments accepts arguments as an object, a JSON-encoded object string, or nothing
+func decodeArguments(raw interface{}) (map[string]interface{}, bool) {
+	switch v := raw.(type) {
+	case nil:
+		return map[string]interface{}{}, true
+	case map[string]interface{}:
+		return v, true
+	case string:
+		if strings.TrimSpace(v) == "" {
+			return map[string]interface{}{}, true
+		}
+		start := strings.Index(v, "{")
+		if start < 0 {
+			return nil, false
+		}
+		repaired, _, ok := repairJSON(v, start)
+		if !ok {
+			return nil, false
+		}
+		var args map[string]interface{}
+		if err := json.Unmarshal([]byte(repaired), &args); err != nil {
+			return nil, false
+		}
+		return args, true
+	}
+	return nil, false
+}
+
+func firstPresent(m map[string]interface{}, keys ...string) interface{} {
+	for _, key := range keys {
+		if value, ok := m[key]; ok {
+			return value
+		}
+	}
+	return nil
+}
+
+func newToolCall(name string, args map[string]interface{}) ToolCall {
+	return ToolCall{
+		ID:        fmt.Sprintf("call_%d_%d", time.Now().UnixNano(), callSeq.Add(1)),
+		Name:      name,
+		Arguments: args,
+	}
+}
+
+// repairJSON reads the JSON object or array starting at text[start] and returns
+// it as strict JSON, with the index just past its end. It fixes the defects
+// small models commonly make:
+//
+//   - trailing commas before } or ]
+//   - single-quoted strings
+//   - raw newlines, tabs and other control characters inside strings, typical of unified_diff
+//   - unescaped double quotes inside strings, when they cannot end the string
+//   - invalid escapes such as \d, kept as a literal backslash
+//
+// Valid JSON comes back unchanged. ok is false if the value never closes.
+func repairJSON(text string, start int) (repaired string, end int, ok bool) {
+	out := make([]byte, 0, 256)
+	depth := 0
+	var quote byte // Quote character of the string being read, 0 outside strings
+
+	for i := start; i < len(text); i++ {
+		c := text[i]
+
+		if quote != 0 {
+			switch {
+			case c == '\\' && i+1 < len(text):
+				next := text[i+1]
+				switch {
+				case next == '\'' && quote == '\'':
+					out = append(out, '\'')
+				case strings.IndexByte(`"\/bfnrtu`, next) >= 0:
+					out = append(out, '\\')
+					out = append(out, next)
+				default:
+					out = append(out, `\\`...)
+					continue // Reread next as an ordinary character
+				}
+				i++
+			case c == quote && closesString(text, i+1):
+				out = append(out, '"')
+				quote = 0
+			case c == '"':
+				out = append(out, `\"`...)
+			case c == '\n':
+				out = append(out, `\n`...)
+			case c == '\r':
+				out = append(out, `\r`...)
+			case c == '\t':
+				out = append(out, `\t`...)
+			case c < 0x20:
+				out = fmt.Appendf(out, `\u%04x`, c)
+			default:
+				out = append(out, c)
+			}
+			continue
+		}
+
+		switch c {
+		case '"', '\'':
+			quote = c
+			out = append(out, '"')
+		case '{', '[':
+			depth++
+			out = append(out, c)
+		case '}', ']':
+			out = trimTrailingComma(out)
+			out = append(out, c)
+			depth--
+			if depth == 0 {
+				return string(out), i + 1, true
+			}
+		default:
+			out = append(out, c)
+		}
+	}
+
+	return "", len(text), false
+}
+
+// closesString reports whether a quote whose next character is text[i] can end
+// a string: it must be followed by one of , : } ] or the end of the text
+func closesString(text string, i int) bool {
+	for ; i < len(text); i++ {
+		switch text[i] {
+		case ' ', '\t', '\n', '\r':
+			continue
+		case ',', ':', '}', ']':
+			return true
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// trimTrailingComma drops a comma, keeping the whitespace after it, from the end of out
+func trimTrailingComma(out []byte) []byte {
+	i := len(out)
+	for i > 0 && strings.IndexByte(" \t\r\n", out[i-1]) >= 0 {
+		i--
+	}
+	if i > 0 && out[i-1] == ',' {
+		return append(out[:i-1], out[i:]...)
+	}
+	return out
+}
@@ -0,0 +1,147 @@
+package llm
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// goldenCall is a parsed call without its generated ID
+type goldenCall struct {
+	Name      string                 `json:"name"`
+	Arguments map[string]interface{} `json:"arguments"`
+}
+
+// TestParseToolCallsGolden parses real model replies in testdata/toolcalls;
+// each NAME.txt has the expected calls in NAME.golden
+func TestParseToolCallsGolden(t *testing.T) {
+	inputs, err := filepath.Glob(filepath.Join("testdata", "toolcalls", "*.txt"))
+	if err != nil || len(inputs) == 0 {
+		t.Fatalf("No corpus files found: %v", err)
+	}
+
+	for _, input := range inputs {
+		name := strings.TrimSuffix(filepath.Base(input), ".txt")
+		t.Run(name, func(t *testing.T) {
+			text, err := os.ReadFile(input)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			calls := []goldenCall{}
+			for _, call := range ParseToolCalls(string(text)) {
+				calls = append(calls, goldenCall{Name: call.Name, Arguments: call.Arguments})
+			}
+			got, _ := json.MarshalIndent(calls, "", "  ")
+			got = append(got, '\n')
+
+			golden := strings.TrimSuffix(input, ".txt") + ".golden"
+			if *update {
+				if err := os.WriteFile(golden, got, 0644); err != nil {
+					t.Fatalf("Failed to update golden file: %v", err)
+				}
+			}
+
+			want, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatalf("Failed to read golden file (run with -update to create): %v", err)
+			}
+			if string(got) != string(want) {
+				t.Errorf("Tool calls in %s do not match %s\nGot:\n%s", input, golden, got)
+			}
+		})
+	}
+}
+
+func TestRepairJSON(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+		ok       bool
+	}{
+		{"Valid JSON unchanged", `{"a": [1, "x\n"], "b": {}}`, `{"a": [1, "x\n"], "b": {}}`, true},
+		{"Trailing commas", "{\"a\": [1, 2,], \"b\": 3,\n}", "{\"a\": [1, 2], \"b\": 3\n}", true},
+		{"Single quotes", `{'a': 'x', 'b': "don't"}`, `{"a": "x", "b": "don't"}`, true},
+		{"Escaped single quote", `{'a': 'it\'s'}`, `{"a": "it's"}`, true},
+		{"Raw control characters", "{\"d\": \"-a\n+b\t\r\"}", `{"d": "-a\n+b\t\r"}`, true},
+		{"Unescaped inner quotes", `{"d": "+fmt.Println("hi")"}`, `{"d": "+fmt.Println(\"hi\")"}`, true},
+		{"Invalid escape", `{"re": "\d+"}`, `{"re": "\\d+"}`, true},
+		{"Stops at the end of the value", `{"a": 1} trailing {`, `{"a": 1}`, true},
+		{"Never closes", `{"a": {"b": 1}`, "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, _, ok := repairJSON(tt.input, 0)
+			if tt.ok && got != tt.expected {
+				t.Errorf("Expected %s, got %s", tt.expected, got)
+			}
+			if ok && tt.ok && !json.Valid([]byte(got)) {
+				t.Errorf("Repaired JSON is not valid: %s", got)
+			}
+			if !tt.ok && ok && json.Valid([]byte(got)) {
+				t.Errorf("Expected no valid JSON, got %s", got)
+			}
+		})
+	}
+}
+
+// FuzzParseToolCalls checks that any text parses without panicking, and that
+// the calls found survive a round trip through the format we prompt for
+func FuzzParseToolCalls(f *testing.F) {
+	inputs, _ := filepath.Glob(filepath.Join("testdata", "toolcalls", "*.txt"))
+	for _, input := range inputs {
+		if text, err := os.ReadFile(input); err == nil {
+			f.Add(string(text))
+		}
+	}
+	f.Add(`{"tool": "a", "args": {"x": "}{"}}`)
+	f.Add(`[[{'name': 'b', 'parameters': {},},]]`)
+
+	f.Fuzz(func(t *testing.T, text string) {
+		calls := ParseToolCalls(text)
+		for _, call := range calls {
+			if call.Name == "" || call.Arguments == nil {
+				t.Fatalf("Incomplete call %+v from %q", call, text)
+			}
+		}
+
+		again := ParseToolCalls(formatToolCalls(calls))
+		if len(again) != len(calls) {
+			t.Fatalf("Round trip found %d calls, expected %d", len(again), len(calls))
+		}
+		for i := range calls {
+			if again[i].Name != calls[i].Name || !reflect.DeepEqual(again[i].Arguments, calls[i].Arguments) {
+				t.Fatalf("Round trip changed %+v into %+v", calls[i], again[i])
+			}
+		}
+	})
+}
+
+// TestParseToolCallsBounded checks that brackets that never close cannot make
+// parsing quadratic, and that a call before them is still found
+func TestParseToolCallsBounded(t *testing.T) {
+	call := `{"tool": "git_status", "args": {}}` + "\n"
+	for _, junk := range []string{
+		strings.Repeat(`{"name":"x","arguments":{`, 16000),
+		strings.Repeat(`{"a":[`, 16000),
+		strings.Repeat("[", 100000) + strings.Repeat("]", 100000),
+	} {
+		done := make(chan []ToolCall, 1)
+		go func() { done <- ParseToolCalls(call + junk) }()
+
+		select {
+		case calls := <-done:
+			if len(calls) != 1 || calls[0].Name != "git_status" {
+				t.Errorf("Expected the git_status call, got %+v", calls)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatalf("ParseToolCalls took too long on %d bytes", len(call+junk))
+		}
+	}
+}
@@ -0,0 +1,187 @@
+package llm
+
+import "strings"
+
+// toolCallTags wrap tool calls in Qwen's native format
+var toolCallTags = []string{"<tool_call>", "</tool_call>"}
+
+// toolCallKeys are the first keys of the call shapes ParseToolCalls accepts
+var toolCallKeys = []string{"tool", "name", "function", "tool_calls"}
+
+// toolCallScanner spots tool call objects in streamed text as they form.
+// A line that starts with "{" is held back from the display until its object closes;
+// tool calls are returned typed and dropped from the display, anything else is released.
+// <tool_call> tags at the start of a line are dropped too.
+type toolCallScanner struct {
+	indent      strings.Builder // Leading whitespace of the current line, held until we know what follows
+	object      strings.Builder // Object or tag still forming
+	lineStart   bool            // The next character begins a line
+	inObject    bool
+	inTag       bool
+	depth       int
+	inString    bool
+	escaped     bool
+	skipNewline bool // Drop the newline that ends a tool call line
+}
+
+func newToolCallScanner() *toolCallScanner {
+	return &toolCallScanner{lineStart: true}
+}
+
+// push consumes streamed text, returning what can be displayed and the tool calls that completed
+func (s *toolCallScanner) push(text string) (display string, calls []ToolCall) {
+	var out strings.Builder
+
+	for _, r := range text {
+		if s.inTag {
+			s.object.WriteRune(r)
+			tag := s.object.String()
+			switch {
+			case tag == toolCallTags[0] || tag == toolCallTags[1]:
+				s.indent.Reset()
+				s.object.Reset()
+				s.skipNewline = true
+				s.lineStart = true // A call may follow the tag on the same line
+			case !hasTagPrefix(tag):
+				s.release(&out)
+				s.lineStart = r == '\n'
+			default:
+				continue
+			}
+			s.inTag = false
+			continue
+		}
+
+		if s.inObject {
+			s.object.WriteRune(r)
+			s.scan(r)
+
+			switch {
+			case s.depth == 0:
+				if found := ParseToolCalls(s.object.String()); len(found) > 0 {
+					calls = append(calls, found...)
+					s.indent.Reset()
+					s.object.Reset()
+					s.skipNewline = true
+					s.lineStart = true // A closing tag may follow on the same line
+				} else {
+					s.release(&out)
+				}
+				s.inObject = false
+
+			case r == '\n' && !mayBeToolCall(s.object.String()):
+				// A multi-line block that is not a tool call (e.g. code); show it
+				s.release(&out)
+				s.inObject = false
+				s.lineStart = true
+			}
+			continue
+		}
+
+		if s.skipNewline {
+			if r == ' ' || r == '\t' || r == '\r' {
+				continue
+			}
+			s.skipNewline = false
+			if r == '\n' {
+				s.lineStart = true
+				continue
+			}
+		}
+
+		switch {
+		case s.lineStart && (r == ' ' || r == '\t'):
+			s.indent.WriteRune(r)
+		case s.lineStart && r == '{':
+			s.inObject = true
+			s.depth = 1
+			s.inString, s.escaped = false, false
+			s.object.WriteRune(r)
+			s.lineStart = false
+		case s.lineStart && r == '<':
+			s.inTag = true
+			s.object.WriteRune(r)
+			s.lineStart = false
+		default:
+			out.WriteString(s.indent.String())
+			s.indent.Reset()
+			out.WriteRune(r)
+			s.lineStart = r == '\n'
+		}
+	}
+
+	return out.String(), calls
+}
+
+// flush returns any text still held back, e.g. an object cut off by the end of the stream
+func (s *toolCallScanner) flush() string {
+	var out strings.Builder
+	s.release(&out)
+	s.inObject, s.inTag = false, false
+	return out.String()
+}
+
+// mayBeToolCall reports whether an object that has not closed yet could still be a
+// tool call: its first key is unknown so far, or is one a call starts with
+func mayBeToolCall(object string) bool {
+	rest := strings.TrimLeft(strings.TrimPrefix(object, "{"), " \t\r\n")
+	if rest == "" {
+		return true
+	}
+	if rest[0] != '"' && rest[0] != '\'' {
+		return false
+	}
+	end := strings.IndexByte(rest[1:], rest[0])
+	if end < 0 {
+		return true
+	}
+	key := rest[1 : end+1]
+	for _, k := range toolCallKeys {
+		if key == k {
+			return true
+		}
+	}
+	return false
+}
+
+// hasTagPrefix reports whether text may still grow into a tool call tag
+func hasTagPrefix(text string) bool {
+	for _, tag := range toolCallTags {
+		if strings.HasPrefix(tag, text) {
+			return true
+		}
+	}
+	return false
+}
+
+// release moves the held text to the display
+func (s *toolCallScanner) release(out *strings.Builder) {
+	out.WriteString(s.indent.String())
+	out.WriteString(s.object.String())
+	s.indent.Reset()
+	s.object.Reset()
+}
+
+// scan tracks JSON nesting, ignoring braces inside strings
+func (s *toolCallScanner) scan(r rune) {
+	if s.inString {
+		switch {
+		case s.escaped:
+			s.escaped = false
+		case r == '\\':
+			s.escaped = true
+		case r == '"':
+			s.inString = false
+		}
+		return
+	}
+
+	switch r {
+	case '"':
+		s.inString = true
+	case '{', '[':
+		s.depth++
+	case '}', ']':
+		s.depth--
+	}
+}
@@ -0,0 +1,98 @@
+package llm
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestToolCallScanner(t *testing.T) {
+	tests := []struct {
+		name     string
+		chunks   []string
+		display  string
+		expected []string // Names of the tool calls found
+	}{
+		{
+			name:    "Prose only",
+			chunks:  []string{"The handler ", "checks the token.\n", "Done."},
+			display: "The handler checks the token.\nDone.",
+		},
+		{
+			name:     "Call split across chunks",
+			chunks:   []string{"Let me look.\n{\"to", "ol\": \"read_file\", \"args\": {\"pa", "th\": \"main.go\"}}"},
+			display:  "Let me look.\n",
+			expected: []string{"read_file"},
+		},
+		{
+			name:     "Two calls",
+			chunks:   []string{`{"tool": "git_status", "args": {}}` + "\n", `  {"tool": "read_file", "args": {"path": "a.go"}}`},
+			expected: []string{"git_status", "read_file"},
+		},
+		{
+			name:     "Braces inside strings",
+			chunks:   []string{`{"tool": "propose_patch", "args": {"file_path": "a.go", "unified_diff": "+func f() {\n+\t}\"}\n"}}`},
+			expected: []string{"propose_patch"},
+		},
+		{
+			name:     "Call spanning lines",
+			chunks:   []string{"Reading it.\n{\n", "  \"tool\": \"read_file\",\n  \"args\": {\"path\": \"a.go\"}\n}\n", "Done."},
+			display:  "Reading it.\nDone.",
+			expected: []string{"read_file"},
+		},
+		{
+			name:     "Qwen tool call tags",
+			chunks:   []string{"<tool_", "call>\n{\"name\": \"git_status\", \"arguments\": {}}\n</tool_call>\n", "Checking."},
+			display:  "Checking.",
+			expected: []string{"git_status"},
+		},
+		{
+			name:    "Markup that is not a tag",
+			chunks:  []string{"<div>\n", "<tool>x</tool>\n"},
+			display: "<div>\n<tool>x</tool>\n",
+		},
+		{
+			name:    "JSON that is not a tool call",
+			chunks:  []string{"Config:\n", `{"port": 8080}`, "\nok"},
+			display: "Config:\n{\"port\": 8080}\nok",
+		},
+		{
+			name:    "Multi-line JSON that is not a tool call",
+			chunks:  []string{"{\n", `  "port": 8080`, "\n}\n"},
+			display: "{\n  \"port\": 8080\n}\n",
+		},
+		{
+			name:    "Code block opening brace",
+			chunks:  []string{"func main()\n", "{\n", "\tfmt.Println()\n}\n"},
+			display: "func main()\n{\n\tfmt.Println()\n}\n",
+		},
+		{
+			name:    "Call cut off by end of stream",
+			chunks:  []string{`{"tool": "read_file", "args": {"pa`},
+			display: `{"tool": "read_file", "args": {"pa`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			scanner := newToolCallScanner()
+
+			var display strings.Builder
+			var names []string
+			for _, chunk := range tt.chunks {
+				text, calls := scanner.push(chunk)
+				display.WriteString(text)
+				for _, call := range calls {
+					names = append(names, call.Name)
+				}
+			}
+			display.WriteString(scanner.flush())
+
+			if display.String() != tt.display {
+				t.Errorf("Expected display %q, got %q", tt.display, display.String())
+			}
+			if strings.Join(names, ",") != strings.Join(tt.expected, ",") {
+				t.Errorf("Expected tool calls %v, got %v", tt.expected, names)
+			}
+		})
+	}
+}
@@ -13,6 +13,7 @@ type ModelRole string
 const (
 	RolePlanner  ModelRole = "planner"
 	RoleExecutor ModelRole = "executor"
+	RoleChat     ModelRole = "chat" // Interactive agent (pg agent, pg ask)
 )
 
 // ModelSpec defines a model's specifications and requirements
@@ -24,6 +25,7 @@ type ModelSpec struct {
 	Quantization  string    // Quantization format (e.g., Q4_K_M)
 	HuggingFaceID string    // HuggingFace repository ID
 	FileName      string    // GGUF filename
+	ChatTemplate  string    // Prompt format the model was trained on (chatml, zephyr, deepseek-coder, plain)
 	LocalPath     string    // Local path (set after download)
 }
 
@@ -42,6 +44,7 @@ var ModelRegistry = []ModelSpec{
 		Quantization:  "Q4_K_M",
 		HuggingFaceID: "Qwen/Qwen2.5-0.5B-Instruct-GGUF",
 		FileName:      "qwen2.5-0.5b-instruct-q4_k_m.gguf",
+		ChatTemplate:  "chatml",
 	},
 	{
 		Name:          "TinyLLaMA-1.1B",
@@ -51,6 +54,7 @@ var ModelRegistry = []ModelSpec{
 		Quantization:  "Q4_K_M",
 		HuggingFaceID: "TheBloke/TinyLlama-1.1B-Chat-v1.0-GGUF",
 		FileName:      "tinyllama-1.1b-chat-v1.0.Q4_K_M.gguf",
+		ChatTemplate:  "zephyr",
 	},
 
 	// ===== EXECUTORS =====
@@ -63,6 +67,7 @@ var ModelRegistry = []ModelSpec{
 		Quantization:  "Q4_K_M",
 		HuggingFaceID: "QuantFactory/deepseek-coder-6.7b-instruct-GGUF",
 		FileName:      "deepseek-coder-6.7b-instruct.Q4_K_M.gguf",
+		ChatTemplate:  "deepseek-coder",
 	},
 	{
 		Name:          "Qwen2.5-Coder-3B-Instruct",
@@ -72,6 +77,7 @@ var ModelRegistry = []ModelSpec{
 		Quantization:  "Q4_K_M",
 		HuggingFaceID: "Qwen/Qwen2.5-Coder-3B-Instruct-GGUF",
 		FileName:      "qwen2.5-coder-3b-instruct-q4_k_m.gguf",
+		ChatTemplate:  "chatml",
 	},
 	{
 		Name:          "Qwen2.5-Coder-1.5B-Instruct",
@@ -81,6 +87,7 @@ var ModelRegistry = []ModelSpec{
 		Quantization:  "Q4_K_M",
 		HuggingFaceID: "Qwen/Qwen2.5-Coder-1.5B-Instruct-GGUF",
 		FileName:      "qwen2.5-coder-1.5b-instruct-q4_k_m.gguf",
+		ChatTemplate:  "chatml",
 	},
 	{
 		Name:          "StarCoder-Base-1B",
@@ -90,6 +97,7 @@ var ModelRegistry = []ModelSpec{
 		Quantization:  "Q4_K_M",
 		HuggingFaceID: "bigcode/starcoderbase-1b",
 		FileName:      "starcoderbase-1b.Q4_K_M.gguf",
+		ChatTemplate:  "plain",
 	},
 }
 
@@ -1,9 +1,12 @@
 package orchestrator
 
 import (
+	"context"
 	"fmt"
 	"os/exec"
+	"time"
 
+	"github.com/yourusername/playground/internal/llm"
 	"github.com/yourusername/playground/internal/model"
 	"github.com/yourusername/playground/internal/system"
 )
@@ -13,6 +16,8 @@ type Orchestrator struct {
 	systemInfo *system.SystemInfo
 	planner    *model.ModelSpec
 	executor   *model.ModelSpec
+	generation map[model.ModelRole]llm.GenerationOptions // Per-role sampling overrides
+	timeout    time.Duration                             // Limit for a single model call (0 = no limit)
 }
 
 // New creates a new orchestrator with automatic model selection
@@ -124,6 +129,40 @@ func (o *Orchestrator) SetModelPaths(plannerPath, executorPath string) {
 	o.executor.LocalPath = executorPath
 }
 
+// SetGenerationOptions overrides sampling settings for the planner or executor
+func (o *Orchestrator) SetGenerationOptions(role model.ModelRole, opts llm.GenerationOptions) {
+	if o.generation == nil {
+		o.generation = map[model.ModelRole]llm.GenerationOptions{}
+	}
+	o.generation[role] = opts
+}
+
+// SetRequestTimeout limits each planner and executor call; 0 means no limit.
+// Cancelling the context passed to Execute always stops the current call.
+func (o *Orchestrator) SetRequestTimeout(timeout time.Duration) {
+	o.timeout = timeout
+}
+
+// requestContext derives the context for a single model call
+func (o *Orchestrator) requestContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if o.timeout > 0 {
+		return context.WithTimeout(ctx, o.timeout)
+	}
+	return context.WithCancel(ctx)
+}
+
+// generationOptions resolves sampling settings for a role: the defaults, a
+// role-specific output length and all CPU cores, then any overrides
+func (o *Orchestrator) generationOptions(role model.ModelRole, maxTokens int) llm.GenerationOptions {
+	threads := o.systemInfo.CPUCores
+
+	opts := llm.DefaultGenerationOptions().Merge(llm.GenerationOptions{
+		MaxTokens: &maxTokens,
+		Threads:   &threads,
+	})
+	return opts.Merge(o.generation[role])
+}
+
 // NeedsDownload checks if any models need to be downloaded
 func (o *Orchestrator) NeedsDownload() (plannerNeeded, executorNeeded bool) {
 	plannerNeeded = o.planner.LocalPath == ""
@@ -1,8 +1,11 @@
 package orchestrator
 
 import (
+	"context"
 	"testing"
+	"time"
 
+	"github.com/yourusername/playground/internal/llm"
 	"github.com/yourusername/playground/internal/model"
 )
 
@@ -298,3 +301,49 @@ func contains(s, substr string) bool {
 	}
 	return false
 }
+
+func TestGenerationOptions(t *testing.T) {
+	planner := &model.ModelSpec{Name: "Test Planner", Role: model.RolePlanner}
+	executor := &model.ModelSpec{Name: "Test Executor", Role: model.RoleExecutor}
+
+	orch, err := NewWithModels(planner, executor)
+	if err != nil {
+		t.Fatalf("NewWithModels() failed: %v", err)
+	}
+
+	seed := 42
+	orch.SetGenerationOptions(model.RolePlanner, llm.GenerationOptions{Seed: &seed})
+
+	plannerOpts := orch.generationOptions(model.RolePlanner, 512)
+	if plannerOpts.Seed == nil || *plannerOpts.Seed != 42 {
+		t.Errorf("Expected planner seed override, got %v", plannerOpts.Seed)
+	}
+	if *plannerOpts.MaxTokens != 512 || *plannerOpts.Threads != orch.systemInfo.CPUCores {
+		t.Errorf("Expected role defaults, got max_tokens=%d threads=%d", *plannerOpts.MaxTokens, *plannerOpts.Threads)
+	}
+
+	executorOpts := orch.generationOptions(model.RoleExecutor, 2048)
+	if executorOpts.Seed != nil {
+		t.Errorf("Planner override leaked into executor: seed=%d", *executorOpts.Seed)
+	}
+}
+
+func TestRequestContext(t *testing.T) {
+	orch, err := NewWithModels(&model.ModelSpec{Name: "Test Planner"}, &model.ModelSpec{Name: "Test Executor"})
+	if err != nil {
+		t.Fatalf("NewWithModels() failed: %v", err)
+	}
+
+	ctx, cancel := orch.requestContext(context.Background())
+	if _, ok := ctx.Deadline(); ok {
+		t.Error("Expected no deadline without a request timeout")
+	}
+	cancel()
+
+	orch.SetRequestTimeout(5 * time.Minute)
+	ctx, cancel = orch.requestContext(context.Background())
+	defer cancel()
+	if deadline, ok := ctx.Deadline(); !ok || time.Until(deadline) > 5*time.Minute {
+		t.Errorf("Expected a deadline within the request timeout, got %v", deadline)
+	}
+}
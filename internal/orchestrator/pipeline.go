@@ -1,12 +1,16 @@
 package orchestrator
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
 	"fmt"
 	"os"
 	"os/exec"
-	"time"
+	"strings"
 
+	"github.com/yourusername/playground/internal/llm"
+	"github.com/yourusername/playground/internal/model"
 	"github.com/yourusername/playground/internal/system"
 )
 
@@ -18,6 +22,18 @@ type PlannerOutput struct {
 	FilesToInspect []string `json:"files_to_inspect"`
 }
 
+// plannerSchema is the JSON schema of PlannerOutput, used to constrain the planner's output
+var plannerSchema = map[string]interface{}{
+	"type": "object",
+	"properties": map[string]interface{}{
+		"goal":             map[string]interface{}{"type": "string"},
+		"constraints":      map[string]interface{}{"type": "array", "items": map[string]interface{}{"type": "string"}},
+		"steps":            map[string]interface{}{"type": "array", "items": map[string]interface{}{"type": "string"}},
+		"files_to_inspect": map[string]interface{}{"type": "array", "items": map[string]interface{}{"type": "string"}},
+	},
+	"required": []string{"goal", "constraints", "steps", "files_to_inspect"},
+}
+
 // ExecutorOutput represents the output from the executor model
 type ExecutorOutput struct {
 	Response  string
@@ -25,7 +41,8 @@ type ExecutorOutput struct {
 }
 
 // Execute runs the two-stage pipeline: planner → executor
-func (o *Orchestrator) Execute(userInput string) (string, error) {
+// Cancelling ctx stops whichever model is currently running
+func (o *Orchestrator) Execute(ctx context.Context, userInput string) (string, error) {
 	// Validate models can fit in RAM
 	if err := o.ValidateModels(); err != nil {
 		return "", err
@@ -40,7 +57,7 @@ func (o *Orchestrator) Execute(userInput string) (string, error) {
 	// Force GC before loading planner
 	system.ForceGC()
 
-	plan, err := o.runPlanner(userInput)
+	plan, err := o.runPlanner(ctx, userInput)
 	if err != nil {
 		return "", fmt.Errorf("planner failed: %w", err)
 	}
@@ -49,7 +66,7 @@ func (o *Orchestrator) Execute(userInput string) (string, error) {
 	system.ForceGC()
 
 	// Stage 2: Execution
-	result, err := o.runExecutor(plan, userInput)
+	result, err := o.runExecutor(ctx, plan, userInput)
 	if err != nil {
 		return "", fmt.Errorf("executor failed: %w", err)
 	}
@@ -61,7 +78,7 @@ func (o *Orchestrator) Execute(userInput string) (string, error) {
 }
 
 // runPlanner executes the planner model
-func (o *Orchestrator) runPlanner(userInput string) (string, error) {
+func (o *Orchestrator) runPlanner(ctx context.Context, userInput string) (string, error) {
 	if o.planner.LocalPath == "" {
 		return "", fmt.Errorf("planner model not downloaded: %s", o.planner.Name)
 	}
@@ -71,36 +88,47 @@ func (o *Orchestrator) runPlanner(userInput string) (string, error) {
 		return "", fmt.Errorf("planner model file not found: %s", o.planner.LocalPath)
 	}
 
-	// Build planner prompt
-	prompt := buildPlannerPrompt(userInput)
+	// Build planner prompt in the planner's chat format
+	tmpl := chatTemplate(o.planner)
+	prompt := tmpl.Format("", []llm.Message{{Role: "user", Content: buildPlannerPrompt(userInput)}})
 
 	// Run llama-cli with planner model
-	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	ctx, cancel := o.requestContext(ctx)
 	defer cancel()
 
-	cmd := exec.CommandContext(ctx, "llama-cli",
+	opts := o.generationOptions(model.RolePlanner, 512)
+
+	args := []string{
 		"--model", o.planner.LocalPath,
 		"--prompt", prompt,
 		"--ctx-size", "2048",
-		"--n-predict", "512",
-		"--temp", "0.1",
-		"--top-k", "40",
-		"--top-p", "0.9",
-		"--threads", fmt.Sprintf("%d", o.systemInfo.CPUCores),
 		"--no-display-prompt",
 		"--log-disable",
-	)
+		"--grammar", llm.SchemaGrammar(plannerSchema), // Only a valid PlannerOutput can be generated
+	}
+	cmd := exec.CommandContext(ctx, "llama-cli", append(args, opts.LlamaCLIArgs()...)...)
 
-	output, err := cmd.CombinedOutput()
-	if err != nil {
-		return "", fmt.Errorf("llama-cli failed: %w\nOutput: %s", err, string(output))
+	// Keep stderr apart so diagnostics can't corrupt the JSON
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("llama-cli failed: %w\nOutput: %s", err, stderr.String())
 	}
 
-	return string(output), nil
+	plan := strings.TrimSpace(tmpl.TrimAtStop(stdout.String(), opts.Stop...))
+
+	var parsed PlannerOutput
+	if err := json.Unmarshal([]byte(plan), &parsed); err != nil {
+		return "", fmt.Errorf("planner output is not valid plan JSON: %w\nOutput: %s", err, plan)
+	}
+
+	return plan, nil
 }
 
 // runExecutor executes the executor model
-func (o *Orchestrator) runExecutor(plan, userInput string) (string, error) {
+func (o *Orchestrator) runExecutor(ctx context.Context, plan, userInput string) (string, error) {
 	if o.executor.LocalPath == "" {
 		return "", fmt.Errorf("executor model not downloaded: %s", o.executor.Name)
 	}
@@ -110,32 +138,40 @@ func (o *Orchestrator) runExecutor(plan, userInput string) (string, error) {
 		return "", fmt.Errorf("executor model file not found: %s", o.executor.LocalPath)
 	}
 
-	// Build executor prompt
-	prompt := buildExecutorPrompt(plan, userInput)
+	// Build executor prompt in the executor's chat format
+	tmpl := chatTemplate(o.executor)
+	prompt := tmpl.Format("", []llm.Message{{Role: "user", Content: buildExecutorPrompt(plan, userInput)}})
 
 	// Run llama-cli with executor model
-	ctx, cancel := context.WithTimeout(context.Background(), 120*time.Second)
+	ctx, cancel := o.requestContext(ctx)
 	defer cancel()
 
-	cmd := exec.CommandContext(ctx, "llama-cli",
+	opts := o.generationOptions(model.RoleExecutor, 2048)
+
+	args := []string{
 		"--model", o.executor.LocalPath,
 		"--prompt", prompt,
 		"--ctx-size", "4096",
-		"--n-predict", "2048",
-		"--temp", "0.1",
-		"--top-k", "40",
-		"--top-p", "0.9",
-		"--threads", fmt.Sprintf("%d", o.systemInfo.CPUCores),
 		"--no-display-prompt",
 		"--log-disable",
-	)
+	}
+	cmd := exec.CommandContext(ctx, "llama-cli", append(args, opts.LlamaCLIArgs()...)...)
 
 	output, err := cmd.CombinedOutput()
 	if err != nil {
 		return "", fmt.Errorf("llama-cli failed: %w\nOutput: %s", err, string(output))
 	}
 
-	return string(output), nil
+	return tmpl.TrimAtStop(string(output), opts.Stop...), nil
+}
+
+// chatTemplate returns the prompt format for a model, falling back to
+// detection from the GGUF file when the spec doesn't name one
+func chatTemplate(spec *model.ModelSpec) *llm.ChatTemplate {
+	if tmpl, err := llm.GetTemplate(spec.ChatTemplate); err == nil {
+		return tmpl
+	}
+	return llm.TemplateForModel(spec.LocalPath)
 }
 
 // buildPlannerPrompt creates the prompt for the planner model
@@ -3,84 +3,331 @@ package patch
 import (
 	"fmt"
 	"os"
-	"os/exec"
 	"path/filepath"
+	"strings"
 )
 
-// Apply applies a validated patch to the repository
-// This is the ONLY place in the codebase that modifies files
-func Apply(repoRoot string, p Patch) error {
-	// Validate first - safety check
-	if err := Validate(repoRoot, p); err != nil {
-		return fmt.Errorf("patch validation failed: %w", err)
-	}
+// Options control how closely a hunk must match the file
+type Options struct {
+	// Fuzz is how many context lines may be ignored at each end of a hunk, like
+	// patch(1)'s --fuzz. 0 requires every context line to match. At least one
+	// context line is always kept on each side of a change that has context.
+	Fuzz int
+
+	// MaxOffset is how many lines from where its header puts it a hunk may be
+	// found; 0 means anywhere in the file
+	MaxOffset int
+}
 
-	targetFile := filepath.Join(repoRoot, p.FilePath)
+// DefaultOptions match patch(1)'s default fuzz, and keep hunks near their headers
+var DefaultOptions = Options{Fuzz: 2, MaxOffset: 100}
 
-	// Create backup for rollback
-	var backupPath string
-	var needsBackup bool
+// Report describes how a patch applied, file by file and hunk by hunk
+type Report struct {
+	Files []FileReport
+}
+
+// FileReport is the outcome for one file of a patch
+type FileReport struct {
+	Path    string      // File after the patch; the deleted file for a deletion
+	OldPath string      // File before the patch, when it is renamed
+	Created bool        // The patch creates the file
+	Deleted bool        // The patch deletes the file
+	Mode    os.FileMode // New permissions, when the patch changes them
+	Hunks   []HunkResult
+}
 
-	if _, err := os.Stat(targetFile); err == nil {
-		needsBackup = true
-		backupPath = targetFile + ".backup"
+// HunkResult is the outcome of one hunk
+type HunkResult struct {
+	Header  string
+	Applied bool
+	Line    int    // 1-based line of the original file where the hunk matched
+	Offset  int    // Lines between where the header put the hunk and where it matched
+	Fuzz    int    // Context lines ignored at each end to make it match
+	Reason  string // Why the hunk failed
+}
+
+func (h HunkResult) String() string {
+	if !h.Applied {
+		return fmt.Sprintf("%s FAILED: %s", h.Header, h.Reason)
+	}
+	s := fmt.Sprintf("%s applied at line %d", h.Header, h.Line)
+	switch {
+	case h.Offset != 0 && h.Fuzz > 0:
+		s += fmt.Sprintf(" (offset %+d, fuzz %d)", h.Offset, h.Fuzz)
+	case h.Offset != 0:
+		s += fmt.Sprintf(" (offset %+d)", h.Offset)
+	case h.Fuzz > 0:
+		s += fmt.Sprintf(" (fuzz %d)", h.Fuzz)
+	}
+	return s
+}
 
-		if err := copyFile(targetFile, backupPath); err != nil {
-			return fmt.Errorf("failed to create backup: %w", err)
+// Summary describes what happens to the file, e.g. "old.go renamed to new.go"
+func (f FileReport) Summary() string {
+	s := f.Path
+	switch {
+	case f.Created:
+		s += " created"
+	case f.Deleted:
+		s += " deleted"
+	case f.OldPath != "":
+		s = fmt.Sprintf("%s renamed to %s", f.OldPath, f.Path)
+	}
+	if f.Mode != 0 {
+		s += fmt.Sprintf(" (mode %04o)", f.Mode)
+	}
+	return s
+}
+
+// Inexact reports whether any hunk needed an offset or fuzz to apply
+func (r *Report) Inexact() bool {
+	for _, f := range r.Files {
+		for _, h := range f.Hunks {
+			if h.Applied && (h.Offset != 0 || h.Fuzz > 0) {
+				return true
+			}
 		}
 	}
+	return false
+}
+
+func (r *Report) String() string {
+	var out strings.Builder
+	for _, f := range r.Files {
+		fmt.Fprintf(&out, "%s\n", f.Summary())
+		for i, h := range f.Hunks {
+			fmt.Fprintf(&out, "  hunk %d: %s\n", i+1, h)
+		}
+	}
+	return out.String()
+}
 
-	// Apply patch using the `patch` command
-	// Write diff to temporary file
-	tempDiff, err := os.CreateTemp("", "pg-patch-*.diff")
+// Apply applies a single patch to the repository. Every file it changes is
+// worked out in memory first, so a patch with a hunk that does not apply
+// changes nothing. Use a Transaction to apply several patches as one.
+func Apply(repoRoot string, p Patch, opts Options) (*Report, error) {
+	tx := NewTransaction(repoRoot, opts)
+	report, err := tx.Stage(p)
 	if err != nil {
-		cleanup(backupPath)
-		return fmt.Errorf("failed to create temp diff file: %w", err)
+		return report, err
 	}
-	defer os.Remove(tempDiff.Name())
+	return report, tx.Commit()
+}
+
+// DryRun reports how a patch would apply, without touching the repository
+func DryRun(repoRoot string, p Patch, opts Options) (*Report, error) {
+	return newTree(repoRoot).apply(p, opts)
+}
+
+// file is a text file as lines, remembering how it ends its lines
+type file struct {
+	lines    []string // Without line endings
+	crlf     bool     // Lines end in "\r\n"
+	finalEOL bool     // The last line has a line ending
+}
 
-	if _, err := tempDiff.WriteString(p.UnifiedDiff); err != nil {
-		cleanup(backupPath)
-		return fmt.Errorf("failed to write diff: %w", err)
+func splitFile(content string) *file {
+	f := &file{crlf: strings.Contains(content, "\r\n")}
+	if f.crlf {
+		content = strings.ReplaceAll(content, "\r\n", "\n")
+	}
+	f.finalEOL = strings.HasSuffix(content, "\n")
+	if content != "" {
+		f.lines = strings.Split(strings.TrimSuffix(content, "\n"), "\n")
+	}
+	return f
+}
+
+func (f *file) String() string {
+	if len(f.lines) == 0 {
+		return ""
+	}
+	eol := "\n"
+	if f.crlf {
+		eol = "\r\n"
 	}
-	tempDiff.Close()
+	s := strings.Join(f.lines, eol)
+	if f.finalEOL {
+		s += eol
+	}
+	return s
+}
 
-	// Apply patch
-	cmd := exec.Command("patch", "-p0", "-i", tempDiff.Name())
-	cmd.Dir = repoRoot
+// applyHunks applies hunks in order, each searched for nearest to where its
+// header puts it, first exactly and then with growing fuzz.
+// Every hunk is tried so the report is complete; the first failure is returned
+// as a *ContextError.
+func (f *file) applyHunks(hunks []Hunk, opts Options, report *FileReport) error {
+	var out []string
+	consumed := 0 // Original lines already copied to out or replaced
+	var firstErr error
 
-	output, err := cmd.CombinedOutput()
-	if err != nil {
-		// Patch failed - restore backup
-		if needsBackup {
-			if restoreErr := copyFile(backupPath, targetFile); restoreErr != nil {
-				return fmt.Errorf("patch failed and backup restoration failed: %w (original error: %v)",
-					restoreErr, err)
+	for i := range hunks {
+		h := &hunks[i]
+		expected := max(h.OldStart-1, 0)
+		if h.OldLines == 0 && h.OldStart > 0 {
+			expected = h.OldStart // An empty old range names the line before it
+		}
+		result := HunkResult{Header: h.Header()}
+
+		at, fuzz, ok := f.locate(h, expected, consumed, opts)
+		if !ok {
+			result.Reason = "context does not match the file"
+			report.Hunks = append(report.Hunks, result)
+			if firstErr == nil {
+				firstErr = f.mismatch(i+1, h, expected)
 			}
+			continue
 		}
-		cleanup(backupPath)
-		return fmt.Errorf("patch application failed: %w\nOutput: %s", err, string(output))
-	}
 
-	// Success - remove backup
-	cleanup(backupPath)
+		old, replacement := trimFuzz(h, fuzz)
+		result.Applied, result.Fuzz = true, fuzz
+		result.Line = at + 1
+		result.Offset = at - (expected + leadingTrimmed(h, fuzz))
+		report.Hunks = append(report.Hunks, result)
+
+		out = append(out, f.lines[consumed:at]...)
+		out = append(out, replacement...)
+		consumed = at + len(old)
+
+		// A hunk that changes the file's last line decides whether the file ends in
+		// a newline; if it leaves no lines there, the new last line had one. When
+		// the last line is unchanged context, the file keeps its ending.
+		if consumed == len(f.lines) && trailingContext(h) == 0 {
+			f.finalEOL = len(replacement) == 0 || !h.newNoNewline()
+		}
+	}
 
+	if firstErr != nil {
+		return firstErr
+	}
+	f.lines = append(out, f.lines[consumed:]...)
 	return nil
 }
 
-// copyFile copies a file from src to dst
-func copyFile(src, dst string) error {
-	data, err := os.ReadFile(src)
-	if err != nil {
-		return err
+// locate finds where a hunk applies: the match nearest to expected, at or after
+// from and within opts.MaxOffset of it, trying each fuzz level in turn. It
+// returns the 0-based line and the fuzz used.
+func (f *file) locate(h *Hunk, expected, from int, opts Options) (at, fuzz int, ok bool) {
+	for fuzz = 0; fuzz <= opts.Fuzz; fuzz++ {
+		if fuzz > 0 && fuzz > fuzzable(leadingContext(h)) && fuzz > fuzzable(trailingContext(h)) {
+			break // Nothing more to ignore
+		}
+		old, _ := trimFuzz(h, fuzz)
+		if len(old) == 0 && fuzz > 0 {
+			break // Matching nothing would apply the hunk anywhere
+		}
+		want := expected + leadingTrimmed(h, fuzz)
+
+		for distance := 0; opts.MaxOffset == 0 || distance <= opts.MaxOffset; distance++ {
+			before, after := want-distance, want+distance
+			if before < from && after+len(old) > len(f.lines) {
+				break
+			}
+			if before >= from && matchesAt(f.lines, old, before) {
+				return before, fuzz, true
+			}
+			if distance > 0 && after >= from && matchesAt(f.lines, old, after) {
+				return after, fuzz, true
+			}
+		}
 	}
+	return 0, 0, false
+}
 
-	return os.WriteFile(dst, data, 0644)
+// trimFuzz returns a hunk's old and new lines with up to fuzz context lines
+// dropped from each end, keeping at least one on each side that has any
+func trimFuzz(h *Hunk, fuzz int) (old, new []string) {
+	lead := leadingTrimmed(h, fuzz)
+	trail := min(fuzz, fuzzable(trailingContext(h)), len(h.Lines)-lead)
+	lines := h.Lines[lead : len(h.Lines)-trail]
+	for _, l := range lines {
+		if l.Kind != Added {
+			old = append(old, l.Text)
+		}
+		if l.Kind != Removed {
+			new = append(new, l.Text)
+		}
+	}
+	return old, new
+}
+
+func leadingTrimmed(h *Hunk, fuzz int) int { return min(fuzz, fuzzable(leadingContext(h))) }
+
+// fuzzable is how many of n context lines on one side fuzz may ignore: all but
+// one, so a hunk stays anchored to something it expects
+func fuzzable(n int) int { return max(n-1, 0) }
+
+// leadingContext counts the context lines before a hunk's first change
+func leadingContext(h *Hunk) int {
+	n := 0
+	for n < len(h.Lines) && h.Lines[n].Kind == Context {
+		n++
+	}
+	return n
 }
 
-// cleanup removes a file, ignoring errors
-func cleanup(path string) {
-	if path != "" {
-		os.Remove(path)
+// trailingContext counts the context lines after a hunk's last change
+func trailingContext(h *Hunk) int {
+	n := 0
+	for n < len(h.Lines) && h.Lines[len(h.Lines)-1-n].Kind == Context {
+		n++
+	}
+	return n
+}
+
+// mismatch explains why a hunk does not apply: the first line that differs
+// where its header puts it, and the file's actual content there
+func (f *file) mismatch(number int, h *Hunk, expected int) *ContextError {
+	old := h.old()
+	offset := 0
+	for offset < len(old)-1 && expected+offset < len(f.lines) && f.lines[expected+offset] == old[offset] {
+		offset++
+	}
+	lineIndex := expected + offset
+
+	e := &ContextError{
+		Hunk:    number,
+		Header:  h.Header(),
+		Line:    lineIndex + 1,
+		PastEOF: lineIndex >= len(f.lines),
+		Nearby:  numberedLines(f.lines, expected+1-nearbyLines, expected+len(old)+nearbyLines),
+	}
+	if len(old) > 0 {
+		e.Expected = old[offset]
+		e.FoundAt = findLine(f.lines, e.Expected)
+	}
+	if !e.PastEOF {
+		e.Actual = f.lines[lineIndex]
+	}
+	return e
+}
+
+// writeAtomic replaces path with data: it writes a temporary file next to it
+// and renames it into place, so a failure never leaves a half-written file
+func writeAtomic(path string, data []byte, mode os.FileMode) error {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(path)+".pg-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name()) // No-op once renamed
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(mode); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
 	}
+	return os.Rename(tmp.Name(), path)
 }
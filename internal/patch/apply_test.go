@@ -0,0 +1,414 @@
+package patch
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestApply covers the diff shapes the agent emits, well-formed or not
+func TestApply(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string // Initial content of main.go; empty to leave it out
+		diff    string
+		opts    Options
+		want    string   // Content afterwards
+		report  []string // Per-hunk report lines
+		wantErr string
+	}{
+		{
+			name:   "a/ b/ prefixes",
+			file:   mainGo,
+			diff:   "--- a/main.go\n+++ b/main.go\n@@ -5,3 +5,3 @@\n func main() {\n-\tfmt.Println(\"hello\")\n+\tfmt.Println(\"hi\")\n }\n",
+			want:   strings.Replace(mainGo, "hello", "hi", 1),
+			report: []string{"@@ -5,3 +5,3 @@ applied at line 5"},
+		},
+		{
+			name:   "no prefixes, git headers and wrong line numbers",
+			file:   mainGo,
+			diff:   "diff --git a/main.go b/main.go\nindex 83db48f..bf269f4 100644\n--- main.go\n+++ main.go\n@@ -12,3 +12,3 @@\n func main() {\n-\tfmt.Println(\"hello\")\n+\tfmt.Println(\"hi\")\n }\n",
+			want:   strings.Replace(mainGo, "hello", "hi", 1),
+			report: []string{"@@ -12,3 +12,3 @@ applied at line 5 (offset -7)"},
+		},
+		{
+			name:   "miscounted header and blank context without its space",
+			file:   mainGo,
+			diff:   "--- a/main.go\n+++ b/main.go\n@@ -1,9 +1,2 @@\n package main\n\n-import \"fmt\"\n+import \"os\"\n",
+			want:   strings.Replace(mainGo, "\"fmt\"", "\"os\"", 1),
+			report: []string{"@@ -1,3 +1,3 @@ applied at line 1"},
+		},
+		{
+			name:   "stale context within fuzz",
+			file:   mainGo,
+			diff:   "--- a/main.go\n+++ b/main.go\n@@ -4,4 +4,4 @@\n // main greets\n func main() {\n-\tfmt.Println(\"hello\")\n+\tfmt.Println(\"hi\")\n }\n",
+			opts:   Options{Fuzz: 2},
+			want:   strings.Replace(mainGo, "hello", "hi", 1),
+			report: []string{"@@ -4,4 +4,4 @@ applied at line 5 (fuzz 1)"},
+		},
+		{
+			name:    "fuzz keeps a context line on each side",
+			file:    "func a() {\n\tb()\n}\n\nfunc c() {\n\td()\n}\n",
+			diff:    "--- a/main.go\n+++ b/main.go\n@@ -2,3 +2,3 @@\n x\n-}\n+})\n y\n",
+			opts:    Options{Fuzz: 2},
+			report:  []string{"@@ -2,3 +2,3 @@ FAILED: context does not match the file"},
+			wantErr: "hunk 1",
+		},
+		{
+			name:    "match too far from the header",
+			file:    strings.Repeat("// filler\n", 20) + "var x = 1\n",
+			diff:    "--- a/main.go\n+++ b/main.go\n@@ -1 +1 @@\n-var x = 1\n+var x = 2\n",
+			opts:    Options{MaxOffset: 10},
+			report:  []string{"@@ -1 +1 @@ FAILED: context does not match the file"},
+			wantErr: "hunk 1",
+		},
+		{
+			name:   "match within the offset limit",
+			file:   strings.Repeat("// filler\n", 20) + "var x = 1\n",
+			diff:   "--- a/main.go\n+++ b/main.go\n@@ -15 +15 @@\n-var x = 1\n+var x = 2\n",
+			opts:   Options{MaxOffset: 10},
+			want:   strings.Repeat("// filler\n", 20) + "var x = 2\n",
+			report: []string{"@@ -15 +15 @@ applied at line 21 (offset +6)"},
+		},
+		{
+			name:    "stale context without fuzz",
+			file:    mainGo,
+			diff:    "--- a/main.go\n+++ b/main.go\n@@ -4,4 +4,4 @@\n // main greets\n func main() {\n-\tfmt.Println(\"hello\")\n+\tfmt.Println(\"hi\")\n }\n",
+			report:  []string{"@@ -4,4 +4,4 @@ FAILED: context does not match the file"},
+			wantErr: "hunk 1 (@@ -4,4 +4,4 @@) line 4: expected \"// main greets\", found \"\"",
+		},
+		{
+			name: "two hunks, one failing",
+			file: mainGo + "\nfunc helper() {\n\treturn\n}\n",
+			diff: "--- a/main.go\n+++ b/main.go\n@@ -1,3 +1,3 @@\n-package main\n+package app\n \n import \"fmt\"\n" +
+				"@@ -9,3 +9,3 @@\n func helper() {\n-\treturn nil\n+\treturn\n }\n",
+			report: []string{
+				"@@ -1,3 +1,3 @@ applied at line 1",
+				"@@ -9,3 +9,3 @@ FAILED: context does not match the file",
+			},
+			wantErr: "hunk 2",
+		},
+		{
+			name:   "hunks in order with a shifting offset",
+			file:   "a\nb\nc\nd\ne\nf\ng\nh\ni\nj\n",
+			diff:   "--- a/main.go\n+++ b/main.go\n@@ -1,2 +1,3 @@\n a\n+a2\n b\n@@ -8,3 +9,2 @@\n h\n-i\n j\n",
+			want:   "a\na2\nb\nc\nd\ne\nf\ng\nh\nj\n",
+			report: []string{"@@ -1,2 +1,3 @@ applied at line 1", "@@ -8,3 +9,2 @@ applied at line 8"},
+		},
+		{
+			name: "add a final newline",
+			file: "package main\n\nvar x = 1",
+			diff: "--- a/main.go\n+++ b/main.go\n@@ -3 +3 @@\n-var x = 1\n\\ No newline at end of file\n+var x = 1\n",
+			want: "package main\n\nvar x = 1\n",
+		},
+		{
+			name: "keep a missing final newline",
+			file: "package main\n\nvar x = 1",
+			diff: "--- a/main.go\n+++ b/main.go\n@@ -3 +3 @@\n-var x = 1\n\\ No newline at end of file\n+var x = 2\n\\ No newline at end of file\n",
+			want: "package main\n\nvar x = 2",
+		},
+		{
+			name: "missing final newline kept under unchanged context",
+			file: "a\nb",
+			diff: "--- a/main.go\n+++ b/main.go\n@@ -1,2 +1,2 @@\n-a\n+A\n b\n",
+			want: "A\nb",
+		},
+		{
+			name: "final newline kept under unchanged context",
+			file: "a\nb\n",
+			diff: "--- a/main.go\n+++ b/main.go\n@@ -1,2 +1,2 @@\n-a\n+A\n b\n\\ No newline at end of file\n",
+			want: "A\nb\n",
+		},
+		{
+			name: "CRLF line endings",
+			file: "package main\r\n\r\nvar x = 1\r\n",
+			diff: "--- a/main.go\n+++ b/main.go\n@@ -1,3 +1,3 @@\n package main\n \n-var x = 1\n+var x = 2\n",
+			want: "package main\r\n\r\nvar x = 2\r\n",
+		},
+		{
+			name:   "new file",
+			diff:   "--- /dev/null\n+++ b/main.go\n@@ -0,0 +1,3 @@\n+package main\n+\n+func main() {}\n",
+			want:   "package main\n\nfunc main() {}\n",
+			report: []string{"@@ -0,0 +1,3 @@ applied at line 1"},
+		},
+		{
+			name:    "new file that exists",
+			file:    mainGo,
+			diff:    "--- /dev/null\n+++ b/main.go\n@@ -0,0 +1 @@\n+package main\n",
+			wantErr: "target file already exists: main.go",
+		},
+		{
+			name:    "malformed hunk header",
+			file:    mainGo,
+			diff:    "--- a/main.go\n+++ b/main.go\n@@ -x +1 @@\n-package main\n+package app\n",
+			wantErr: "malformed hunk header",
+		},
+		{
+			name:    "second file missing leaves the first untouched",
+			file:    mainGo,
+			diff:    "--- a/main.go\n+++ b/main.go\n@@ -1 +1 @@\n-package main\n+package app\n--- a/other.go\n+++ b/other.go\n@@ -1 +1 @@\n-a\n+b\n",
+			wantErr: "target file does not exist: other.go",
+		},
+		{
+			name: "removed SQL comment is not a header",
+			file: "-- setup\n-- seed\nSELECT 1;\n",
+			diff: "--- a/main.go\n+++ b/main.go\n@@ -1,3 +1,3 @@\n--- setup\n+++ new\n -- seed\n SELECT 1;\n",
+			want: "++ new\n-- seed\nSELECT 1;\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := t.TempDir()
+			path := filepath.Join(root, "main.go")
+			if tt.file != "" {
+				if err := os.WriteFile(path, []byte(tt.file), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			report, err := Apply(root, Patch{FilePath: "main.go", UnifiedDiff: tt.diff}, tt.opts)
+			if tt.report != nil {
+				var got []string
+				for _, f := range report.Files {
+					for _, h := range f.Hunks {
+						got = append(got, h.String())
+					}
+				}
+				if strings.Join(got, "\n") != strings.Join(tt.report, "\n") {
+					t.Errorf("report:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(tt.report, "\n"))
+				}
+			}
+
+			data, _ := os.ReadFile(path)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("expected error containing %q, got: %v", tt.wantErr, err)
+				}
+				if string(data) != tt.file {
+					t.Errorf("failed patch changed the file:\n%s", data)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if string(data) != tt.want {
+				t.Errorf("got:\n%q\nwant:\n%q", data, tt.want)
+			}
+		})
+	}
+}
+
+func TestApplyKeepsFileMode(t *testing.T) {
+	root := t.TempDir()
+	path := filepath.Join(root, "run.sh")
+	if err := os.WriteFile(path, []byte("#!/bin/sh\necho hi\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	p := Patch{FilePath: "run.sh", UnifiedDiff: "--- a/run.sh\n+++ b/run.sh\n@@ -2 +2 @@\n-echo hi\n+echo bye\n"}
+	if _, err := Apply(root, p, DefaultOptions); err != nil {
+		t.Fatal(err)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0755 {
+		t.Errorf("expected mode 0755, got %v", info.Mode().Perm())
+	}
+	if entries, _ := os.ReadDir(root); len(entries) != 1 {
+		t.Errorf("expected no leftover temporary files, got %v", entries)
+	}
+}
+
+func TestApplyDeletesFile(t *testing.T) {
+	root := t.TempDir()
+	path := filepath.Join(root, "old.go")
+	if err := os.WriteFile(path, []byte("package old\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	report, err := Apply(root, Patch{FilePath: "old.go", UnifiedDiff: "--- a/old.go\n+++ /dev/null\n@@ -1 +0,0 @@\n-package old\n"}, DefaultOptions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Files) != 1 || !report.Files[0].Deleted {
+		t.Error("expected the report to record the deletion")
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("expected old.go to be deleted, got %v", err)
+	}
+}
+
+// TestApplyDeletionLeavesContents checks that a deletion only removes a file
+// whose every line it removes
+func TestApplyDeletionLeavesContents(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		diff    string
+		wantErr string
+	}{
+		{
+			name:    "hunk removes only the first line",
+			file:    "package c\n\nvar x = 1\n",
+			diff:    "--- a/c.go\n+++ /dev/null\n@@ -1 +0,0 @@\n-package c\n",
+			wantErr: "removal patch leaves c.go contents: 2 line(s)",
+		},
+		{
+			name:    "git header without hunks",
+			file:    "package c\n\nvar x = 1\n",
+			diff:    "diff --git a/c.go b/c.go\ndeleted file mode 100644\n",
+			wantErr: "removal patch leaves c.go contents: 3 line(s)",
+		},
+		{
+			name: "git header without hunks for an empty file",
+			file: "",
+			diff: "diff --git a/c.go b/c.go\ndeleted file mode 100644\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := t.TempDir()
+			path := filepath.Join(root, "c.go")
+			writeFiles(t, root, map[string]string{"c.go": tt.file})
+
+			_, err := Apply(root, Patch{UnifiedDiff: tt.diff}, DefaultOptions)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				if _, err := os.Stat(path); !os.IsNotExist(err) {
+					t.Errorf("expected c.go to be deleted, got %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("expected error containing %q, got: %v", tt.wantErr, err)
+			}
+			if data, err := os.ReadFile(path); err != nil || string(data) != tt.file {
+				t.Errorf("c.go changed: %q, %v", data, err)
+			}
+		})
+	}
+}
+
+// TestApplyMultipleFiles applies one patch that renames, deletes, creates and
+// changes the mode of files, using git's extended headers
+func TestApplyMultipleFiles(t *testing.T) {
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{
+		"old.go":  "package old\n",
+		"gone.go": "package gone\n",
+		"run.sh":  "#!/bin/sh\n",
+	})
+
+	diff := "diff --git a/old.go b/pkg/new.go\nsimilarity index 50%\nrename from old.go\nrename to pkg/new.go\n" +
+		"--- a/old.go\n+++ b/pkg/new.go\n@@ -1 +1 @@\n-package old\n+package pkg\n" +
+		"diff --git a/gone.go b/gone.go\ndeleted file mode 100644\n--- a/gone.go\n+++ /dev/null\n@@ -1 +0,0 @@\n-package gone\n" +
+		"diff --git a/tool.sh b/tool.sh\nnew file mode 100755\n--- /dev/null\n+++ b/tool.sh\n@@ -0,0 +1 @@\n+#!/bin/sh\n" +
+		"diff --git a/run.sh b/run.sh\nold mode 100644\nnew mode 100755\n"
+
+	report, err := Apply(root, Patch{UnifiedDiff: diff}, DefaultOptions)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var summaries []string
+	for _, f := range report.Files {
+		summaries = append(summaries, f.Summary())
+	}
+	want := "old.go renamed to pkg/new.go\ngone.go deleted\ntool.sh created\nrun.sh (mode 0755)"
+	if got := strings.Join(summaries, "\n"); got != want {
+		t.Errorf("report:\n%s\nwant:\n%s", got, want)
+	}
+
+	for path, want := range map[string]string{"pkg/new.go": "package pkg\n", "tool.sh": "#!/bin/sh\n", "run.sh": "#!/bin/sh\n"} {
+		if data, err := os.ReadFile(filepath.Join(root, path)); err != nil || string(data) != want {
+			t.Errorf("%s: got %q, %v; want %q", path, data, err, want)
+		}
+	}
+	for _, path := range []string{"old.go", "gone.go"} {
+		if _, err := os.Stat(filepath.Join(root, path)); !os.IsNotExist(err) {
+			t.Errorf("expected %s to be gone, got %v", path, err)
+		}
+	}
+	for _, path := range []string{"tool.sh", "run.sh"} {
+		if info, err := os.Stat(filepath.Join(root, path)); err != nil || info.Mode().Perm() != 0755 {
+			t.Errorf("expected %s to be executable, got %v, %v", path, info.Mode(), err)
+		}
+	}
+}
+
+// TestApplyAllOrNothing checks that a patch whose last file fails writes nothing
+func TestApplyAllOrNothing(t *testing.T) {
+	tests := []struct {
+		name    string
+		diff    string
+		wantErr string
+	}{
+		{
+			name: "stale hunk in the last file",
+			diff: "diff --git a/a.go b/b.go\nrename from a.go\nrename to b.go\n" +
+				"--- a/gone.go\n+++ /dev/null\n@@ -1 +0,0 @@\n-package gone\n" +
+				"--- a/main.go\n+++ b/main.go\n@@ -1 +1 @@\n-package stale\n+package app\n",
+			wantErr: "context mismatch in main.go",
+		},
+		{
+			name:    "rename onto an existing file",
+			diff:    "diff --git a/a.go b/main.go\nrename from a.go\nrename to main.go\n",
+			wantErr: "cannot rename a.go: main.go already exists",
+		},
+		{
+			name:    "same file twice",
+			diff:    "--- a/main.go\n+++ b/main.go\n@@ -1 +1 @@\n-package main\n+package app\n--- a/main.go\n+++ b/main.go\n@@ -1 +1 @@\n-package app\n+package cmd\n",
+			wantErr: "diff changes main.go more than once",
+		},
+		{
+			name:    "path outside the repository",
+			diff:    "--- a/../outside.go\n+++ b/../outside.go\n@@ -1 +1 @@\n-a\n+b\n",
+			wantErr: "path ../outside.go is outside the repository",
+		},
+		{
+			name:    "directory mode",
+			diff:    "diff --git a/main.go b/main.go\nold mode 100644\nnew mode 040000\n",
+			wantErr: "unsupported file mode 040000",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := t.TempDir()
+			files := map[string]string{"a.go": "package a\n", "gone.go": "package gone\n", "main.go": "package main\n"}
+			writeFiles(t, root, files)
+
+			_, err := Apply(root, Patch{UnifiedDiff: tt.diff}, DefaultOptions)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("expected error containing %q, got: %v", tt.wantErr, err)
+			}
+
+			for path, want := range files {
+				if data, err := os.ReadFile(filepath.Join(root, path)); err != nil || string(data) != want {
+					t.Errorf("%s changed: %q, %v", path, data, err)
+				}
+			}
+			if _, err := os.Stat(filepath.Join(root, "b.go")); !os.IsNotExist(err) {
+				t.Errorf("rename target was written: %v", err)
+			}
+		})
+	}
+}
+
+func writeFiles(t *testing.T, root string, files map[string]string) {
+	t.Helper()
+	for path, content := range files {
+		if err := os.WriteFile(filepath.Join(root, path), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,219 @@
+package patch
+
+import (
+	"fmt"
+	"strings"
+)
+
+// diffContext is the number of unchanged lines shown around each change
+const diffContext = 3
+
+// maxEditDistance bounds the Myers search; past it the changed region is
+// replaced wholesale, which is still a correct diff, just a longer one
+const maxEditDistance = 1000
+
+// edit is one line of an edit script: ' ' keeps a line, '-' removes it, '+' adds it
+type edit struct {
+	op   byte
+	line string // Including its newline, if it has one
+}
+
+// Diff returns a unified diff that turns oldText into newText in the file at path,
+// with a/ and b/ prefixes and three lines of context. It returns "" when the
+// texts are equal.
+func Diff(path, oldText, newText string) string {
+	edits := diffLines(splitKeepNewlines(oldText), splitKeepNewlines(newText))
+	return formatDiff("a/"+path, "b/"+path, edits)
+}
+
+// NewFileDiff returns a unified diff that creates the file at path with text
+func NewFileDiff(path, text string) string {
+	edits := diffLines(nil, splitKeepNewlines(text))
+	return formatDiff("/dev/null", "b/"+path, edits)
+}
+
+// splitKeepNewlines splits text into lines, each keeping its "\n"
+func splitKeepNewlines(text string) []string {
+	lines := strings.SplitAfter(text, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// diffLines returns an edit script from a to b. Common leading and trailing
+// lines are matched first, then Myers' algorithm finds a shortest script
+// for the rest.
+func diffLines(a, b []string) []edit {
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	var edits []edit
+	for _, line := range a[:prefix] {
+		edits = append(edits, edit{' ', line})
+	}
+	edits = append(edits, myers(a[prefix:len(a)-suffix], b[prefix:len(b)-suffix])...)
+	for _, line := range a[len(a)-suffix:] {
+		edits = append(edits, edit{' ', line})
+	}
+	return edits
+}
+
+// myers finds a shortest edit script from a to b (Myers, "An O(ND) Difference
+// Algorithm and Its Variations", 1986). trace keeps the furthest x reached on
+// each diagonal k in -d..d, for every d, so the path can be walked back.
+func myers(a, b []string) []edit {
+	n, m := len(a), len(b)
+	limit := min(n+m, maxEditDistance)
+	offset := limit + 1
+	v := make([]int, 2*limit+3)
+	var trace [][]int
+
+	for d := 0; d <= limit; d++ {
+		trace = append(trace, append([]int(nil), v[offset-d:offset+d+1]...))
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1] // Down: insert from b
+			} else {
+				x = v[offset+k-1] + 1 // Right: delete from a
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x, y = x+1, y+1
+			}
+			v[offset+k] = x
+			if x >= n && y >= m {
+				return backtrack(a, b, trace, d)
+			}
+		}
+	}
+
+	// Too different to search; remove everything, then add everything
+	var edits []edit
+	for _, line := range a {
+		edits = append(edits, edit{'-', line})
+	}
+	for _, line := range b {
+		edits = append(edits, edit{'+', line})
+	}
+	return edits
+}
+
+// backtrack walks the Myers trace back from the end of both inputs
+func backtrack(a, b []string, trace [][]int, d int) []edit {
+	x, y := len(a), len(b)
+	var reversed []edit
+
+	for ; d >= 0; d-- {
+		v := trace[d] // v[i] is diagonal k = i - d
+		k := x - y
+
+		var prevK int
+		if k == -d || (k != d && v[k-1+d] < v[k+1+d]) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+		prevX := 0
+		if d > 0 {
+			prevX = v[prevK+d]
+		}
+		prevY := prevX - prevK
+
+		for x > prevX && y > prevY {
+			x, y = x-1, y-1
+			reversed = append(reversed, edit{' ', a[x]})
+		}
+		if d > 0 {
+			if x == prevX {
+				reversed = append(reversed, edit{'+', b[prevY]})
+			} else {
+				reversed = append(reversed, edit{'-', a[prevX]})
+			}
+		}
+		x, y = prevX, prevY
+	}
+
+	edits := make([]edit, len(reversed))
+	for i, e := range reversed {
+		edits[len(reversed)-1-i] = e
+	}
+	return edits
+}
+
+// formatDiff renders an edit script as a unified diff, merging changes whose
+// context would overlap into one hunk
+func formatDiff(oldName, newName string, edits []edit) string {
+	var changed []int
+	for i, e := range edits {
+		if e.op != ' ' {
+			changed = append(changed, i)
+		}
+	}
+	if len(changed) == 0 {
+		return ""
+	}
+
+	// Line numbers before each edit, 1-based
+	oldLine := make([]int, len(edits)+1)
+	newLine := make([]int, len(edits)+1)
+	oldLine[0], newLine[0] = 1, 1
+	for i, e := range edits {
+		oldLine[i+1], newLine[i+1] = oldLine[i], newLine[i]
+		if e.op != '+' {
+			oldLine[i+1]++
+		}
+		if e.op != '-' {
+			newLine[i+1]++
+		}
+	}
+
+	var out strings.Builder
+	fmt.Fprintf(&out, "--- %s\n+++ %s\n", oldName, newName)
+
+	for i := 0; i < len(changed); {
+		// Extend the hunk while the next change is close enough to share context
+		j := i
+		for j+1 < len(changed) && changed[j+1]-changed[j] <= 2*diffContext+1 {
+			j++
+		}
+		start := max(changed[i]-diffContext, 0)
+		end := min(changed[j]+diffContext+1, len(edits))
+
+		oldCount := oldLine[end] - oldLine[start]
+		newCount := newLine[end] - newLine[start]
+		fmt.Fprintf(&out, "@@ -%s +%s @@\n",
+			hunkRange(oldLine[start], oldCount), hunkRange(newLine[start], newCount))
+
+		for _, e := range edits[start:end] {
+			out.WriteByte(e.op)
+			out.WriteString(e.line)
+			if !strings.HasSuffix(e.line, "\n") {
+				out.WriteString("\n\\ No newline at end of file\n")
+			}
+		}
+		i = j + 1
+	}
+
+	return out.String()
+}
+
+// hunkRange formats one side of a hunk header the way diff(1) does: the count
+// is left out when it is 1, and an empty range names the line before it
+func hunkRange(start, count int) string {
+	switch count {
+	case 0:
+		return fmt.Sprintf("%d,0", start-1)
+	case 1:
+		return fmt.Sprintf("%d", start)
+	default:
+		return fmt.Sprintf("%d,%d", start, count)
+	}
+}
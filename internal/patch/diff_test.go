@@ -0,0 +1,69 @@
+package patch
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDiff(t *testing.T) {
+	got := Diff("main.go", mainGo, strings.Replace(mainGo, "hello", "hi", 1))
+	want := "--- a/main.go\n+++ b/main.go\n@@ -3,5 +3,5 @@\n import \"fmt\"\n \n func main() {\n-\tfmt.Println(\"hello\")\n+\tfmt.Println(\"hi\")\n }\n"
+	if got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+
+	if got := Diff("main.go", mainGo, mainGo); got != "" {
+		t.Errorf("expected no diff for equal texts, got:\n%s", got)
+	}
+
+	got = NewFileDiff("doc.go", "// Package main\npackage main")
+	want = "--- /dev/null\n+++ b/doc.go\n@@ -0,0 +1,2 @@\n+// Package main\n+package main\n\\ No newline at end of file\n"
+	if got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+// TestDiffApplies checks generated diffs apply exactly, including hunks that
+// merge, files without a final newline and rewrites of every line
+func TestDiffApplies(t *testing.T) {
+	var long strings.Builder
+	for i := 1; i <= 40; i++ {
+		long.WriteString(strings.Repeat("x", i%7) + "line\n")
+	}
+	base := long.String()
+
+	tests := []struct {
+		name     string
+		old, new string
+	}{
+		{"one line", mainGo, strings.Replace(mainGo, "hello", "hi", 1)},
+		{"insert at top", mainGo, "// Command main greets.\n" + mainGo},
+		{"append at end", mainGo, mainGo + "\nfunc helper() {}\n"},
+		{"close changes share a hunk", base, strings.Replace(strings.Replace(base, "xline\n", "XLINE\n", 1), "xxxxline\n", "", 1)},
+		{"distant changes", base, strings.Replace(base, "xxxxxxline", "changed", -1)},
+		{"add final newline", strings.TrimSuffix(mainGo, "\n"), mainGo},
+		{"remove final newline", mainGo, strings.TrimSuffix(mainGo, "\n")},
+		{"rewrite", mainGo, "package other\n\nvar X = 1\n"},
+		{"empty old file", "", "package main\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := t.TempDir()
+			path := filepath.Join(root, "file.go")
+			if err := os.WriteFile(path, []byte(tt.old), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			diff := Diff("file.go", tt.old, tt.new)
+			if _, err := Apply(root, Patch{FilePath: "file.go", UnifiedDiff: diff}, Options{}); err != nil {
+				t.Fatalf("generated diff does not apply: %v\n%s", err, diff)
+			}
+			if data, _ := os.ReadFile(path); string(data) != tt.new {
+				t.Errorf("applying the diff gave:\n%q\nwant:\n%q\ndiff:\n%s", data, tt.new, diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,263 @@
+package patch
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// Lint reads a diff the way models write them and repairs the mistakes they
+// make most: hunk headers whose line counts are wrong, context lines that lost
+// their leading space, misplaced "\ No newline at end of file" markers, and
+// --- / +++ paths that lack the a/ and b/ prefixes or are missing altogether.
+// filePath, when set, is the file a single-file diff is meant for; headers that
+// name another file are an error. Lint returns the repaired diffs, which Parse
+// accepts once formatted, and a description of each repair.
+func Lint(text, filePath string) ([]*FileDiff, []string, error) {
+	diffs, repairs, err := parseLoose(text)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for _, d := range diffs {
+		label := "hunk"
+		if len(diffs) > 1 {
+			label = d.Name() + " hunk"
+		}
+		for i := range d.Hunks {
+			repairs = append(repairs, recount(fmt.Sprintf("%s %d", label, i+1), &d.Hunks[i], i == len(d.Hunks)-1)...)
+		}
+	}
+
+	if len(diffs) == 1 && filePath != "" {
+		fixed, err := fixPaths(diffs[0], path.Clean(filepath.ToSlash(filePath)))
+		if err != nil {
+			return nil, nil, err
+		}
+		repairs = append(repairs, fixed...)
+	}
+	for _, d := range diffs {
+		repairs = append(repairs, fixPrefixes(d)...)
+		if err := d.check(); err != nil {
+			return nil, nil, err
+		}
+	}
+	return diffs, repairs, nil
+}
+
+// parseLoose reads a diff without trusting its hunk line counts. A hunk runs
+// until the next @@ line or file header. Lines without a prefix while the
+// header still promises old lines are read as context. So are blank lines,
+// except at the end of a hunk once its header's old lines are used up, where
+// they separate sections. The first file's --- and +++ headers may be missing.
+func parseLoose(text string) ([]*FileDiff, []string, error) {
+	var diffs []*FileDiff
+	var repairs []string
+	lines := splitDiff(text)
+
+	var d *FileDiff
+	var current *Hunk
+	oldLeft := 0 // Old lines the current hunk's header still promises
+	blank := 0   // Blank lines read as context
+	held := 0    // Blank lines past the header's count, context only if the hunk goes on
+
+	for i := 0; i < len(lines); {
+		line := lines[i]
+
+		// A "--- " line is a removed "-- " line while the hunk still expects
+		// old lines; otherwise, followed by "+++ ", it starts a file header
+		if isFileHeader(lines, i) && (current == nil || oldLeft <= 0 || strings.HasPrefix(line, "diff --git ")) {
+			var err error
+			if d, i, err = parseFileHeader(lines, i); err != nil {
+				return nil, nil, err
+			}
+			diffs = append(diffs, d)
+			current, held = nil, 0
+			continue
+		}
+		i++
+
+		if strings.HasPrefix(line, "@@") {
+			if d == nil {
+				d = &FileDiff{} // Headers missing; Lint adds them
+				diffs = append(diffs, d)
+			}
+			h, err := parseHunkHeader(line)
+			if err != nil {
+				return nil, nil, err
+			}
+			d.Hunks = append(d.Hunks, h)
+			current = &d.Hunks[len(d.Hunks)-1]
+			oldLeft, held = h.OldLines, 0
+			continue
+		}
+
+		if current == nil {
+			continue // "index" lines, or prose before the first hunk
+		}
+
+		if line == "" && oldLeft <= 0 {
+			held++
+			continue
+		}
+		for ; held > 0; held-- {
+			current.Lines = append(current.Lines, Line{Kind: Context})
+			blank++
+		}
+
+		switch {
+		case line == "":
+			current.Lines = append(current.Lines, Line{Kind: Context})
+			oldLeft--
+			blank++
+		case line[0] == ' ':
+			current.Lines = append(current.Lines, Line{Kind: Context, Text: line[1:]})
+			oldLeft--
+		case line[0] == '-':
+			current.Lines = append(current.Lines, Line{Kind: Removed, Text: line[1:]})
+			oldLeft--
+		case line[0] == '+':
+			current.Lines = append(current.Lines, Line{Kind: Added, Text: line[1:]})
+		case line[0] == '\\':
+			if n := len(current.Lines); n > 0 {
+				current.Lines[n-1].NoNewline = true
+			}
+		case oldLeft > 0:
+			current.Lines = append(current.Lines, Line{Kind: Context, Text: line})
+			oldLeft--
+			repairs = append(repairs, fmt.Sprintf("hunk %d: added the missing space before context line %q", len(d.Hunks), line))
+		default:
+			return nil, nil, fmt.Errorf("%s: unexpected line %q; hunk lines must start with ' ', '-' or '+'", current.Header(), line)
+		}
+	}
+
+	if len(diffs) == 0 {
+		return nil, nil, fmt.Errorf("invalid unified diff format: no @@ hunk headers")
+	}
+	if blank > 0 {
+		repairs = append(repairs, fmt.Sprintf("added the missing space before %d blank context line(s)", blank))
+	}
+	return diffs, repairs, nil
+}
+
+// recount fixes a hunk header's line counts to match its body, and drops
+// "\ No newline at end of file" markers that are not at the end of the file
+func recount(label string, h *Hunk, last bool) []string {
+	var repairs []string
+
+	oldLast, newLast := -1, -1
+	for i, l := range h.Lines {
+		if l.Kind != Added {
+			oldLast = i
+		}
+		if l.Kind != Removed {
+			newLast = i
+		}
+	}
+	for i := range h.Lines {
+		if h.Lines[i].NoNewline && (!last || (i != oldLast && i != newLast)) {
+			h.Lines[i].NoNewline = false
+			repairs = append(repairs, fmt.Sprintf("%s: dropped a %q marker that was not at the end of the file", label, noNewlineMarker))
+		}
+	}
+
+	old, new := h.count()
+	if old == h.OldLines && new == h.NewLines {
+		return repairs
+	}
+	header := h.Header()
+	h.OldStart = recountStart(h.OldStart, h.OldLines, old)
+	h.NewStart = recountStart(h.NewStart, h.NewLines, new)
+	h.OldLines, h.NewLines = old, new
+	return append(repairs, fmt.Sprintf("%s: header %s recounted as %s", label, header, h.Header()))
+}
+
+// recountStart moves a side's start line when its count changes to or from
+// zero, since an empty side names the line before the hunk
+func recountStart(start, was, now int) int {
+	switch {
+	case was == 0 && now > 0:
+		return start + 1
+	case was > 0 && now == 0:
+		return max(start-1, 0)
+	}
+	return start
+}
+
+// fixPaths checks that a single-file diff's --- and +++ headers name filePath,
+// adding them when the diff has none and repairing their a/ and b/ prefixes.
+// A rename that names filePath on either side is left alone. Headers that name
+// another file are an error: the diff is never moved to a different file.
+func fixPaths(d *FileDiff, filePath string) ([]string, error) {
+	if d.OldPath == "" {
+		d.OldPath, d.NewPath = "a/"+filePath, "b/"+filePath
+		if allAdded(d) {
+			d.OldPath = "/dev/null"
+		}
+		return []string{fmt.Sprintf("added the missing --- %s and +++ %s headers", d.OldPath, d.NewPath)}, nil
+	}
+	// Swapped a/ and b/ prefixes look like a rename of filePath to itself; those are repaired below
+	if d.IsRename() && (d.OldName() == filePath || d.NewName() == filePath) &&
+		!(namesFile(d.OldPath, filePath) && namesFile(d.NewPath, filePath)) {
+		return nil, nil
+	}
+
+	var repairs []string
+	for _, side := range []struct {
+		marker, prefix string
+		path           *string
+	}{{"---", "a/", &d.OldPath}, {"+++", "b/", &d.NewPath}} {
+		if *side.path == "/dev/null" {
+			continue
+		}
+		if !namesFile(*side.path, filePath) {
+			return nil, fmt.Errorf("%s header %s names a different file than file_path %s", side.marker, *side.path, filePath)
+		}
+		if want := side.prefix + filePath; *side.path != want {
+			repairs = append(repairs, fmt.Sprintf("%s header %s rewritten as %s", side.marker, *side.path, want))
+			*side.path = want
+		}
+	}
+	return repairs, nil
+}
+
+// namesFile reports whether a header path is filePath, with or without an
+// a/ or b/ prefix
+func namesFile(header, filePath string) bool {
+	for _, prefix := range []string{"", "a/", "b/"} {
+		if name, ok := strings.CutPrefix(header, prefix); ok && path.Clean(name) == filePath {
+			return true
+		}
+	}
+	return false
+}
+
+// fixPrefixes adds the a/ and b/ prefixes a diff's paths are missing
+func fixPrefixes(d *FileDiff) []string {
+	var repairs []string
+	if d.OldPath != "" && d.OldPath != "/dev/null" && !strings.HasPrefix(d.OldPath, "a/") {
+		repairs = append(repairs, fmt.Sprintf("--- header %s rewritten as a/%s", d.OldPath, d.OldPath))
+		d.OldPath = "a/" + d.OldPath
+	}
+	if d.NewPath != "" && d.NewPath != "/dev/null" && !strings.HasPrefix(d.NewPath, "b/") {
+		repairs = append(repairs, fmt.Sprintf("+++ header %s rewritten as b/%s", d.NewPath, d.NewPath))
+		d.NewPath = "b/" + d.NewPath
+	}
+	return repairs
+}
+
+// allAdded reports whether a diff only adds lines, as one creating a file does
+func allAdded(d *FileDiff) bool {
+	for _, h := range d.Hunks {
+		if h.OldStart != 0 {
+			return false
+		}
+		for _, l := range h.Lines {
+			if l.Kind != Added {
+				return false
+			}
+		}
+	}
+	return true
+}
@@ -0,0 +1,170 @@
+package patch
+
+import (
+	"strings"
+	"testing"
+)
+
+// TestLint covers the diff mistakes models make; every repaired diff must pass Parse
+func TestLint(t *testing.T) {
+	tests := []struct {
+		name    string
+		diff    string
+		path    string
+		want    string
+		repairs []string
+		wantErr string
+	}{
+		{
+			name: "clean diff",
+			diff: "--- a/main.go\n+++ b/main.go\n@@ -5,3 +5,3 @@\n func main() {\n-\tfmt.Println(\"hello\")\n+\tfmt.Println(\"hi\")\n }\n",
+			path: "main.go",
+			want: "--- a/main.go\n+++ b/main.go\n@@ -5,3 +5,3 @@\n func main() {\n-\tfmt.Println(\"hello\")\n+\tfmt.Println(\"hi\")\n }\n",
+		},
+		{
+			name:    "wrong counts",
+			diff:    "--- a/main.go\n+++ b/main.go\n@@ -5,2 +5,4 @@\n func main() {\n-\tfmt.Println(\"hello\")\n+\tfmt.Println(\"hi\")\n }\n",
+			path:    "main.go",
+			want:    "--- a/main.go\n+++ b/main.go\n@@ -5,3 +5,3 @@\n func main() {\n-\tfmt.Println(\"hello\")\n+\tfmt.Println(\"hi\")\n }\n",
+			repairs: []string{"hunk 1: header @@ -5,2 +5,4 @@ recounted as @@ -5,3 +5,3 @@"},
+		},
+		{
+			name: "missing context prefixes",
+			diff: "--- a/main.go\n+++ b/main.go\n@@ -1,4 +1,4 @@\npackage main\n\n-import \"fmt\"\n+import \"os\"\n func main() {}\n",
+			path: "main.go",
+			want: "--- a/main.go\n+++ b/main.go\n@@ -1,4 +1,4 @@\n package main\n \n-import \"fmt\"\n+import \"os\"\n func main() {}\n",
+			repairs: []string{
+				"hunk 1: added the missing space before context line \"package main\"",
+				"added the missing space before 1 blank context line(s)",
+			},
+		},
+		{
+			name: "paths without prefixes",
+			diff: "--- main.go\n+++ main.go\n@@ -1 +1 @@\n-package main\n+package app\n",
+			path: "main.go",
+			want: "--- a/main.go\n+++ b/main.go\n@@ -1 +1 @@\n-package main\n+package app\n",
+			repairs: []string{
+				"--- header main.go rewritten as a/main.go",
+				"+++ header main.go rewritten as b/main.go",
+			},
+		},
+		{
+			name:    "new file without headers",
+			diff:    "@@ -0,0 +1,3 @@\n+package main\n+\n+func main() {}\n",
+			path:    "cmd/main.go",
+			want:    "--- /dev/null\n+++ b/cmd/main.go\n@@ -0,0 +1,3 @@\n+package main\n+\n+func main() {}\n",
+			repairs: []string{"added the missing --- /dev/null and +++ b/cmd/main.go headers"},
+		},
+		{
+			name:    "new file with wrong counts",
+			diff:    "--- /dev/null\n+++ b/main.go\n@@ -0,0 +1 @@\n+package main\n+\n+func main() {}\n",
+			path:    "main.go",
+			want:    "--- /dev/null\n+++ b/main.go\n@@ -0,0 +1,3 @@\n+package main\n+\n+func main() {}\n",
+			repairs: []string{"hunk 1: header @@ -0,0 +1 @@ recounted as @@ -0,0 +1,3 @@"},
+		},
+		{
+			name:    "empty side counted as a line",
+			diff:    "--- a/main.go\n+++ b/main.go\n@@ -3,1 +3,1 @@\n+// added\n",
+			path:    "main.go",
+			want:    "--- a/main.go\n+++ b/main.go\n@@ -2,0 +3 @@\n+// added\n",
+			repairs: []string{"hunk 1: header @@ -3 +3 @@ recounted as @@ -2,0 +3 @@"},
+		},
+		{
+			name: "misplaced no-newline marker",
+			diff: "--- a/main.go\n+++ b/main.go\n@@ -1,2 +1,2 @@\n-a\n\\ No newline at end of file\n+b\n c\n",
+			path: "main.go",
+			want: "--- a/main.go\n+++ b/main.go\n@@ -1,2 +1,2 @@\n-a\n+b\n c\n",
+			repairs: []string{
+				"hunk 1: dropped a \"\\\\ No newline at end of file\" marker that was not at the end of the file",
+			},
+		},
+		{
+			name:    "removed SQL comment is not a header",
+			diff:    "--- a/q.sql\n+++ b/q.sql\n@@ -1,2 +1,2 @@\n--- setup\n+++ seed\n SELECT 1;\n",
+			path:    "q.sql",
+			want:    "--- a/q.sql\n+++ b/q.sql\n@@ -1,2 +1,2 @@\n--- setup\n+++ seed\n SELECT 1;\n",
+			repairs: nil,
+		},
+		{
+			name: "two files without prefixes",
+			diff: "--- main.go\n+++ main.go\n@@ -1 +1,2 @@\n-a\n+b\n--- util.go\n+++ util.go\n@@ -1 +1 @@\n-c\n+d\n",
+			path: "main.go",
+			want: "--- a/main.go\n+++ b/main.go\n@@ -1 +1 @@\n-a\n+b\n--- a/util.go\n+++ b/util.go\n@@ -1 +1 @@\n-c\n+d\n",
+			repairs: []string{
+				"main.go hunk 1: header @@ -1 +1,2 @@ recounted as @@ -1 +1 @@",
+				"--- header main.go rewritten as a/main.go",
+				"+++ header main.go rewritten as b/main.go",
+				"--- header util.go rewritten as a/util.go",
+				"+++ header util.go rewritten as b/util.go",
+			},
+		},
+		{
+			name: "rename keeps both paths",
+			diff: "diff --git a/old.go b/new.go\nrename from old.go\nrename to new.go\n--- a/old.go\n+++ b/new.go\n@@ -1 +1 @@\n-package old\n+package new\n",
+			path: "new.go",
+			want: "diff --git a/old.go b/new.go\nrename from old.go\nrename to new.go\n--- a/old.go\n+++ b/new.go\n@@ -1 +1 @@\n-package old\n+package new\n",
+		},
+		{
+			name: "blank line between files",
+			diff: "--- a/a.go\n+++ b/a.go\n@@ -3,2 +3,2 @@\n-x\n+y\n z\n\n--- a/b.go\n+++ b/b.go\n@@ -1 +1 @@\n-c\n+d\n\n",
+			want: "--- a/a.go\n+++ b/a.go\n@@ -3,2 +3,2 @@\n-x\n+y\n z\n--- a/b.go\n+++ b/b.go\n@@ -1 +1 @@\n-c\n+d\n",
+		},
+		{
+			name:    "blank context past an undercounted header",
+			diff:    "--- a/main.go\n+++ b/main.go\n@@ -1 +1 @@\n-a\n+b\n\n c\n",
+			path:    "main.go",
+			want:    "--- a/main.go\n+++ b/main.go\n@@ -1,3 +1,3 @@\n-a\n+b\n \n c\n",
+			repairs: []string{"added the missing space before 1 blank context line(s)", "hunk 1: header @@ -1 +1 @@ recounted as @@ -1,3 +1,3 @@"},
+		},
+		{
+			name:    "headers name another file",
+			diff:    "--- a/cmd/main.go\n+++ b/cmd/main.go\n@@ -1 +1 @@\n-a\n+b\n",
+			path:    "main.go",
+			wantErr: "--- header a/cmd/main.go names a different file than file_path main.go",
+		},
+		{
+			name:    "wrong prefix",
+			diff:    "--- b/main.go\n+++ b/main.go\n@@ -1 +1 @@\n-a\n+b\n",
+			path:    "./main.go",
+			want:    "--- a/main.go\n+++ b/main.go\n@@ -1 +1 @@\n-a\n+b\n",
+			repairs: []string{"--- header b/main.go rewritten as a/main.go"},
+		},
+		{
+			name:    "missing headers without a path",
+			diff:    "@@ -1 +1 @@\n-a\n+b\n",
+			wantErr: "missing --- or +++ headers",
+		},
+		{
+			name:    "prose after the diff",
+			diff:    "--- a/main.go\n+++ b/main.go\n@@ -1 +1 @@\n-a\n+b\nThis renames the package.\n",
+			path:    "main.go",
+			wantErr: "unexpected line \"This renames the package.\"",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			diffs, repairs, err := Lint(tt.diff, tt.path)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("expected error containing %q, got: %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			got := Format(diffs)
+			if got != tt.want {
+				t.Errorf("got:\n%s\nwant:\n%s", got, tt.want)
+			}
+			if strings.Join(repairs, "\n") != strings.Join(tt.repairs, "\n") {
+				t.Errorf("repairs:\n%s\nwant:\n%s", strings.Join(repairs, "\n"), strings.Join(tt.repairs, "\n"))
+			}
+			if _, err := Parse(got); err != nil {
+				t.Errorf("repaired diff does not parse: %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,450 @@
+package patch
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// FileDiff is the part of a unified diff that changes one file. Besides the
+// hunks it carries what git's extended headers say: a mode change, a new or
+// deleted file's mode, or a rename.
+type FileDiff struct {
+	OldPath string // From the --- header, e.g. "a/main.go" or "/dev/null"
+	NewPath string // From the +++ header
+	OldMode string // From "old mode" or "deleted file mode", e.g. "100644"
+	NewMode string // From "new mode" or "new file mode"
+	Hunks   []Hunk
+}
+
+// Hunk is one @@ section of a diff. A side with no lines names the line before
+// the hunk as its start, so a new file's hunk is "@@ -0,0 +1,n @@".
+type Hunk struct {
+	OldStart, OldLines int
+	NewStart, NewLines int
+	Section            string // Text after the closing @@, usually the enclosing function
+	Lines              []Line
+}
+
+// LineKind is the prefix of a hunk line
+type LineKind byte
+
+const (
+	Context LineKind = ' '
+	Removed LineKind = '-'
+	Added   LineKind = '+'
+)
+
+// Line is one line of a hunk body
+type Line struct {
+	Kind      LineKind
+	Text      string // Without its prefix or newline
+	NoNewline bool   // Followed by "\ No newline at end of file"
+}
+
+const noNewlineMarker = `\ No newline at end of file`
+
+// hunkHeaderPattern matches "@@ -start[,count] +start[,count] @@ section"
+var hunkHeaderPattern = regexp.MustCompile(`^@@ -(\d+)(?:,(\d+))? \+(\d+)(?:,(\d+))? @@(.*)$`)
+
+// IsNew reports whether the diff creates its file
+func (d *FileDiff) IsNew() bool { return d.OldPath == "/dev/null" }
+
+// IsDeleted reports whether the diff deletes its file
+func (d *FileDiff) IsDeleted() bool { return d.NewPath == "/dev/null" }
+
+// IsRename reports whether the diff moves its file to another path
+func (d *FileDiff) IsRename() bool {
+	return !d.IsNew() && !d.IsDeleted() && d.OldName() != d.NewName()
+}
+
+// OldName is the file's path before the diff, without the a/ prefix; "" for a new file
+func (d *FileDiff) OldName() string { return pathName(d.OldPath, "a/") }
+
+// NewName is the file's path after the diff, without the b/ prefix; "" for a deleted file
+func (d *FileDiff) NewName() string { return pathName(d.NewPath, "b/") }
+
+// Name is the path the diff is about: the new one, or the old one for a deletion
+func (d *FileDiff) Name() string {
+	if d.IsDeleted() {
+		return d.OldName()
+	}
+	return d.NewName()
+}
+
+// Summary describes the change in a line, e.g. "old.go renamed to new.go"
+func (d *FileDiff) Summary() string {
+	var s string
+	switch {
+	case d.IsNew():
+		s = d.NewName() + " created"
+	case d.IsDeleted():
+		s = d.OldName() + " deleted"
+	case d.IsRename():
+		s = fmt.Sprintf("%s renamed to %s", d.OldName(), d.NewName())
+	default:
+		s = d.NewName()
+	}
+	if d.OldMode != "" && d.NewMode != "" && d.OldMode != d.NewMode {
+		s += fmt.Sprintf(" (mode %s -> %s)", d.OldMode, d.NewMode)
+	}
+	return s
+}
+
+func pathName(path, prefix string) string {
+	if path == "/dev/null" {
+		return ""
+	}
+	return strings.TrimPrefix(path, prefix)
+}
+
+// String formats the diff, recomputing nothing: a diff from Parse or Lint
+// formats back to text that Parse accepts. A "diff --git" header and git's
+// extended headers are written only when the diff needs them.
+func (d *FileDiff) String() string {
+	var out strings.Builder
+	if d.OldMode != "" || d.NewMode != "" || d.IsRename() || len(d.Hunks) == 0 {
+		oldName, newName := d.OldName(), d.NewName()
+		if oldName == "" {
+			oldName = newName
+		}
+		if newName == "" {
+			newName = oldName
+		}
+		fmt.Fprintf(&out, "diff --git a/%s b/%s\n", oldName, newName)
+		switch {
+		case d.IsNew():
+			fmt.Fprintf(&out, "new file mode %s\n", orDefaultMode(d.NewMode))
+		case d.IsDeleted():
+			fmt.Fprintf(&out, "deleted file mode %s\n", orDefaultMode(d.OldMode))
+		case d.OldMode != d.NewMode:
+			fmt.Fprintf(&out, "old mode %s\nnew mode %s\n", orDefaultMode(d.OldMode), orDefaultMode(d.NewMode))
+		}
+		if d.IsRename() {
+			fmt.Fprintf(&out, "rename from %s\nrename to %s\n", oldName, newName)
+		}
+		if len(d.Hunks) == 0 {
+			return out.String()
+		}
+	}
+
+	fmt.Fprintf(&out, "--- %s\n+++ %s\n", d.OldPath, d.NewPath)
+	for i := range d.Hunks {
+		h := &d.Hunks[i]
+		out.WriteString(h.Header())
+		out.WriteByte('\n')
+		for _, l := range h.Lines {
+			out.WriteByte(byte(l.Kind))
+			out.WriteString(l.Text)
+			out.WriteByte('\n')
+			if l.NoNewline {
+				out.WriteString(noNewlineMarker + "\n")
+			}
+		}
+	}
+	return out.String()
+}
+
+func orDefaultMode(mode string) string {
+	if mode == "" {
+		return "100644"
+	}
+	return mode
+}
+
+// Format formats the diffs of a patch one after another
+func Format(diffs []*FileDiff) string {
+	var out strings.Builder
+	for _, d := range diffs {
+		out.WriteString(d.String())
+	}
+	return out.String()
+}
+
+// Header formats the hunk's @@ line
+func (h *Hunk) Header() string {
+	header := fmt.Sprintf("@@ -%s +%s @@", formatRange(h.OldStart, h.OldLines), formatRange(h.NewStart, h.NewLines))
+	if h.Section != "" {
+		header += " " + h.Section
+	}
+	return header
+}
+
+// formatRange formats one side of a hunk header, leaving out a count of 1
+func formatRange(start, count int) string {
+	if count == 1 {
+		return strconv.Itoa(start)
+	}
+	return fmt.Sprintf("%d,%d", start, count)
+}
+
+// count returns how many lines the hunk has on each side
+func (h *Hunk) count() (old, new int) {
+	for _, l := range h.Lines {
+		if l.Kind != Added {
+			old++
+		}
+		if l.Kind != Removed {
+			new++
+		}
+	}
+	return old, new
+}
+
+// old returns the lines a hunk expects in the file
+func (h *Hunk) old() []string {
+	var lines []string
+	for _, l := range h.Lines {
+		if l.Kind != Added {
+			lines = append(lines, l.Text)
+		}
+	}
+	return lines
+}
+
+// newNoNewline reports whether the new side of the hunk ends without a newline
+func (h *Hunk) newNoNewline() bool {
+	for i := len(h.Lines) - 1; i >= 0; i-- {
+		if h.Lines[i].Kind != Removed {
+			return h.Lines[i].NoNewline
+		}
+	}
+	return false
+}
+
+// Parse reads a unified diff strictly: every hunk must hold exactly the lines
+// its header counts, every body line needs its ' ', '-' or '+' prefix, and
+// "\ No newline at end of file" may only follow the last line of a side.
+// The diff may change several files, each starting with a "diff --git" line
+// or a --- / +++ pair; lines before the first file are skipped. Use Lint for
+// diffs written by a model.
+func Parse(text string) ([]*FileDiff, error) {
+	lines := splitDiff(text)
+	var diffs []*FileDiff
+	var d *FileDiff
+
+	for i := 0; i < len(lines); {
+		line := lines[i]
+		switch {
+		case isFileHeader(lines, i):
+			var err error
+			if d, i, err = parseFileHeader(lines, i); err != nil {
+				return nil, err
+			}
+			diffs = append(diffs, d)
+			continue
+		case d == nil:
+			i++ // Prose or "index" lines before the first file
+			continue
+		case strings.HasPrefix(line, "@@"):
+		case len(d.Hunks) > 0 && line != "" && strings.ContainsRune(" -+", rune(line[0])):
+			return nil, fmt.Errorf("line %d: %s has more lines than its header counts", i+1, d.Hunks[len(d.Hunks)-1].Header())
+		default:
+			return nil, fmt.Errorf("line %d: unexpected line %q after the hunk; check the hunk's line counts", i+1, line)
+		}
+
+		h, err := parseHunkHeader(line)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", i+1, err)
+		}
+		i++
+
+		oldLeft, newLeft := h.OldLines, h.NewLines
+		for oldLeft > 0 || newLeft > 0 {
+			if i >= len(lines) {
+				return nil, fmt.Errorf("%s: diff ends %d old and %d new lines short of the header's counts", h.Header(), oldLeft, newLeft)
+			}
+			line := lines[i]
+			if line == "" {
+				return nil, fmt.Errorf("line %d: blank line in %s; context lines start with a space", i+1, h.Header())
+			}
+			l := Line{Kind: LineKind(line[0]), Text: line[1:]}
+			switch l.Kind {
+			case Context:
+				oldLeft--
+				newLeft--
+			case Removed:
+				oldLeft--
+			case Added:
+				newLeft--
+			default:
+				return nil, fmt.Errorf("line %d: unexpected line %q in %s; hunk lines must start with ' ', '-' or '+'", i+1, line, h.Header())
+			}
+			if oldLeft < 0 || newLeft < 0 {
+				return nil, fmt.Errorf("line %d: %s has more lines than its header counts", i+1, h.Header())
+			}
+			i++
+
+			if i < len(lines) && lines[i] == noNewlineMarker {
+				if (l.Kind != Added && oldLeft > 0) || (l.Kind != Removed && newLeft > 0) {
+					return nil, fmt.Errorf("line %d: %q before the end of the file", i+1, noNewlineMarker)
+				}
+				l.NoNewline = true
+				i++
+			}
+			h.Lines = append(h.Lines, l)
+		}
+		d.Hunks = append(d.Hunks, h)
+	}
+
+	if len(diffs) == 0 {
+		return nil, fmt.Errorf("invalid unified diff format: missing --- or +++ headers")
+	}
+	for _, d := range diffs {
+		if err := d.check(); err != nil {
+			return nil, err
+		}
+		for i := range d.Hunks[:max(len(d.Hunks)-1, 0)] {
+			for _, l := range d.Hunks[i].Lines {
+				if l.NoNewline {
+					return nil, fmt.Errorf("%s: %q before the last hunk", d.Hunks[i].Header(), noNewlineMarker)
+				}
+			}
+		}
+	}
+	return diffs, nil
+}
+
+// check reports a file in a diff that has nothing to change
+func (d *FileDiff) check() error {
+	if d.OldPath == "" || d.NewPath == "" {
+		return fmt.Errorf("invalid unified diff format: missing --- or +++ headers")
+	}
+	if len(d.Hunks) == 0 && d.OldMode == "" && d.NewMode == "" && !d.IsRename() {
+		return fmt.Errorf("invalid unified diff format: no @@ hunk headers for %s", d.Name())
+	}
+	return nil
+}
+
+// isFileHeader reports whether a new file's section of a diff starts at lines[i]
+func isFileHeader(lines []string, i int) bool {
+	return strings.HasPrefix(lines[i], "diff --git ") ||
+		strings.HasPrefix(lines[i], "--- ") && i+1 < len(lines) && strings.HasPrefix(lines[i+1], "+++ ")
+}
+
+// parseFileHeader reads the headers of one file's section: an optional
+// "diff --git" line with git's extended headers, then the --- and +++ lines,
+// which a rename, mode change or empty new file may leave out. It returns the
+// index of the line after the headers.
+func parseFileHeader(lines []string, i int) (*FileDiff, int, error) {
+	d := &FileDiff{}
+	git := strings.HasPrefix(lines[i], "diff --git ")
+	if rest, ok := strings.CutPrefix(lines[i], "diff --git "); ok {
+		var found bool
+		if d.OldPath, d.NewPath, found = gitPaths(rest); !found {
+			return nil, i, fmt.Errorf("line %d: malformed diff --git header %q", i+1, lines[i])
+		}
+		for i++; i < len(lines); i++ {
+			key, value, ok := extendedHeader(lines[i])
+			if !ok {
+				break
+			}
+			switch key {
+			case "old mode":
+				d.OldMode = value
+			case "new mode":
+				d.NewMode = value
+			case "deleted file mode":
+				d.OldMode, d.NewPath = value, "/dev/null"
+			case "new file mode":
+				d.NewMode, d.OldPath = value, "/dev/null"
+			case "rename from":
+				d.OldPath = "a/" + value
+			case "rename to":
+				d.NewPath = "b/" + value
+			case "copy from", "copy to":
+				return nil, i, fmt.Errorf("line %d: copies are not supported; add the new file instead", i+1)
+			}
+		}
+	}
+
+	if i+1 < len(lines) && strings.HasPrefix(lines[i], "--- ") && strings.HasPrefix(lines[i+1], "+++ ") {
+		oldPath, newPath := headerName(lines[i][4:]), headerName(lines[i+1][4:])
+		// After a "diff --git" line, --- and +++ naming other files start the next file
+		if !git || samePath(oldPath, d.OldPath, "a/") && samePath(newPath, d.NewPath, "b/") {
+			d.OldPath, d.NewPath = oldPath, newPath
+			i += 2
+		}
+	}
+	return d, i, nil
+}
+
+// samePath reports whether two header paths name the same file, ignoring the
+// a/ or b/ prefix; /dev/null matches either side's real name, as git writes both
+func samePath(a, b, prefix string) bool {
+	if a == "/dev/null" || b == "/dev/null" {
+		return true
+	}
+	return pathName(a, prefix) == pathName(b, prefix)
+}
+
+// extendedHeaderKeys are the git extended header lines between "diff --git" and "---"
+var extendedHeaderKeys = []string{
+	"old mode", "new mode", "deleted file mode", "new file mode",
+	"rename from", "rename to", "copy from", "copy to",
+	"similarity index", "dissimilarity index", "index",
+}
+
+// extendedHeader splits a git extended header line into its key and value
+func extendedHeader(line string) (key, value string, ok bool) {
+	for _, key := range extendedHeaderKeys {
+		if value, found := strings.CutPrefix(line, key+" "); found {
+			return key, strings.TrimSpace(value), true
+		}
+	}
+	return "", "", false
+}
+
+// gitPaths reads the two paths of "diff --git a/old b/new"
+func gitPaths(s string) (oldPath, newPath string, ok bool) {
+	if strings.HasPrefix(s, "a/") {
+		if i := strings.Index(s, " b/"); i > 0 {
+			return s[:i], s[i+1:], true
+		}
+	}
+	fields := strings.Fields(s)
+	if len(fields) != 2 {
+		return "", "", false
+	}
+	return fields[0], fields[1], true
+}
+
+// splitDiff splits diff text into lines, without line endings or trailing blank lines
+func splitDiff(text string) []string {
+	text = strings.TrimRight(strings.ReplaceAll(text, "\r\n", "\n"), "\n")
+	if text == "" {
+		return nil
+	}
+	return strings.Split(text, "\n")
+}
+
+// parseHunkHeader reads "@@ -start,count +start,count @@"; a missing count means 1
+func parseHunkHeader(line string) (Hunk, error) {
+	m := hunkHeaderPattern.FindStringSubmatch(strings.TrimRight(line, " "))
+	if m == nil {
+		return Hunk{}, fmt.Errorf("malformed hunk header %q; expected @@ -start,count +start,count @@", line)
+	}
+	h := Hunk{Section: strings.TrimSpace(m[5])}
+	h.OldStart, h.OldLines = parseRange(m[1], m[2])
+	h.NewStart, h.NewLines = parseRange(m[3], m[4])
+	return h, nil
+}
+
+// parseRange reads the digits matched by hunkHeaderPattern for one side
+func parseRange(start, count string) (int, int) {
+	s, _ := strconv.Atoi(start)
+	if count == "" {
+		return s, 1
+	}
+	c, _ := strconv.Atoi(count)
+	return s, c
+}
+
+// headerName reads the file name from a --- or +++ header, dropping any timestamp
+func headerName(s string) string {
+	if name, _, found := strings.Cut(s, "\t"); found {
+		return name
+	}
+	return strings.TrimSpace(s)
+}
@@ -0,0 +1,132 @@
+package patch
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name    string
+		diff    string
+		want    string // Formatted result; empty means the input itself
+		wantErr string
+	}{
+		{
+			name: "two hunks",
+			diff: "--- a/main.go\n+++ b/main.go\n@@ -1,3 +1,3 @@\n-package main\n+package app\n \n import \"fmt\"\n@@ -5,3 +5,3 @@ func main() {\n func main() {\n-\tfmt.Println(\"hello\")\n+\tfmt.Println(\"hi\")\n }\n",
+		},
+		{
+			name: "git preamble and timestamps",
+			diff: "diff --git a/main.go b/main.go\nindex 83db48f..bf269f4 100644\n--- a/main.go\t2024-01-01 00:00:00\n+++ b/main.go\t2024-01-01 00:00:00\n@@ -1 +1 @@\n-package main\n+package app\n",
+			want: "--- a/main.go\n+++ b/main.go\n@@ -1 +1 @@\n-package main\n+package app\n",
+		},
+		{
+			name: "new file without a final newline",
+			diff: "--- /dev/null\n+++ b/main.go\n@@ -0,0 +1,2 @@\n+package main\n+var x = 1\n\\ No newline at end of file\n",
+		},
+		{
+			name: "removed line that looks like a header",
+			diff: "--- a/q.sql\n+++ b/q.sql\n@@ -1,2 +1,2 @@\n--- setup\n+++ seed\n SELECT 1;\n",
+		},
+		{
+			name:    "count too high",
+			diff:    "--- a/main.go\n+++ b/main.go\n@@ -1,3 +1,3 @@\n-package main\n+package app\n \n",
+			wantErr: "@@ -1,3 +1,3 @@: diff ends 1 old and 1 new lines short of the header's counts",
+		},
+		{
+			name:    "count too low",
+			diff:    "--- a/main.go\n+++ b/main.go\n@@ -1 +1 @@\n-package main\n+package app\n \n",
+			wantErr: "line 6: @@ -1 +1 @@ has more lines than its header counts",
+		},
+		{
+			name:    "blank context line",
+			diff:    "--- a/main.go\n+++ b/main.go\n@@ -1,3 +1,3 @@\n-package main\n+package app\n\n import \"fmt\"\n",
+			wantErr: "line 6: blank line in @@ -1,3 +1,3 @@",
+		},
+		{
+			name:    "missing prefix",
+			diff:    "--- a/main.go\n+++ b/main.go\n@@ -1,2 +1,2 @@\n-package main\n+package app\nimport \"fmt\"\n",
+			wantErr: "line 6: unexpected line \"import \\\"fmt\\\"\" in @@ -1,2 +1,2 @@",
+		},
+		{
+			name:    "marker before the end of a side",
+			diff:    "--- a/main.go\n+++ b/main.go\n@@ -1,2 +1,2 @@\n-package main\n\\ No newline at end of file\n+package app\n \n",
+			wantErr: "line 5: \"\\\\ No newline at end of file\" before the end of the file",
+		},
+		{
+			name:    "marker before the last hunk",
+			diff:    "--- a/main.go\n+++ b/main.go\n@@ -1 +1 @@\n-a\n\\ No newline at end of file\n+b\n@@ -3 +3 @@\n-c\n+d\n",
+			wantErr: "@@ -1 +1 @@: \"\\\\ No newline at end of file\" before the last hunk",
+		},
+		{
+			name: "two files",
+			diff: "--- a/main.go\n+++ b/main.go\n@@ -1 +1 @@\n-a\n+b\n--- a/other.go\n+++ b/other.go\n@@ -1 +1 @@\n-a\n+b\n",
+		},
+		{
+			name: "rename with changes",
+			diff: "diff --git a/old.go b/new.go\nsimilarity index 90%\nrename from old.go\nrename to new.go\nindex 83db48f..bf269f4 100644\n--- a/old.go\n+++ b/new.go\n@@ -1 +1 @@\n-package old\n+package new\n",
+			want: "diff --git a/old.go b/new.go\nrename from old.go\nrename to new.go\n--- a/old.go\n+++ b/new.go\n@@ -1 +1 @@\n-package old\n+package new\n",
+		},
+		{
+			name: "pure rename",
+			diff: "diff --git a/old.go b/new.go\nrename from old.go\nrename to new.go\n",
+		},
+		{
+			name: "deleted file",
+			diff: "diff --git a/old.go b/old.go\ndeleted file mode 100644\n--- a/old.go\n+++ /dev/null\n@@ -1 +0,0 @@\n-package old\n",
+		},
+		{
+			name: "empty new file and a mode change",
+			diff: "diff --git a/empty.txt b/empty.txt\nnew file mode 100644\ndiff --git a/run.sh b/run.sh\nold mode 100644\nnew mode 100755\n",
+		},
+		{
+			name:    "header with nothing to change",
+			diff:    "diff --git a/main.go b/main.go\nindex 83db48f..bf269f4 100644\n",
+			wantErr: "no @@ hunk headers for main.go",
+		},
+		{
+			name:    "copy",
+			diff:    "diff --git a/a.go b/b.go\ncopy from a.go\ncopy to b.go\n",
+			wantErr: "line 2: copies are not supported",
+		},
+		{
+			name:    "malformed hunk header",
+			diff:    "--- a/main.go\n+++ b/main.go\n@@ -1,3 @@\n-a\n",
+			wantErr: "line 3: malformed hunk header",
+		},
+		{
+			name:    "missing headers",
+			diff:    "@@ -1 +1 @@\n-a\n+b\n",
+			wantErr: "missing --- or +++ headers",
+		},
+		{
+			name:    "no hunks",
+			diff:    "--- a/main.go\n+++ b/main.go\n",
+			wantErr: "no @@ hunk headers",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			diffs, err := Parse(tt.diff)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("expected error containing %q, got: %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			want := tt.want
+			if want == "" {
+				want = tt.diff
+			}
+			if got := Format(diffs); got != want {
+				t.Errorf("got:\n%s\nwant:\n%s", got, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,26 @@
+package patch
+
+// WithHunks returns a copy of the diff that makes only the given hunks, which
+// may be a subset of its own or edited versions of them, in file order. The
+// new-side start lines are worked out again, since leaving a hunk out moves
+// every line after it.
+func (d *FileDiff) WithHunks(hunks []Hunk) *FileDiff {
+	out := *d
+	out.Hunks = make([]Hunk, len(hunks))
+	copy(out.Hunks, hunks)
+
+	delta := 0 // Lines the kept hunks so far add to the file
+	for i := range out.Hunks {
+		h := &out.Hunks[i]
+		first := h.OldStart // First line of the hunk in the old file
+		if h.OldLines == 0 {
+			first++
+		}
+		h.NewStart = first + delta
+		if h.NewLines == 0 {
+			h.NewStart--
+		}
+		delta += h.NewLines - h.OldLines
+	}
+	return &out
+}
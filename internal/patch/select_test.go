@@ -0,0 +1,76 @@
+package patch
+
+import "testing"
+
+func TestWithHunks(t *testing.T) {
+	const diff = "--- a/main.go\n+++ b/main.go\n" +
+		"@@ -2,2 +2,3 @@\n a\n+b\n c\n" +
+		"@@ -10,2 +11,2 @@\n-d\n+e\n f\n" +
+		"@@ -20,2 +21 @@\n g\n-h\n"
+
+	tests := []struct {
+		name string
+		keep []int
+		want string
+	}{
+		{
+			name: "all hunks",
+			keep: []int{0, 1, 2},
+			want: "--- a/main.go\n+++ b/main.go\n@@ -2,2 +2,3 @@\n a\n+b\n c\n@@ -10,2 +11,2 @@\n-d\n+e\n f\n@@ -20,2 +21 @@\n g\n-h\n",
+		},
+		{
+			name: "first hunk left out",
+			keep: []int{1, 2},
+			want: "--- a/main.go\n+++ b/main.go\n@@ -10,2 +10,2 @@\n-d\n+e\n f\n@@ -20,2 +20 @@\n g\n-h\n",
+		},
+		{
+			name: "only the last hunk",
+			keep: []int{2},
+			want: "--- a/main.go\n+++ b/main.go\n@@ -20,2 +20 @@\n g\n-h\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			diffs, err := Parse(diff)
+			if err != nil {
+				t.Fatalf("Parse failed: %v", err)
+			}
+			var hunks []Hunk
+			for _, i := range tt.keep {
+				hunks = append(hunks, diffs[0].Hunks[i])
+			}
+
+			got := diffs[0].WithHunks(hunks).String()
+			if got != tt.want {
+				t.Errorf("got:\n%s\nwant:\n%s", got, tt.want)
+			}
+			if _, err := Parse(got); err != nil {
+				t.Errorf("result does not parse: %v", err)
+			}
+			if len(diffs[0].Hunks) != 3 {
+				t.Errorf("WithHunks changed the original diff")
+			}
+		})
+	}
+}
+
+// TestWithHunksEmptySides checks the start lines of hunks that only add or only remove
+func TestWithHunksEmptySides(t *testing.T) {
+	const diff = "--- a/main.go\n+++ b/main.go\n" +
+		"@@ -1,0 +2,2 @@\n+a\n+b\n" +
+		"@@ -5 +6,0 @@\n-c\n" +
+		"@@ -9,0 +10 @@\n+d\n"
+
+	diffs, err := Parse(diff)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	d := diffs[0]
+
+	got := d.WithHunks([]Hunk{d.Hunks[1], d.Hunks[2]}).String()
+	want := "--- a/main.go\n+++ b/main.go\n@@ -5 +4,0 @@\n-c\n@@ -9,0 +9 @@\n+d\n"
+	if got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
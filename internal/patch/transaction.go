@@ -0,0 +1,39 @@
+package patch
+
+// Transaction applies a set of patches as one. Each patch is staged in memory
+// on top of the ones staged before it, so later patches may build on earlier
+// ones, and nothing touches the disk until Commit. Either every staged patch
+// is written, or the repository is left exactly as it was.
+type Transaction struct {
+	tree    *tree
+	opts    Options
+	reports []*Report
+}
+
+// NewTransaction starts a transaction against the repository at repoRoot
+func NewTransaction(repoRoot string, opts Options) *Transaction {
+	return &Transaction{tree: newTree(repoRoot), opts: opts}
+}
+
+// Stage applies p in memory. A patch that does not apply returns an error and
+// leaves the transaction as it was, so the caller may stage others or give up.
+func (tx *Transaction) Stage(p Patch) (*Report, error) {
+	report, err := tx.tree.apply(p, tx.opts)
+	if err != nil {
+		return report, err
+	}
+	tx.reports = append(tx.reports, report)
+	return report, nil
+}
+
+// Staged returns how many patches have been staged
+func (tx *Transaction) Staged() int {
+	return len(tx.reports)
+}
+
+// Commit writes every staged patch. It fails without writing anything if a
+// file the patches read has changed on disk since it was staged, and if a
+// write fails part way, every file already written gets its original bytes back.
+func (tx *Transaction) Commit() error {
+	return tx.tree.commit()
+}
@@ -0,0 +1,105 @@
+package patch
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestTransaction(t *testing.T) {
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{"main.go": "package main\n\nvar x = 1\n"})
+
+	tx := NewTransaction(root, DefaultOptions)
+	if _, err := tx.Stage(Patch{FilePath: "main.go", UnifiedDiff: "--- a/main.go\n+++ b/main.go\n@@ -3 +3 @@\n-var x = 1\n+var x = 2\n"}); err != nil {
+		t.Fatal(err)
+	}
+	// The second patch only applies on top of the first
+	if _, err := tx.Stage(Patch{FilePath: "main.go", UnifiedDiff: "--- a/main.go\n+++ b/main.go\n@@ -3 +3,2 @@\n var x = 2\n+var y = 3\n"}); err != nil {
+		t.Fatal(err)
+	}
+	// A patch that does not apply is left out, and the others stay staged
+	if _, err := tx.Stage(Patch{FilePath: "main.go", UnifiedDiff: "--- a/main.go\n+++ b/main.go\n@@ -3 +3 @@\n-var x = 1\n+var x = 5\n"}); err == nil {
+		t.Fatal("expected a stale patch to fail")
+	}
+	if tx.Staged() != 2 {
+		t.Fatalf("expected 2 staged patches, got %d", tx.Staged())
+	}
+
+	if data, _ := os.ReadFile(filepath.Join(root, "main.go")); string(data) != "package main\n\nvar x = 1\n" {
+		t.Fatalf("staging wrote to disk: %q", data)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(filepath.Join(root, "main.go")); string(data) != "package main\n\nvar x = 2\nvar y = 3\n" {
+		t.Errorf("unexpected content after commit: %q", data)
+	}
+}
+
+func TestTransactionFileChangedOnDisk(t *testing.T) {
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{"a.go": "package a\n", "b.go": "package b\n"})
+
+	tx := NewTransaction(root, DefaultOptions)
+	for _, name := range []string{"a", "b"} {
+		diff := "--- a/" + name + ".go\n+++ b/" + name + ".go\n@@ -1 +1 @@\n-package " + name + "\n+package x\n"
+		if _, err := tx.Stage(Patch{FilePath: name + ".go", UnifiedDiff: diff}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeFiles(t, root, map[string]string{"b.go": "package b // edited\n"})
+
+	err := tx.Commit()
+	if err == nil || !strings.Contains(err.Error(), "b.go changed on disk") {
+		t.Fatalf("expected the commit to notice b.go changed, got %v", err)
+	}
+	if data, _ := os.ReadFile(filepath.Join(root, "a.go")); string(data) != "package a\n" {
+		t.Errorf("a.go was written: %q", data)
+	}
+}
+
+func TestTransactionRollsBack(t *testing.T) {
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{"a.go": "package a\n", "c.go": "package c\n"})
+
+	tx := NewTransaction(root, DefaultOptions)
+	patches := []string{
+		"--- a/a.go\n+++ b/a.go\n@@ -1 +1 @@\n-package a\n+package x\n",
+		"--- /dev/null\n+++ b/b.go\n@@ -0,0 +1 @@\n+package b\n",
+		"--- a/c.go\n+++ /dev/null\n@@ -1 +0,0 @@\n-package c\n",
+		"--- /dev/null\n+++ b/d.go\n@@ -0,0 +1 @@\n+package d\n",
+	}
+	for _, diff := range patches {
+		if _, err := tx.Stage(Patch{UnifiedDiff: diff}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Fail the last write, after a.go, b.go and c.go have been written
+	defer func(orig func(string, fileState) error) { writeFile = orig }(writeFile)
+	writeFile = func(path string, s fileState) error {
+		if filepath.Base(path) == "d.go" {
+			return errors.New("disk full")
+		}
+		return writeState(path, s)
+	}
+
+	err := tx.Commit()
+	if err == nil || !strings.Contains(err.Error(), "failed to write d.go: disk full") {
+		t.Fatalf("expected the write to fail, got %v", err)
+	}
+
+	for path, want := range map[string]string{"a.go": "package a\n", "c.go": "package c\n"} {
+		if data, err := os.ReadFile(filepath.Join(root, path)); err != nil || string(data) != want {
+			t.Errorf("%s was not restored: %q, %v", path, data, err)
+		}
+	}
+	for _, path := range []string{"b.go", "d.go"} {
+		if _, err := os.Stat(filepath.Join(root, path)); !os.IsNotExist(err) {
+			t.Errorf("%s was left behind: %v", path, err)
+		}
+	}
+}
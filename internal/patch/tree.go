@@ -0,0 +1,230 @@
+package patch
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+)
+
+// tree is the repository with changes staged in memory on top of the files on
+// disk. Patches are applied to the tree, and nothing is written until commit.
+type tree struct {
+	root   string
+	base   map[string]fileState // Files as they were on disk when first read, by slash-separated path
+	staged map[string]fileState // Files as the staged patches leave them
+}
+
+// fileState is the content of a file, or its absence
+type fileState struct {
+	exists  bool
+	content []byte
+	mode    os.FileMode
+}
+
+func newTree(root string) *tree {
+	return &tree{root: root, base: make(map[string]fileState), staged: make(map[string]fileState)}
+}
+
+// read returns a file as staged, or as it is on disk
+func (t *tree) read(path string) (fileState, error) {
+	if !filepath.IsLocal(filepath.FromSlash(path)) {
+		return fileState{}, fmt.Errorf("path %s is outside the repository", path)
+	}
+	if s, ok := t.staged[path]; ok {
+		return s, nil
+	}
+	if s, ok := t.base[path]; ok {
+		return s, nil
+	}
+	s, err := readDisk(t.disk(path))
+	if err == nil {
+		t.base[path] = s
+	}
+	return s, err
+}
+
+// disk returns where path is on disk
+func (t *tree) disk(path string) string {
+	return filepath.Join(t.root, filepath.FromSlash(path))
+}
+
+func readDisk(path string) (fileState, error) {
+	info, err := os.Stat(path)
+	if os.IsNotExist(err) {
+		return fileState{}, nil
+	}
+	if err != nil {
+		return fileState{}, fmt.Errorf("failed to read file: %w", err)
+	}
+	if info.IsDir() {
+		return fileState{}, fmt.Errorf("%s is a directory", filepath.Base(path))
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return fileState{}, fmt.Errorf("failed to read file: %w", err)
+	}
+	return fileState{exists: true, content: content, mode: info.Mode().Perm()}, nil
+}
+
+// apply stages a patch. Either every file of the patch is staged or, when
+// anything fails, none is.
+func (t *tree) apply(p Patch, opts Options) (*Report, error) {
+	report := &Report{}
+	diffs, _, err := Lint(p.UnifiedDiff, p.FilePath)
+	if err != nil {
+		return report, err
+	}
+
+	before := maps.Clone(t.staged)
+	touched := make(map[string]bool)
+	for _, d := range diffs {
+		names := []string{d.OldName()}
+		if d.NewName() != d.OldName() {
+			names = append(names, d.NewName())
+		}
+		for _, name := range names {
+			if name != "" && touched[name] {
+				t.staged = before
+				return report, fmt.Errorf("diff changes %s more than once", name)
+			}
+			touched[name] = true
+		}
+
+		fr, err := t.applyFile(d, opts)
+		report.Files = append(report.Files, fr)
+		if err != nil {
+			t.staged = before
+			return report, err
+		}
+	}
+	return report, nil
+}
+
+// applyFile stages the change one FileDiff makes
+func (t *tree) applyFile(d *FileDiff, opts Options) (FileReport, error) {
+	fr := FileReport{Path: d.Name(), Created: d.IsNew(), Deleted: d.IsDeleted()}
+	source := d.OldName()
+	if d.IsRename() {
+		fr.OldPath = source
+	}
+	if d.IsNew() {
+		source = d.NewName()
+	}
+
+	cur, err := t.read(source)
+	switch {
+	case err != nil:
+		return fr, err
+	case d.IsNew() && cur.exists:
+		return fr, fmt.Errorf("target file already exists: %s", source)
+	case !d.IsNew() && !cur.exists:
+		return fr, fmt.Errorf("target file does not exist: %s", source)
+	}
+	if d.IsRename() {
+		dest, err := t.read(d.NewName())
+		if err != nil {
+			return fr, err
+		}
+		if dest.exists {
+			return fr, fmt.Errorf("cannot rename %s: %s already exists", source, d.NewName())
+		}
+	}
+
+	f := splitFile(string(cur.content))
+	if err := f.applyHunks(d.Hunks, opts, &fr); err != nil {
+		return fr, fmt.Errorf("context mismatch in %s: %w", source, err)
+	}
+
+	if d.IsDeleted() {
+		// As git apply does, a deletion must account for every line of the file
+		if len(f.lines) > 0 {
+			return fr, fmt.Errorf("removal patch leaves %s contents: %d line(s) the diff does not remove", source, len(f.lines))
+		}
+		t.staged[source] = fileState{}
+		return fr, nil
+	}
+
+	mode := cur.mode
+	if !cur.exists {
+		mode = 0644
+	}
+	if d.NewMode != "" {
+		m, err := parseMode(d.NewMode)
+		if err != nil {
+			return fr, err
+		}
+		if m != mode && !d.IsNew() {
+			fr.Mode = m
+		}
+		mode = m
+	}
+
+	if d.IsRename() {
+		t.staged[source] = fileState{}
+	}
+	t.staged[d.NewName()] = fileState{exists: true, content: []byte(f.String()), mode: mode}
+	return fr, nil
+}
+
+// parseMode reads a git file mode such as "100755" into its permission bits
+func parseMode(mode string) (os.FileMode, error) {
+	m, err := strconv.ParseUint(mode, 8, 32)
+	if err != nil || m&^0o777 != 0o100000 {
+		return 0, fmt.Errorf("unsupported file mode %s; only regular files (100644, 100755) can be patched", mode)
+	}
+	return os.FileMode(m & 0o777), nil
+}
+
+// commit writes every staged file to disk. It first checks that every file the
+// patches read is still as it was, and writes nothing if one has changed. If a
+// write fails, the files already written are put back to their original bytes.
+// This is the ONLY place in the codebase that modifies files
+func (t *tree) commit() error {
+	for _, path := range slices.Sorted(maps.Keys(t.base)) {
+		now, err := readDisk(t.disk(path))
+		if err != nil {
+			return err
+		}
+		if !now.equal(t.base[path]) {
+			return fmt.Errorf("%s changed on disk after the patches were checked; nothing was written", path)
+		}
+	}
+
+	var written []string
+	for _, path := range slices.Sorted(maps.Keys(t.staged)) {
+		if err := writeFile(t.disk(path), t.staged[path]); err != nil {
+			err = fmt.Errorf("failed to write %s: %w", path, err)
+			for _, done := range slices.Backward(append(written, path)) {
+				if rerr := writeState(t.disk(done), t.base[done]); rerr != nil {
+					err = errors.Join(err, fmt.Errorf("failed to restore %s: %w", done, rerr))
+				}
+			}
+			return err
+		}
+		written = append(written, path)
+	}
+	return nil
+}
+
+func (s fileState) equal(o fileState) bool {
+	return s.exists == o.exists && s.mode == o.mode && bytes.Equal(s.content, o.content)
+}
+
+// writeFile writes one staged file; tests replace it to make a write fail
+var writeFile = writeState
+
+// writeState makes the file at path match s
+func writeState(path string, s fileState) error {
+	if !s.exists {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+	return writeAtomic(path, s.content, s.mode)
+}
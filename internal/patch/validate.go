@@ -1,10 +1,7 @@
 package patch
 
 import (
-	"bufio"
 	"fmt"
-	"os"
-	"path/filepath"
 	"strings"
 )
 
@@ -14,88 +11,91 @@ type Patch struct {
 	UnifiedDiff string
 }
 
-// Validate checks if a patch is valid and can be applied
-func Validate(repoRoot string, p Patch) error {
-	// Parse the unified diff to extract metadata
-	targetFile := filepath.Join(repoRoot, p.FilePath)
-
-	// Check if this is a new file creation
-	isNewFile := strings.Contains(p.UnifiedDiff, "--- /dev/null")
+// nearbyLines is how much of the file around a hunk a ContextError shows
+const nearbyLines = 3
+
+// ContextError reports a hunk whose context or removed lines differ from the file.
+// Its message quotes both versions of the line and the file's actual content
+// around the hunk, so whoever wrote the diff can regenerate it.
+type ContextError struct {
+	Hunk     int    // 1-based hunk number
+	Header   string // The hunk's @@ line
+	Line     int    // 1-based file line that differs
+	Expected string // Line as written in the diff
+	Actual   string // Line in the file; empty if the file is shorter
+	PastEOF  bool   // The file ends before Line
+	FoundAt  []int  // Lines where Expected does appear in the file
+	Nearby   string // Numbered file lines around the hunk
+}
 
-	if !isNewFile {
-		// File must exist for modification
-		if _, err := os.Stat(targetFile); os.IsNotExist(err) {
-			return fmt.Errorf("target file does not exist: %s", p.FilePath)
-		}
+func (e *ContextError) Error() string {
+	var msg strings.Builder
+	if e.PastEOF {
+		fmt.Fprintf(&msg, "hunk %d (%s) line %d: expected %q, but the file ends at line %d", e.Hunk, e.Header, e.Line, e.Expected, e.Line-1)
+	} else {
+		fmt.Fprintf(&msg, "hunk %d (%s) line %d: expected %q, found %q", e.Hunk, e.Header, e.Line, e.Expected, e.Actual)
+	}
 
-		// Verify context lines match current file state
-		if err := validateContext(targetFile, p.UnifiedDiff); err != nil {
-			return fmt.Errorf("context mismatch: %w", err)
-		}
+	switch len(e.FoundAt) {
+	case 0:
+	case 1:
+		fmt.Fprintf(&msg, "; the expected line is at line %d", e.FoundAt[0])
+	default:
+		fmt.Fprintf(&msg, "; the expected line appears %d times, first at line %d", len(e.FoundAt), e.FoundAt[0])
 	}
 
-	// Validate unified diff format
-	if !strings.Contains(p.UnifiedDiff, "---") || !strings.Contains(p.UnifiedDiff, "+++") {
-		return fmt.Errorf("invalid unified diff format: missing --- or +++ headers")
+	if e.Nearby != "" {
+		msg.WriteString("\nActual file content near the hunk:\n")
+		msg.WriteString(e.Nearby)
 	}
+	return msg.String()
+}
 
-	return nil
+// Validate checks that a patch is well-formed and applies to the repository as it is now
+func Validate(repoRoot string, p Patch) error {
+	_, err := DryRun(repoRoot, p, DefaultOptions)
+	return err
 }
 
-// validateContext checks if context lines in the diff match the current file
-func validateContext(filePath string, diff string) error {
-	// Read current file
-	content, err := os.ReadFile(filePath)
-	if err != nil {
-		return fmt.Errorf("failed to read file: %w", err)
+// matchesAt reports whether block appears in lines at the 0-based index at
+func matchesAt(lines, block []string, at int) bool {
+	if at < 0 || at+len(block) > len(lines) {
+		return false
 	}
-
-	lines := strings.Split(string(content), "\n")
-
-	// Parse diff to extract hunks
-	scanner := bufio.NewScanner(strings.NewReader(diff))
-	var currentLine int
-
-	for scanner.Scan() {
-		line := scanner.Text()
-
-		// Parse hunk header: @@ -start,count +start,count @@
-		if strings.HasPrefix(line, "@@") {
-			// Extract old file line number
-			parts := strings.Split(line, " ")
-			if len(parts) >= 2 {
-				oldPart := strings.TrimPrefix(parts[1], "-")
-				if strings.Contains(oldPart, ",") {
-					fmt.Sscanf(oldPart, "%d", &currentLine)
-				} else {
-					fmt.Sscanf(oldPart, "%d", &currentLine)
-				}
-				currentLine-- // Convert to 0-indexed
-			}
-			continue
+	for i, line := range block {
+		if lines[at+i] != line {
+			return false
 		}
+	}
+	return true
+}
 
-		// Context line (starts with space) or deletion (starts with -)
-		if strings.HasPrefix(line, " ") || strings.HasPrefix(line, "-") {
-			contextContent := line[1:] // Remove prefix
-
-			if currentLine >= 0 && currentLine < len(lines) {
-				if lines[currentLine] != contextContent {
-					// Context doesn't match - this means file has changed
-					return fmt.Errorf("context line %d doesn't match: expected %q, got %q",
-						currentLine+1, contextContent, lines[currentLine])
-				}
-			}
-
-			// Only increment for context and deletions (not additions)
-			if !strings.HasPrefix(line, "+") {
-				currentLine++
-			}
-		} else if strings.HasPrefix(line, "+") {
-			// Addition - doesn't affect current file line tracking
-			continue
+// findLine returns the 1-based lines equal to text, ignoring blank text
+func findLine(lines []string, text string) []int {
+	if strings.TrimSpace(text) == "" {
+		return nil
+	}
+	var found []int
+	for i, line := range lines {
+		if line == text {
+			found = append(found, i+1)
 		}
 	}
+	return found
+}
 
-	return nil
+// numberedLines renders the 1-based lines first..last, clamped to the file
+func numberedLines(lines []string, first, last int) string {
+	first = max(first, 1)
+	last = min(last, len(lines))
+	if first > last {
+		return ""
+	}
+
+	width := len(fmt.Sprint(last))
+	var out strings.Builder
+	for n := first; n <= last; n++ {
+		fmt.Fprintf(&out, "%*d  %s\n", width, n, lines[n-1])
+	}
+	return out.String()
 }
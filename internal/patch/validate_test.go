@@ -0,0 +1,96 @@
+package patch
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const mainGo = "package main\n\nimport \"fmt\"\n\nfunc main() {\n\tfmt.Println(\"hello\")\n}\n"
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		diff    string
+		wantErr string // Substring of the error; empty for a clean patch
+	}{
+		{
+			name: "clean patch with a/ b/ prefixes",
+			file: "main.go",
+			diff: "--- a/main.go\n+++ b/main.go\n@@ -5,3 +5,3 @@\n func main() {\n-\tfmt.Println(\"hello\")\n+\tfmt.Println(\"hi\")\n }\n",
+		},
+		{
+			name: "hunk at the wrong line number",
+			file: "main.go",
+			diff: "--- main.go\n+++ main.go\n@@ -1,3 +1,3 @@\n func main() {\n-\tfmt.Println(\"hello\")\n+\tfmt.Println(\"hi\")\n }\n",
+		},
+		{
+			name: "blank context line without its space",
+			file: "main.go",
+			diff: "--- main.go\n+++ main.go\n@@ -1,3 +1,3 @@\n package main\n\n-import \"fmt\"\n+import \"os\"\n",
+		},
+		{
+			name:    "stale context",
+			file:    "main.go",
+			diff:    "--- main.go\n+++ main.go\n@@ -5,3 +5,3 @@\n func main() {\n-\tfmt.Println(\"bye\")\n+\tfmt.Println(\"hi\")\n }\n",
+			wantErr: "hunk 1 (@@ -5,3 +5,3 @@) line 6: expected \"\\tfmt.Println(\\\"bye\\\")\", found \"\\tfmt.Println(\\\"hello\\\")\"\nActual file content near the hunk:\n2  \n3  import \"fmt\"\n4  \n5  func main() {\n6  \tfmt.Println(\"hello\")\n7  }\n",
+		},
+		{
+			name:    "line found elsewhere",
+			file:    "main.go",
+			diff:    "--- main.go\n+++ main.go\n@@ -1,2 +1 @@\n-import \"fmt\"\n-package main\n+import \"os\"\n",
+			wantErr: "the expected line is at line 3",
+		},
+		{
+			name:    "past the end of the file",
+			file:    "main.go",
+			diff:    "--- main.go\n+++ main.go\n@@ -7,2 +7,3 @@\n }\n-// end\n+// done\n",
+			wantErr: "expected \"// end\", but the file ends at line 7",
+		},
+		{
+			name:    "missing file",
+			file:    "other.go",
+			diff:    "--- other.go\n+++ other.go\n@@ -1 +1 @@\n-a\n+b\n",
+			wantErr: "target file does not exist: other.go",
+		},
+		{
+			name: "new file",
+			file: "new.go",
+			diff: "--- /dev/null\n+++ b/new.go\n@@ -0,0 +1 @@\n+package main\n",
+		},
+		{
+			name:    "new file that exists",
+			file:    "main.go",
+			diff:    "--- /dev/null\n+++ b/main.go\n@@ -0,0 +1 @@\n+package main\n",
+			wantErr: "target file already exists: main.go",
+		},
+		{
+			name:    "no hunks",
+			file:    "main.go",
+			diff:    "--- a/main.go\n+++ b/main.go\n",
+			wantErr: "no @@ hunk headers",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := t.TempDir()
+			if err := os.WriteFile(filepath.Join(root, "main.go"), []byte(mainGo), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			err := Validate(root, Patch{FilePath: tt.file, UnifiedDiff: tt.diff})
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("expected error containing %q, got: %v", tt.wantErr, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,93 @@
+package review
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/yourusername/playground/internal/patch"
+	"github.com/yourusername/playground/internal/session"
+)
+
+// Apply writes the pending patches at indexes in one transaction and moves
+// them to the session's applied patches. Each patch is staged in memory on
+// top of the ones before it, and nothing is written unless every one applies.
+// The caller saves the session.
+func Apply(repoRoot string, sess *session.Session, indexes []int, opts patch.Options) error {
+	tx := patch.NewTransaction(repoRoot, opts)
+	for n, i := range indexes {
+		p := sess.PendingPatches[i]
+		fmt.Printf("Checking patch %d/%d: %s... ", n+1, len(indexes), strings.Join(p.Paths(), ", "))
+
+		report, err := tx.Stage(patch.Patch{FilePath: p.FilePath, UnifiedDiff: p.UnifiedDiff})
+		if err != nil {
+			fmt.Printf("❌ FAILED\n")
+			PrintReport(report)
+			fmt.Printf("Error: %v\n", err)
+			fmt.Printf("\nNo patches were applied; all %d are still pending.\n", len(sess.PendingPatches))
+			return fmt.Errorf("patch application failed")
+		}
+
+		fmt.Printf("✓\n")
+		if report.Inexact() {
+			PrintReport(report)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		fmt.Printf("Error: %v\n", err)
+		fmt.Printf("\nNo patches were applied; all %d are still pending.\n", len(sess.PendingPatches))
+		return fmt.Errorf("patch application failed")
+	}
+
+	// Record exactly which patches were written
+	sess.MarkApplied(indexes, time.Now())
+	return nil
+}
+
+// All returns the indexes of every pending patch
+func All(sess *session.Session) []int {
+	indexes := make([]int, len(sess.PendingPatches))
+	for i := range indexes {
+		indexes[i] = i
+	}
+	return indexes
+}
+
+// PrintPatch shows a pending patch: its files, how it came about, and its diff
+func PrintPatch(p session.Patch, number, total int) {
+	fmt.Printf("═══ Patch %d/%d ═══\n", number, total)
+	printFiles(p)
+	if p.Retries > 0 {
+		fmt.Printf("Retries: %d (earlier attempts did not apply)\n", p.Retries)
+	}
+	for _, r := range p.Repairs {
+		fmt.Printf("Repaired: %s\n", r)
+	}
+	fmt.Printf("Created: %s\n\n", p.CreatedAt.Format("2006-01-02 15:04:05"))
+	fmt.Println(p.UnifiedDiff)
+	fmt.Println()
+}
+
+// printFiles lists the files a patch changes, with any rename, deletion or mode change
+func printFiles(p session.Patch) {
+	diffs, err := patch.Parse(p.UnifiedDiff)
+	if err != nil || len(diffs) == 1 && diffs[0].Summary() == p.FilePath {
+		fmt.Printf("File: %s\n", p.FilePath)
+		return
+	}
+	fmt.Println("Files:")
+	for _, d := range diffs {
+		fmt.Printf("  %s\n", d.Summary())
+	}
+}
+
+// PrintReport lists how each file and hunk of a patch applied
+func PrintReport(report *patch.Report) {
+	if report == nil {
+		return
+	}
+	for _, line := range strings.Split(strings.TrimSuffix(report.String(), "\n"), "\n") {
+		fmt.Printf("   %s\n", line)
+	}
+}
@@ -0,0 +1,319 @@
+// Package review lets the user decide what happens to the patches the agent
+// proposes: accept, reject, skip or edit each patch or each of its hunks, then
+// apply the accepted ones together.
+package review
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/yourusername/playground/internal/patch"
+	"github.com/yourusername/playground/internal/session"
+)
+
+// Reviewer walks the user through a session's pending patches
+type Reviewer struct {
+	Session  *session.Session
+	ReadLine func() (string, bool)             // Reads a line of user input; ok is false at end of input
+	Edit     func(text string) (string, error) // Lets the user change a diff; nil opens $EDITOR
+}
+
+// outcome is what the user made of one patch
+type outcome struct {
+	accepted *session.Patch // The part to apply, if any
+	skipped  *session.Patch // The part left pending, if any
+	quit     bool           // Stop reviewing; later patches stay pending
+}
+
+// unit is one change the user decides on in hunk mode: one hunk of a file,
+// or a whole file when the change cannot be split
+type unit struct {
+	file     int
+	diff     *patch.FileDiff // The file's headers and the hunks under review
+	whole    bool
+	decision byte // 'a', 'r' or 's'
+}
+
+// Run reviews every pending patch. Rejected patches and hunks are dropped and
+// noted for the agent; accepted and skipped ones stay pending, split where
+// the user decided hunk by hunk. It returns the indexes of the accepted
+// patches in the rewritten pending list, ready for Apply.
+func (r *Reviewer) Run() []int {
+	patches := r.Session.PendingPatches
+	var pending []session.Patch
+	var accepted []int
+	quit := false
+
+	for i, p := range patches {
+		if quit {
+			pending = append(pending, p)
+			continue
+		}
+		o := r.reviewPatch(p, i+1, len(patches))
+		if o.accepted != nil {
+			accepted = append(accepted, len(pending))
+			pending = append(pending, *o.accepted)
+		}
+		if o.skipped != nil {
+			pending = append(pending, *o.skipped)
+		}
+		quit = o.quit
+	}
+
+	r.Session.PendingPatches = pending
+	return accepted
+}
+
+// reviewPatch asks what to do with a whole patch
+func (r *Reviewer) reviewPatch(p session.Patch, number, total int) outcome {
+	show := true
+	for {
+		if show {
+			PrintPatch(p, number, total)
+			show = false
+		}
+
+		choice, ok := r.ask("[a]ccept, [r]eject, [s]kip, [e]dit, [h]unks, [q]uit? ")
+		switch {
+		case !ok || choice == 'q':
+			return outcome{skipped: &p, quit: true}
+		case choice == 'a':
+			return outcome{accepted: &p}
+		case choice == 's':
+			return outcome{skipped: &p}
+		case choice == 'r':
+			addNote(r.Session, "your patch to "+strings.Join(p.Paths(), ", "), r.reason())
+			return outcome{}
+		case choice == 'e':
+			filePath := p.FilePath
+			if len(p.Files) > 1 {
+				filePath = ""
+			}
+			diffs, err := r.editDiff(p.UnifiedDiff, filePath)
+			if err != nil {
+				fmt.Printf("Edit discarded: %v\n", err)
+				continue
+			}
+			p = withDiffs(p, diffs)
+			show = true
+		case choice == 'h':
+			if o, ok := r.reviewHunks(p, number, total); ok {
+				return o
+			}
+		default:
+			fmt.Println("a: apply this patch, r: drop it and tell the agent, s: leave it pending,")
+			fmt.Println("e: edit it in $EDITOR, h: decide hunk by hunk, q: stop reviewing")
+		}
+	}
+}
+
+// reviewHunks asks about each hunk of a patch in turn. It reports false when
+// the patch cannot be split, and the user decides on it as a whole.
+func (r *Reviewer) reviewHunks(p session.Patch, number, total int) (outcome, bool) {
+	diffs, err := patch.Parse(p.UnifiedDiff)
+	if err != nil {
+		fmt.Printf("Cannot split this patch: %v\n", err)
+		return outcome{}, false
+	}
+
+	units := splitUnits(diffs)
+	if len(units) < 2 {
+		fmt.Println("This patch is a single change; decide on it as a whole.")
+		return outcome{}, false
+	}
+
+	quit := false
+	for n := range units {
+		u := &units[n]
+		if quit {
+			u.decision = 's'
+			continue
+		}
+
+		show := true
+		for u.decision == 0 {
+			if show {
+				fmt.Printf("─── Patch %d/%d, change %d/%d: %s ───\n", number, total, n+1, len(units), u.diff.Summary())
+				fmt.Println(u.diff.String())
+				show = false
+			}
+
+			choice, ok := r.ask("[a]ccept, [r]eject, [s]kip, [e]dit, [q]uit? ")
+			switch {
+			case !ok || choice == 'q':
+				u.decision, quit = 's', true
+			case choice == 'a' || choice == 's':
+				u.decision = choice
+			case choice == 'r':
+				addNote(r.Session, describe(u), r.reason())
+				u.decision = 'r'
+			case choice == 'e':
+				if err := r.editUnit(u); err != nil {
+					fmt.Printf("Edit discarded: %v\n", err)
+					continue
+				}
+				show = true
+			default:
+				fmt.Println("a: apply this change, r: drop it and tell the agent, s: leave it pending,")
+				fmt.Println("e: edit it in $EDITOR, q: stop reviewing")
+			}
+		}
+	}
+
+	o := outcome{quit: quit}
+	if accepted := gather(diffs, units, 'a'); len(accepted) > 0 {
+		a := withDiffs(p, accepted)
+		o.accepted = &a
+	}
+	if skipped := gather(diffs, units, 's'); len(skipped) > 0 {
+		s := withDiffs(p, skipped)
+		o.skipped = &s
+	}
+	return o, true
+}
+
+// splitUnits lists the changes a patch makes: each hunk of a changed file, and
+// each created, deleted, renamed or mode-changed file as a whole
+func splitUnits(diffs []*patch.FileDiff) []unit {
+	var units []unit
+	for i, d := range diffs {
+		if len(d.Hunks) < 2 || d.IsNew() || d.IsDeleted() || d.IsRename() || d.NewMode != "" {
+			units = append(units, unit{file: i, diff: d, whole: true})
+			continue
+		}
+		for _, h := range d.Hunks {
+			units = append(units, unit{file: i, diff: d.WithHunks([]patch.Hunk{h})})
+		}
+	}
+	return units
+}
+
+// gather puts the units with a decision back together into file diffs
+func gather(diffs []*patch.FileDiff, units []unit, decision byte) []*patch.FileDiff {
+	var out []*patch.FileDiff
+	for i, d := range diffs {
+		var hunks []patch.Hunk
+		for _, u := range units {
+			if u.file != i || u.decision != decision {
+				continue
+			}
+			if u.whole {
+				out = append(out, u.diff)
+			} else {
+				hunks = append(hunks, u.diff.Hunks...)
+			}
+		}
+		if len(hunks) > 0 {
+			out = append(out, d.WithHunks(hunks))
+		}
+	}
+	return out
+}
+
+// describe names a unit in a note for the agent
+func describe(u *unit) string {
+	if u.whole {
+		return "your change to " + u.diff.Summary()
+	}
+	return fmt.Sprintf("hunk %s of your change to %s", u.diff.Hunks[0].Header(), u.diff.Name())
+}
+
+// editUnit lets the user edit one unit. An edited hunk stays in its file; it
+// may become several hunks, but its headers cannot move it elsewhere.
+func (r *Reviewer) editUnit(u *unit) error {
+	diffs, err := r.editDiff(u.diff.String(), u.diff.Name())
+	if err != nil {
+		return err
+	}
+	if u.whole {
+		if len(diffs) != 1 {
+			return fmt.Errorf("the edited diff must change exactly one file")
+		}
+		u.diff = diffs[0]
+		return nil
+	}
+	if len(diffs) != 1 || len(diffs[0].Hunks) == 0 {
+		return fmt.Errorf("an edited hunk must stay within %s", u.diff.Name())
+	}
+	u.diff = u.diff.WithHunks(diffs[0].Hunks)
+	return nil
+}
+
+// editDiff opens a diff in the editor and reads back what the user saved,
+// repairing it the way proposed diffs are
+func (r *Reviewer) editDiff(text, filePath string) ([]*patch.FileDiff, error) {
+	edit := r.Edit
+	if edit == nil {
+		edit = editInEditor
+	}
+	edited, err := edit(text)
+	if err != nil {
+		return nil, err
+	}
+	diffs, repairs, err := patch.Lint(edited, filePath)
+	if err != nil {
+		return nil, err
+	}
+	for _, repair := range repairs {
+		fmt.Printf("Repaired: %s\n", repair)
+	}
+	return diffs, nil
+}
+
+// ask prints a prompt and returns the first letter of the answer, lowercased
+func (r *Reviewer) ask(prompt string) (byte, bool) {
+	fmt.Print(prompt)
+	line, ok := r.ReadLine()
+	if !ok {
+		return 0, false
+	}
+	line = strings.ToLower(strings.TrimSpace(line))
+	if line == "" {
+		return '?', true
+	}
+	return line[0], true
+}
+
+// reason asks why a change was rejected; an empty answer gives none
+func (r *Reviewer) reason() string {
+	fmt.Print("Reason for the agent (optional): ")
+	line, _ := r.ReadLine()
+	return strings.TrimSpace(line)
+}
+
+// editInEditor opens text in $EDITOR, or vi when it is unset, and returns the
+// file as the user saved it
+func editInEditor(text string) (string, error) {
+	f, err := os.CreateTemp("", "pg-*.diff")
+	if err != nil {
+		return "", fmt.Errorf("failed to create temp file: %w", err)
+	}
+	defer os.Remove(f.Name())
+
+	if _, err := f.WriteString(text); err != nil {
+		f.Close()
+		return "", fmt.Errorf("failed to write temp file: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return "", fmt.Errorf("failed to write temp file: %w", err)
+	}
+
+	editor := os.Getenv("EDITOR")
+	if editor == "" {
+		editor = "vi"
+	}
+	// Through the shell, so that EDITOR may carry arguments such as "code --wait"
+	cmd := exec.Command("sh", "-c", editor+` "$1"`, "sh", f.Name())
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("editor failed: %w", err)
+	}
+
+	edited, err := os.ReadFile(f.Name())
+	if err != nil {
+		return "", fmt.Errorf("failed to read edited diff: %w", err)
+	}
+	return string(edited), nil
+}
@@ -0,0 +1,125 @@
+package review
+
+import (
+	"bufio"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/yourusername/playground/internal/session"
+)
+
+const (
+	mainDiff = "--- a/main.go\n+++ b/main.go\n@@ -1 +1 @@\n-package main\n+package app\n@@ -10 +10 @@\n-\tfmt.Println(\"hello\")\n+\tfmt.Println(\"hi\")\n"
+	utilDiff = "--- a/util.go\n+++ b/util.go\n@@ -3 +3 @@\n-var x = 1\n+var x = 2\n"
+
+	mainFirst  = "--- a/main.go\n+++ b/main.go\n@@ -1 +1 @@\n-package main\n+package app\n"
+	mainSecond = "--- a/main.go\n+++ b/main.go\n@@ -10 +10 @@\n-\tfmt.Println(\"hello\")\n+\tfmt.Println(\"hi\")\n"
+)
+
+func TestReviewer(t *testing.T) {
+	tests := []struct {
+		name         string
+		input        string
+		edit         func(string) (string, error)
+		wantAccepted []int
+		wantPending  []string
+		wantFeedback []string
+	}{
+		{
+			name:         "accept one, reject the other with a reason",
+			input:        "a\nr\nkeep x at 1\n",
+			wantAccepted: []int{0},
+			wantPending:  []string{mainDiff},
+			wantFeedback: []string{"The user rejected your patch to util.go: keep x at 1"},
+		},
+		{
+			name:        "skip and quit",
+			input:       "s\nq\n",
+			wantPending: []string{mainDiff, utilDiff},
+		},
+		{
+			name:        "end of input leaves everything pending",
+			input:       "",
+			wantPending: []string{mainDiff, utilDiff},
+		},
+		{
+			name:         "hunk by hunk",
+			input:        "h\nr\n\na\na\n",
+			wantAccepted: []int{0, 1},
+			wantPending:  []string{mainSecond, utilDiff},
+			wantFeedback: []string{"The user rejected hunk @@ -1 +1 @@ of your change to main.go"},
+		},
+		{
+			name:         "accepted and skipped hunks split the patch",
+			input:        "h\ns\na\ns\n",
+			wantAccepted: []int{0},
+			wantPending:  []string{mainSecond, mainFirst, utilDiff},
+		},
+		{
+			name:         "unknown answers ask again",
+			input:        "x\n\ns\nh\na\n",
+			wantAccepted: []int{1},
+			wantPending:  []string{mainDiff, utilDiff},
+		},
+		{
+			name:  "edit a patch",
+			input: "e\na\ns\n",
+			edit: func(text string) (string, error) {
+				return strings.Replace(text, "package app", "package server", 1), nil
+			},
+			wantAccepted: []int{0},
+			wantPending:  []string{strings.Replace(mainDiff, "package app", "package server", 1), utilDiff},
+		},
+		{
+			name:  "edit a hunk",
+			input: "h\ns\ne\na\ns\n",
+			edit: func(text string) (string, error) {
+				return strings.Replace(text, "\"hi\"", "\"hey\"", 1), nil
+			},
+			wantAccepted: []int{0},
+			wantPending:  []string{strings.Replace(mainSecond, "\"hi\"", "\"hey\"", 1), mainFirst, utilDiff},
+		},
+		{
+			name:  "broken edit is discarded",
+			input: "e\na\ns\n",
+			edit: func(text string) (string, error) {
+				return "not a diff", nil
+			},
+			wantAccepted: []int{0},
+			wantPending:  []string{mainDiff, utilDiff},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sess := &session.Session{PendingPatches: []session.Patch{
+				{FilePath: "main.go", UnifiedDiff: mainDiff},
+				{FilePath: "util.go", UnifiedDiff: utilDiff},
+			}}
+			input := bufio.NewScanner(strings.NewReader(tt.input))
+			r := &Reviewer{Session: sess, Edit: tt.edit, ReadLine: func() (string, bool) {
+				if !input.Scan() {
+					return "", false
+				}
+				return input.Text(), true
+			}}
+
+			accepted := r.Run()
+
+			if !slices.Equal(accepted, tt.wantAccepted) {
+				t.Errorf("accepted %v, want %v", accepted, tt.wantAccepted)
+			}
+			var pending []string
+			for _, p := range sess.PendingPatches {
+				pending = append(pending, p.UnifiedDiff)
+			}
+			if strings.Join(pending, "\n=====\n") != strings.Join(tt.wantPending, "\n=====\n") {
+				t.Errorf("pending:\n%s\nwant:\n%s", strings.Join(pending, "\n=====\n"), strings.Join(tt.wantPending, "\n=====\n"))
+			}
+			if strings.Join(sess.Feedback, "\n") != strings.Join(tt.wantFeedback, "\n") {
+				t.Errorf("feedback %q, want %q", sess.Feedback, tt.wantFeedback)
+			}
+		})
+	}
+}
@@ -0,0 +1,133 @@
+package review
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/yourusername/playground/internal/patch"
+	"github.com/yourusername/playground/internal/session"
+)
+
+// ParseNumbers reads patch numbers as review shows them, such as "2,4", into
+// indexes of the n pending patches, in order and without repeats
+func ParseNumbers(spec string, n int) ([]int, error) {
+	var indexes []int
+	for _, field := range strings.Split(spec, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		number, err := strconv.Atoi(field)
+		if err != nil {
+			return nil, fmt.Errorf("invalid patch number %q", field)
+		}
+		if number < 1 || number > n {
+			return nil, fmt.Errorf("no patch %d; there are %d pending", number, n)
+		}
+		if !slices.Contains(indexes, number-1) {
+			indexes = append(indexes, number-1)
+		}
+	}
+	if len(indexes) == 0 {
+		return nil, fmt.Errorf("no patch numbers given")
+	}
+	slices.Sort(indexes)
+	return indexes, nil
+}
+
+// SelectFiles narrows the pending patches at indexes to the parts that change
+// one of files. A patch that also changes other files is split in two, and the
+// part for the other files stays pending after it. It returns the indexes of
+// the selected patches in the rewritten pending list.
+func SelectFiles(sess *session.Session, indexes []int, files []string) ([]int, error) {
+	var pending []session.Patch
+	var selected []int
+	matched := make(map[string]bool)
+
+	for i, p := range sess.PendingPatches {
+		if !slices.Contains(indexes, i) {
+			pending = append(pending, p)
+			continue
+		}
+		diffs, err := patch.Parse(p.UnifiedDiff)
+		if err != nil {
+			return nil, fmt.Errorf("patch %d: %w", i+1, err)
+		}
+
+		var in, out []*patch.FileDiff
+		for _, d := range diffs {
+			hit := false
+			for _, f := range files {
+				if d.OldName() == f || d.NewName() == f {
+					matched[f], hit = true, true
+				}
+			}
+			if hit {
+				in = append(in, d)
+			} else {
+				out = append(out, d)
+			}
+		}
+
+		if len(in) > 0 {
+			selected = append(selected, len(pending))
+			pending = append(pending, withDiffs(p, in))
+		}
+		if len(out) > 0 {
+			pending = append(pending, withDiffs(p, out))
+		}
+	}
+
+	for _, f := range files {
+		if !matched[f] {
+			return nil, fmt.Errorf("no selected patch changes %s", f)
+		}
+	}
+	sess.PendingPatches = pending
+	return selected, nil
+}
+
+// Reject drops the pending patches at indexes and leaves a note telling the
+// agent, with the user's reason when there is one
+func Reject(sess *session.Session, indexes []int, reason string) {
+	var pending []session.Patch
+	for i, p := range sess.PendingPatches {
+		if slices.Contains(indexes, i) {
+			addNote(sess, "your patch to "+strings.Join(p.Paths(), ", "), reason)
+		} else {
+			pending = append(pending, p)
+		}
+	}
+	sess.PendingPatches = pending
+}
+
+// addNote records a rejection for the agent's next turn
+func addNote(sess *session.Session, what, reason string) {
+	note := "The user rejected " + what
+	if reason != "" {
+		note += ": " + reason
+	}
+	sess.Feedback = append(sess.Feedback, note)
+}
+
+// withDiffs returns p changed to hold just diffs
+func withDiffs(p session.Patch, diffs []*patch.FileDiff) session.Patch {
+	var files []string
+	for _, d := range diffs {
+		for _, name := range []string{d.OldName(), d.NewName()} {
+			if name != "" && !slices.Contains(files, name) {
+				files = append(files, name)
+			}
+		}
+	}
+
+	p.UnifiedDiff = patch.Format(diffs)
+	p.FilePath = files[0]
+	p.Files = nil
+	if len(files) > 1 {
+		p.Files = files
+	}
+	return p
+}
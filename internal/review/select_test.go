@@ -0,0 +1,136 @@
+package review
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/yourusername/playground/internal/session"
+)
+
+func TestParseNumbers(t *testing.T) {
+	tests := []struct {
+		spec    string
+		want    []int
+		wantErr string
+	}{
+		{spec: "2,4", want: []int{1, 3}},
+		{spec: "4, 2,2", want: []int{1, 3}},
+		{spec: "1", want: []int{0}},
+		{spec: "5", wantErr: "no patch 5; there are 4 pending"},
+		{spec: "0", wantErr: "no patch 0"},
+		{spec: "two", wantErr: "invalid patch number \"two\""},
+		{spec: ",", wantErr: "no patch numbers given"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			got, err := ParseNumbers(tt.spec, 4)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("expected error containing %q, got: %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSelectFiles(t *testing.T) {
+	both := mainDiff + utilDiff
+	tests := []struct {
+		name        string
+		indexes     []int
+		files       []string
+		want        []int
+		wantPending []string
+		wantErr     string
+	}{
+		{
+			name:        "patch split by file",
+			indexes:     []int{0, 1},
+			files:       []string{"util.go"},
+			want:        []int{1},
+			wantPending: []string{mainDiff, utilDiff, mainDiff},
+		},
+		{
+			name:        "only some patches",
+			indexes:     []int{1},
+			files:       []string{"main.go"},
+			want:        []int{1},
+			wantPending: []string{mainDiff, mainDiff, utilDiff},
+		},
+		{
+			name:    "file no selected patch changes",
+			indexes: []int{0},
+			files:   []string{"util.go"},
+			wantErr: "no selected patch changes util.go",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sess := &session.Session{PendingPatches: []session.Patch{
+				{FilePath: "main.go", UnifiedDiff: mainDiff},
+				{FilePath: "main.go", Files: []string{"main.go", "util.go"}, UnifiedDiff: both},
+			}}
+
+			got, err := SelectFiles(sess, tt.indexes, tt.files)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("expected error containing %q, got: %v", tt.wantErr, err)
+				}
+				if len(sess.PendingPatches) != 2 {
+					t.Errorf("a failed selection changed the pending patches")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+			var pending []string
+			for _, p := range sess.PendingPatches {
+				pending = append(pending, p.UnifiedDiff)
+				if len(p.Paths()) != strings.Count(p.UnifiedDiff, "+++ ") {
+					t.Errorf("patch lists files %v for:\n%s", p.Paths(), p.UnifiedDiff)
+				}
+			}
+			if !slices.Equal(pending, tt.wantPending) {
+				t.Errorf("pending:\n%s\nwant:\n%s", strings.Join(pending, "\n=====\n"), strings.Join(tt.wantPending, "\n=====\n"))
+			}
+		})
+	}
+}
+
+func TestReject(t *testing.T) {
+	sess := &session.Session{PendingPatches: []session.Patch{
+		{FilePath: "main.go", UnifiedDiff: mainDiff},
+		{FilePath: "util.go", UnifiedDiff: utilDiff},
+		{FilePath: "main.go", Files: []string{"main.go", "util.go"}, UnifiedDiff: mainDiff + utilDiff},
+	}}
+
+	Reject(sess, []int{0, 2}, "")
+	Reject(sess, []int{0}, "wrong approach")
+
+	if len(sess.PendingPatches) != 0 {
+		t.Errorf("expected no pending patches, got %d", len(sess.PendingPatches))
+	}
+	want := []string{
+		"The user rejected your patch to main.go",
+		"The user rejected your patch to main.go, util.go",
+		"The user rejected your patch to util.go: wrong approach",
+	}
+	if !slices.Equal(sess.Feedback, want) {
+		t.Errorf("feedback %q, want %q", sess.Feedback, want)
+	}
+}
@@ -1,25 +1,132 @@
 package session
 
 import (
+	"fmt"
+	"sort"
+	"strings"
 	"time"
 )
 
 // Session represents a PlayGround coding session
 type Session struct {
 	ID             string     `json:"id"`
-	Repo           string     `json:"repo"`            // Absolute path to repository
-	Goal           string     `json:"goal"`            // User's stated goal for this session
-	ContextSummary string     `json:"context_summary"` // AI-maintained summary of session progress
-	PendingPatches []Patch    `json:"pending_patches"` // Diffs proposed by agent, not yet applied
-	ToolHistory    []ToolCall `json:"tool_history"`    // Record of all tool invocations
+	Repo           string     `json:"repo"`                      // Absolute path to repository
+	Goal           string     `json:"goal"`                      // User's stated goal for this session
+	ContextSummary string     `json:"context_summary"`           // AI-maintained summary of session progress
+	PendingPatches []Patch    `json:"pending_patches"`           // Diffs proposed by agent, not yet applied
+	AppliedPatches []Patch    `json:"applied_patches,omitempty"` // Patches written to the repository, oldest first
+	Feedback       []string   `json:"feedback,omitempty"`        // Review notes for the agent, sent with the next turn
+	ToolHistory    []ToolCall `json:"tool_history"`              // Record of all tool invocations
+	History        []Message  `json:"history"`                   // Conversation with the agent, excluding the system prompt
+	Stats          Stats      `json:"stats"`
 	CreatedAt      time.Time  `json:"created_at"`
 }
 
+// Stats counts how the agent's tool use went over the session
+type Stats struct {
+	ArgumentErrors  map[string]int `json:"argument_errors,omitempty"`  // Tool calls rejected for invalid arguments, per tool
+	RejectedPatches int            `json:"rejected_patches,omitempty"` // Proposed patches that would not have applied
+	PatchRetries    map[string]int `json:"patch_retries,omitempty"`    // Rejections per file since its last accepted patch
+}
+
+// RecordRejectedPatch counts a proposed patch for files that would not have applied
+func (s *Stats) RecordRejectedPatch(files ...string) {
+	if s.PatchRetries == nil {
+		s.PatchRetries = make(map[string]int)
+	}
+	s.RejectedPatches++
+	for _, file := range files {
+		s.PatchRetries[file]++
+	}
+}
+
+// TakePatchRetries returns how many patches for any of files were rejected
+// since the last one that was accepted, and starts counting again
+func (s *Stats) TakePatchRetries(files ...string) int {
+	retries := 0
+	for _, file := range files {
+		retries = max(retries, s.PatchRetries[file])
+		delete(s.PatchRetries, file)
+	}
+	return retries
+}
+
+// RecordArgumentError counts a tool call whose arguments failed validation
+func (s *Stats) RecordArgumentError(tool string) {
+	if s.ArgumentErrors == nil {
+		s.ArgumentErrors = make(map[string]int)
+	}
+	s.ArgumentErrors[tool]++
+}
+
+// DescribeArgumentErrors summarises the argument errors, e.g. "read_file 2, run_command 1"
+func (s Stats) DescribeArgumentErrors() string {
+	tools := make([]string, 0, len(s.ArgumentErrors))
+	for tool := range s.ArgumentErrors {
+		tools = append(tools, tool)
+	}
+	sort.Strings(tools)
+
+	parts := make([]string, 0, len(tools))
+	for _, tool := range tools {
+		parts = append(parts, fmt.Sprintf("%s %d", tool, s.ArgumentErrors[tool]))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// Message is one turn of the agent conversation, as sent to the model
+type Message struct {
+	Role       string            `json:"role"` // "user", "assistant" or "tool"
+	Content    string            `json:"content"`
+	Name       string            `json:"name,omitempty"`         // Tool name, for tool results
+	ToolCalls  []MessageToolCall `json:"tool_calls,omitempty"`   // Tools an assistant message invoked
+	ToolCallID string            `json:"tool_call_id,omitempty"` // For tool results: the call being answered
+}
+
+// MessageToolCall is a tool invocation made by an assistant message
+type MessageToolCall struct {
+	ID        string                 `json:"id"`
+	Name      string                 `json:"name"`
+	Arguments map[string]interface{} `json:"arguments"`
+}
+
 // Patch represents a proposed code change as a unified diff
 type Patch struct {
-	FilePath    string    `json:"file_path"`    // Relative path from repo root
-	UnifiedDiff string    `json:"unified_diff"` // Complete unified diff format
+	FilePath    string    `json:"file_path"`         // Relative path from repo root; the first file of a multi-file patch
+	Files       []string  `json:"files,omitempty"`   // Every file a patch changes, when there are several
+	UnifiedDiff string    `json:"unified_diff"`      // Complete unified diff format, possibly covering several files
+	Retries     int       `json:"retries,omitempty"` // Rejected attempts before this diff applied cleanly
+	Repairs     []string  `json:"repairs,omitempty"` // Fixes made to the diff as proposed, e.g. recounted hunk headers
 	CreatedAt   time.Time `json:"created_at"`
+	AppliedAt   time.Time `json:"applied_at,omitzero"` // When the patch was committed; zero while pending
+}
+
+// Paths lists the files a patch changes
+func (p Patch) Paths() []string {
+	if len(p.Files) > 0 {
+		return p.Files
+	}
+	return []string{p.FilePath}
+}
+
+// MarkApplied moves the pending patches at the given indexes to AppliedPatches,
+// stamped with the time they were committed
+func (s *Session) MarkApplied(indexes []int, at time.Time) {
+	applied := make(map[int]bool, len(indexes))
+	for _, i := range indexes {
+		applied[i] = true
+	}
+
+	var pending []Patch
+	for i, p := range s.PendingPatches {
+		if applied[i] {
+			p.AppliedAt = at
+			s.AppliedPatches = append(s.AppliedPatches, p)
+		} else {
+			pending = append(pending, p)
+		}
+	}
+	s.PendingPatches = pending
 }
 
 // ToolCall records a tool invocation and its result
@@ -0,0 +1,355 @@
+package tools
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/yourusername/playground/internal/llm"
+	"github.com/yourusername/playground/internal/patch"
+	"github.com/yourusername/playground/internal/session"
+)
+
+// Builtin returns a registry with every tool the agent ships with
+func Builtin() *Registry {
+	return NewRegistry(
+		NewTool(llm.Tool{
+			Name:        "read_file",
+			Description: "Read a file from the repository as numbered lines; long output is truncated",
+			Parameters: schema([]string{"path"}, map[string]interface{}{
+				"path":       param("string", "Relative path to the file from repository root"),
+				"start_line": param("integer", "First line to read, starting at 1 (default: start of file)"),
+				"end_line":   param("integer", "Last line to read, inclusive (default: end of file)"),
+			}),
+		}, PermissionRead, func(args Args) string {
+			if _, ranged := args["start_line"]; ranged {
+				return "reading part of " + args.String("path")
+			}
+			return "reading " + args.String("path")
+		}, func(env Env, args Args) (string, error) {
+			return ReadFile(env.RepoRoot, args.String("path"), args.Int("start_line"), args.Int("end_line"))
+		}),
+
+		NewTool(llm.Tool{
+			Name:        "read_symbol",
+			Description: "Read one function, method or type declaration from a Go file",
+			Parameters: schema([]string{"path", "symbol"}, map[string]interface{}{
+				"path":   param("string", "Relative path to the Go file from repository root"),
+				"symbol": param("string", "Name such as 'NewServer', 'Config' or 'Server.Start' for a method"),
+			}),
+		}, PermissionRead, func(args Args) string {
+			return "reading " + args.String("symbol") + " in " + args.String("path")
+		}, func(env Env, args Args) (string, error) {
+			return ReadSymbol(env.RepoRoot, args.String("path"), args.String("symbol"))
+		}),
+
+		NewTool(llm.Tool{
+			Name:        "list_files",
+			Description: "List files and directories at the given path",
+			Parameters: schema([]string{"path"}, map[string]interface{}{
+				"path": param("string", "Relative path to directory from repository root (use '.' for root)"),
+			}),
+		}, PermissionRead, func(args Args) string {
+			return "listing " + args.String("path")
+		}, func(env Env, args Args) (string, error) {
+			files, err := ListFiles(env.RepoRoot, args.String("path"))
+			if err != nil {
+				return "", err
+			}
+			// Convert to JSON for LLM
+			data, _ := json.MarshalIndent(files, "", "  ")
+			return string(data), nil
+		}),
+
+		NewTool(llm.Tool{
+			Name:        "search_code",
+			Description: "Search file contents with a regular expression; returns file:line matches",
+			Parameters: schema([]string{"pattern"}, map[string]interface{}{
+				"pattern":      param("string", "Regular expression to search for (Go RE2 syntax)"),
+				"path":         param("string", "File or directory to search, relative to repository root (default: whole repository)"),
+				"include":      param("string", "Only search files matching this glob, e.g. '*.go' or 'internal/**/*_test.go'"),
+				"max_per_file": param("integer", "Maximum matches to show per file (default 5)"),
+				"ignore_case":  param("boolean", "Match case-insensitively"),
+			}),
+		}, PermissionRead, func(args Args) string {
+			return "searching for " + args.String("pattern")
+		}, func(env Env, args Args) (string, error) {
+			return SearchCode(env.RepoRoot, args.String("pattern"), SearchOptions{
+				Path:       args.String("path"),
+				Include:    args.String("include"),
+				MaxPerFile: args.Int("max_per_file"),
+				IgnoreCase: args.Bool("ignore_case"),
+			})
+		}),
+
+		NewTool(llm.Tool{
+			Name:        "find_files",
+			Description: "Find files whose paths match a glob pattern",
+			Parameters: schema([]string{"pattern"}, map[string]interface{}{
+				"pattern": param("string", "Glob such as '*_test.go' (matches names at any depth) or 'cmd/**/*.go' (matches from repository root)"),
+				"path":    param("string", "Directory to search, relative to repository root (default: whole repository)"),
+			}),
+		}, PermissionRead, func(args Args) string {
+			return "finding " + args.String("pattern")
+		}, func(env Env, args Args) (string, error) {
+			return FindFiles(env.RepoRoot, args.String("pattern"), args.String("path"))
+		}),
+
+		NewTool(llm.Tool{
+			Name:        "go_symbols",
+			Description: "List a Go package's exported declarations with their signatures",
+			Parameters: schema([]string{"package"}, map[string]interface{}{
+				"package": param("string", "Package directory relative to repository root (use '.' for root)"),
+			}),
+		}, PermissionRead, func(args Args) string {
+			return "listing the symbols of " + args.String("package")
+		}, func(env Env, args Args) (string, error) {
+			return GoSymbols(env.RepoRoot, args.String("package"))
+		}),
+
+		NewTool(llm.Tool{
+			Name:        "go_definition",
+			Description: "Find where a Go symbol is declared, with its signature and doc comment",
+			Parameters: schema([]string{"symbol"}, map[string]interface{}{
+				"symbol":  param("string", "Name such as 'NewServer', 'Server.Start' or 'config.Load'"),
+				"package": param("string", "Only look in this package directory (default: whole module)"),
+			}),
+		}, PermissionRead, func(args Args) string {
+			return "looking up " + args.String("symbol")
+		}, func(env Env, args Args) (string, error) {
+			return GoDefinition(env.RepoRoot, args.String("symbol"), args.String("package"))
+		}),
+
+		NewTool(llm.Tool{
+			Name:        "go_references",
+			Description: "List every place in the Go module, tests included, that uses a symbol; returns file:line matches",
+			Parameters: schema([]string{"symbol"}, map[string]interface{}{
+				"symbol":  param("string", "Name such as 'NewServer', 'Server.Start' or 'config.Load'"),
+				"package": param("string", "Package directory that declares the symbol, to disambiguate (default: whole module)"),
+			}),
+		}, PermissionRead, func(args Args) string {
+			return "finding references to " + args.String("symbol")
+		}, func(env Env, args Args) (string, error) {
+			return GoReferences(env.RepoRoot, args.String("symbol"), args.String("package"))
+		}),
+
+		NewTool(llm.Tool{
+			Name:        "git_status",
+			Description: "Get the current Git status of the repository",
+			Parameters:  schema(nil, map[string]interface{}{}),
+		}, PermissionRead, func(args Args) string {
+			return "checking git status"
+		}, func(env Env, args Args) (string, error) {
+			return GitStatus(env.RepoRoot)
+		}),
+
+		NewTool(llm.Tool{
+			Name:        "git_diff",
+			Description: "Get the current Git diff (uncommitted changes)",
+			Parameters:  schema(nil, map[string]interface{}{}),
+		}, PermissionRead, func(args Args) string {
+			return "reading the git diff"
+		}, func(env Env, args Args) (string, error) {
+			return GitDiff(env.RepoRoot)
+		}),
+
+		NewTool(llm.Tool{
+			Name:        "run_command",
+			Description: "Execute a shell command (requires user approval)",
+			Parameters: schema([]string{"command"}, map[string]interface{}{
+				"command": param("string", "The shell command to execute"),
+			}),
+		}, PermissionExecute, func(args Args) string {
+			return "running " + args.String("command")
+		}, func(env Env, args Args) (string, error) {
+			return runCommand(env.RepoRoot, args.String("command"))
+		}),
+
+		NewTool(llm.Tool{
+			Name:        "edit_file",
+			Description: "Change a file by replacing old_string with new_string, or by giving its complete new content; pg writes the diff for you",
+			Parameters: schema([]string{"path"}, map[string]interface{}{
+				"path":        param("string", "Relative path to the file from repository root"),
+				"old_string":  param("string", "Exact text to replace, including indentation; must appear once in the file"),
+				"new_string":  param("string", "Text to put in its place (empty to delete old_string)"),
+				"replace_all": param("boolean", "Replace every occurrence of old_string"),
+				"content":     param("string", "Complete new content of the file, instead of old_string/new_string; creates the file if needed"),
+			}),
+		}, PermissionPropose, func(args Args) string {
+			return "editing " + args.String("path")
+		}, editFile),
+
+		NewTool(llm.Tool{
+			Name:        "propose_patch",
+			Description: "Propose a code change as a unified diff patch. One diff may change several files; git headers (rename from/to, new file mode, deleted file mode, old/new mode) rename, create or delete files",
+			Parameters: schema([]string{"unified_diff"}, map[string]interface{}{
+				"file_path":    param("string", "Relative path of the file a single-file diff changes; leave out when the diff's headers name the files"),
+				"unified_diff": param("string", "Complete unified diff with --- and +++ headers, or diff --git headers"),
+			}),
+		}, PermissionPropose, func(args Args) string {
+			if args.String("file_path") == "" {
+				return "proposing a patch"
+			}
+			return "proposing a patch for " + args.String("file_path")
+		}, proposePatch),
+	)
+}
+
+// proposePatch queues a model-written diff. One that would not apply goes back
+// to the model with the mismatching lines, so only clean patches reach the user.
+func proposePatch(env Env, args Args) (string, error) {
+	filePath := args.String("file_path")
+	queued, report, err := queuePatch(env, filePath, args.String("unified_diff"))
+	if err != nil {
+		what := "patch"
+		if filePath != "" {
+			what = "patch for " + filePath
+		}
+		return "", fmt.Errorf("%s was not proposed: %w\n"+
+			"Regenerate the unified diff against the files' current content and call propose_patch again.", what, err)
+	}
+
+	result := fmt.Sprintf("Patch proposed for %s. User can review with 'pg review' and apply with 'pg apply'.", strings.Join(queued.Paths(), ", "))
+	if len(queued.Repairs) > 0 {
+		result += "\nThe diff was repaired before queueing:\n- " + strings.Join(queued.Repairs, "\n- ")
+	}
+	if report.Inexact() {
+		result += "\nSome hunks are not where their headers say; check the line numbers next time:\n" + strings.TrimSuffix(report.String(), "\n")
+	}
+	return result, nil
+}
+
+// editFile turns a search/replace edit or a whole new file body into a diff
+// and queues it, so the model never has to count hunk lines itself
+func editFile(env Env, args Args) (string, error) {
+	if env.Session == nil {
+		return "", fmt.Errorf("no session to add the patch to")
+	}
+
+	path := args.String("path")
+	_, hasContent := args["content"]
+	_, hasOld := args["old_string"]
+
+	var diff string
+	var err error
+	switch {
+	case hasContent && hasOld:
+		return "", fmt.Errorf("give either old_string and new_string, or content, not both")
+	case hasContent:
+		diff, err = RewriteFile(env.RepoRoot, path, args.String("content"))
+	case hasOld:
+		diff, err = EditFile(env.RepoRoot, path, args.String("old_string"), args.String("new_string"), args.Bool("replace_all"))
+	default:
+		return "", fmt.Errorf("give old_string and new_string to change part of %s, or content to rewrite it", path)
+	}
+	if err != nil {
+		env.Session.Stats.RecordRejectedPatch(path)
+		return "", err
+	}
+
+	if _, _, err := queuePatch(env, path, diff); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("Patch proposed for %s:\n%s\nUser can review with 'pg review' and apply with 'pg apply'.", path, diff), nil
+}
+
+// queuePatch lints a diff, dry-runs it and adds it to the session's pending
+// patches. filePath names the file of a single-file diff, or is empty when the
+// diff's headers name its files. Every context line must match, so fuzz never
+// hides a wrong diff; the report says which hunks were found at an offset.
+// Rejections are counted per file the diff names, or under filePath when the
+// diff cannot be read, and the next accepted patch for one of those files
+// records them as retries.
+func queuePatch(env Env, filePath, diff string) (session.Patch, *patch.Report, error) {
+	if env.Session == nil {
+		return session.Patch{}, nil, fmt.Errorf("no session to add the patch to")
+	}
+	if filePath != "" {
+		if _, err := resolvePath(env.RepoRoot, filePath); err != nil {
+			return session.Patch{}, nil, err
+		}
+	}
+
+	diffs, repairs, err := patch.Lint(diff, filePath)
+	if err != nil {
+		var keys []string
+		if filePath != "" {
+			keys = []string{filePath}
+		}
+		env.Session.Stats.RecordRejectedPatch(keys...)
+		return session.Patch{}, nil, err
+	}
+
+	files, err := diffFiles(env.RepoRoot, diffs)
+	var report *patch.Report
+	if err == nil {
+		diff = patch.Format(diffs)
+		strict := patch.DefaultOptions
+		strict.Fuzz = 0
+		report, err = patch.DryRun(env.RepoRoot, patch.Patch{FilePath: filePath, UnifiedDiff: diff}, strict)
+	}
+	if err != nil {
+		env.Session.Stats.RecordRejectedPatch(diffNames(diffs)...)
+		return session.Patch{}, nil, err
+	}
+
+	queued := session.Patch{
+		FilePath:    files[0],
+		UnifiedDiff: diff,
+		Retries:     env.Session.Stats.TakePatchRetries(files...),
+		Repairs:     repairs,
+		CreatedAt:   time.Now(),
+	}
+	if len(files) > 1 {
+		queued.Files = files
+	}
+	env.Session.PendingPatches = append(env.Session.PendingPatches, queued)
+	return queued, report, nil
+}
+
+// diffFiles lists the files a diff touches, both sides of a rename included,
+// and checks that each is inside the repository
+func diffFiles(repoRoot string, diffs []*patch.FileDiff) ([]string, error) {
+	files := diffNames(diffs)
+	for _, name := range files {
+		if _, err := resolvePath(repoRoot, name); err != nil {
+			return nil, err
+		}
+	}
+	return files, nil
+}
+
+// diffNames lists the files a diff names, both sides of a rename included
+func diffNames(diffs []*patch.FileDiff) []string {
+	var files []string
+	for _, d := range diffs {
+		for _, name := range []string{d.OldName(), d.NewName()} {
+			if name != "" && !slices.Contains(files, name) {
+				files = append(files, name)
+			}
+		}
+	}
+	return files
+}
+
+// schema builds the JSON schema of a tool's arguments object
+func schema(required []string, properties map[string]interface{}) map[string]interface{} {
+	s := map[string]interface{}{
+		"type":       "object",
+		"properties": properties,
+	}
+	if len(required) > 0 {
+		s["required"] = required
+	}
+	return s
+}
+
+// param declares one argument in a schema
+func param(typ, description string) map[string]interface{} {
+	return map[string]interface{}{
+		"type":        typ,
+		"description": description,
+	}
+}
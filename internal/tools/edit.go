@@ -0,0 +1,130 @@
+package tools
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/yourusername/playground/internal/patch"
+)
+
+// EditFile returns the unified diff that replaces oldString with newString in a
+// file. oldString must match exactly once unless replaceAll is set, so the
+// edit cannot land somewhere the model did not mean.
+func EditFile(repoRoot, relPath, oldString, newString string, replaceAll bool) (string, error) {
+	if oldString == "" {
+		return "", fmt.Errorf("old_string is empty; give the exact text to replace, or content to rewrite the whole file")
+	}
+	if oldString == newString {
+		return "", fmt.Errorf("old_string and new_string are the same; nothing to change")
+	}
+
+	current, err := readEditTarget(repoRoot, relPath)
+	if err != nil {
+		return "", err
+	}
+
+	count := strings.Count(current, oldString)
+	switch {
+	case count == 0:
+		return "", fmt.Errorf("old_string not found in %s; %s", relPath, nearMatchHint(current, oldString))
+	case count > 1 && !replaceAll:
+		return "", fmt.Errorf("old_string appears %d times in %s (lines %s); include more surrounding lines to make it unique, or set replace_all",
+			count, relPath, matchLines(current, oldString))
+	}
+
+	var updated string
+	if replaceAll {
+		updated = strings.ReplaceAll(current, oldString, newString)
+	} else {
+		updated = strings.Replace(current, oldString, newString, 1)
+	}
+	return patch.Diff(relPath, current, updated), nil
+}
+
+// RewriteFile returns the unified diff that gives a file the new content,
+// creating the file if it does not exist
+func RewriteFile(repoRoot, relPath, content string) (string, error) {
+	fullPath, err := resolvePath(repoRoot, relPath)
+	if err != nil {
+		return "", err
+	}
+
+	if _, err := os.Stat(fullPath); os.IsNotExist(err) {
+		if content == "" {
+			return "", fmt.Errorf("content is empty; nothing to create")
+		}
+		return patch.NewFileDiff(relPath, content), nil
+	}
+
+	current, err := readEditTarget(repoRoot, relPath)
+	if err != nil {
+		return "", err
+	}
+	if current == content {
+		return "", fmt.Errorf("content is the same as the current %s; nothing to change", relPath)
+	}
+	return patch.Diff(relPath, current, content), nil
+}
+
+// readEditTarget reads the file an edit applies to
+func readEditTarget(repoRoot, relPath string) (string, error) {
+	fullPath, err := resolvePath(repoRoot, relPath)
+	if err != nil {
+		return "", err
+	}
+
+	data, err := os.ReadFile(fullPath)
+	if os.IsNotExist(err) {
+		return "", fmt.Errorf("%s does not exist; to create it, give its content instead of old_string", relPath)
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to read file: %w", err)
+	}
+	return string(data), nil
+}
+
+// nearMatchHint explains a failed match: often only indentation or trailing
+// spaces differ, and saying where saves the model a guess
+func nearMatchHint(content, oldString string) string {
+	want := trimLines(strings.Split(strings.TrimRight(oldString, "\n"), "\n"))
+	lines := strings.Split(content, "\n")
+	trimmed := trimLines(lines)
+
+	for i := 0; i+len(want) <= len(trimmed); i++ {
+		match := true
+		for j := range want {
+			if trimmed[i+j] != want[j] {
+				match = false
+				break
+			}
+		}
+		if match {
+			return fmt.Sprintf("lines %d-%d match except for whitespace; copy them exactly, including indentation", i+1, i+len(want))
+		}
+	}
+	return "read the file and copy the text to replace exactly"
+}
+
+func trimLines(lines []string) []string {
+	out := make([]string, len(lines))
+	for i, line := range lines {
+		out[i] = strings.TrimSpace(line)
+	}
+	return out
+}
+
+// matchLines lists the 1-based lines where each occurrence of s starts, e.g. "3, 17"
+func matchLines(content, s string) string {
+	var lines []string
+	offset := 0
+	for {
+		i := strings.Index(content[offset:], s)
+		if i < 0 {
+			break
+		}
+		lines = append(lines, fmt.Sprint(strings.Count(content[:offset+i], "\n")+1))
+		offset += i + len(s)
+	}
+	return strings.Join(lines, ", ")
+}
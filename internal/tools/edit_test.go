@@ -0,0 +1,93 @@
+package tools
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/yourusername/playground/internal/llm"
+	"github.com/yourusername/playground/internal/session"
+)
+
+func TestEditFile(t *testing.T) {
+	const mainGo = "package main\n\nfunc main() {\n\tprintln(\"hello\")\n\tprintln(\"hello\")\n}\n"
+
+	tests := []struct {
+		name     string
+		args     map[string]interface{}
+		wantDiff string // Substring of the queued diff
+		wantErr  string
+	}{
+		{
+			name:     "replace once",
+			args:     map[string]interface{}{"old_string": "func main() {\n\tprintln(\"hello\")", "new_string": "func main() {\n\tprintln(\"hi\")"},
+			wantDiff: "@@ -1,6 +1,6 @@\n package main\n \n func main() {\n-\tprintln(\"hello\")\n+\tprintln(\"hi\")\n \tprintln(\"hello\")\n }\n",
+		},
+		{
+			name:     "replace all",
+			args:     map[string]interface{}{"old_string": "hello", "new_string": "bye", "replace_all": true},
+			wantDiff: "-\tprintln(\"hello\")\n-\tprintln(\"hello\")\n+\tprintln(\"bye\")\n+\tprintln(\"bye\")\n",
+		},
+		{
+			name:    "ambiguous",
+			args:    map[string]interface{}{"old_string": "println(\"hello\")", "new_string": "println(\"hi\")"},
+			wantErr: "old_string appears 2 times in main.go (lines 4, 5)",
+		},
+		{
+			name:    "whitespace differs",
+			args:    map[string]interface{}{"old_string": "func main() {\n  println(\"hello\")", "new_string": "x"},
+			wantErr: "lines 3-4 match except for whitespace",
+		},
+		{
+			name:    "not found",
+			args:    map[string]interface{}{"old_string": "func helper()", "new_string": "x"},
+			wantErr: "old_string not found in main.go; read the file",
+		},
+		{
+			name:     "new file from content",
+			args:     map[string]interface{}{"path": "util.go", "content": "package main\n"},
+			wantDiff: "--- /dev/null\n+++ b/util.go\n@@ -0,0 +1 @@\n+package main\n",
+		},
+		{
+			name:     "rewrite from content",
+			args:     map[string]interface{}{"content": "package main\n\nfunc main() {}\n"},
+			wantDiff: "-func main() {\n-\tprintln(\"hello\")\n-\tprintln(\"hello\")\n-}\n+func main() {}\n",
+		},
+		{
+			name:    "both forms",
+			args:    map[string]interface{}{"old_string": "a", "new_string": "b", "content": "c"},
+			wantErr: "not both",
+		},
+		{
+			name:    "outside the repository",
+			args:    map[string]interface{}{"path": "../main.go", "content": "x"},
+			wantErr: "outside",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := writeRepo(t, map[string]string{"main.go": mainGo})
+			sess := &session.Session{}
+			if _, ok := tt.args["path"]; !ok {
+				tt.args["path"] = "main.go"
+			}
+
+			_, err := Builtin().Execute(Env{RepoRoot: root, Session: sess}, llm.ToolCall{Name: "edit_file", Arguments: tt.args})
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("expected error containing %q, got %v", tt.wantErr, err)
+				}
+				if len(sess.PendingPatches) != 0 {
+					t.Errorf("failed edit was queued: %+v", sess.PendingPatches)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(sess.PendingPatches) != 1 || !strings.Contains(sess.PendingPatches[0].UnifiedDiff, tt.wantDiff) {
+				t.Errorf("expected a queued diff containing:\n%s\ngot %+v", tt.wantDiff, sess.PendingPatches)
+			}
+		})
+	}
+}
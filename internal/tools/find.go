@@ -0,0 +1,56 @@
+package tools
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+const maxFindResults = 100
+
+// FindFiles lists the files under relPath whose paths match a glob pattern
+// A pattern without a slash ("*_test.go") matches file names at any depth;
+// one with a slash ("internal/**/*.go") matches the path from the repo root.
+// .gitignore'd and hidden files are skipped.
+func FindFiles(repoRoot, pattern, relPath string) (string, error) {
+	if pattern == "" {
+		return "", fmt.Errorf("empty glob pattern")
+	}
+	re, err := globRegexp(pattern)
+	if err != nil {
+		return "", fmt.Errorf("invalid glob pattern: %w", err)
+	}
+
+	start, err := resolvePath(repoRoot, relPath)
+	if err != nil {
+		return "", err
+	}
+	if _, err := os.Stat(start); err != nil {
+		return "", fmt.Errorf("path not found: %s", relPath)
+	}
+
+	var found []string
+	total := 0
+	err = walkRepo(repoRoot, start, func(rel, absPath string) error {
+		if re.MatchString(rel) {
+			total++
+			if len(found) < maxFindResults {
+				found = append(found, rel)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to find files: %w", err)
+	}
+
+	if total == 0 {
+		return fmt.Sprintf("No files match %q", pattern), nil
+	}
+
+	result := strings.Join(found, "\n") + "\n"
+	if total > len(found) {
+		result += fmt.Sprintf("... and %d more\n", total-len(found))
+	}
+	return result, nil
+}
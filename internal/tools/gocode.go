@@ -0,0 +1,417 @@
+package tools
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"os"
+	"sort"
+	"strings"
+)
+
+const maxReferences = 100
+
+// GoSymbols lists the exported declarations of the Go package in relPath with their signatures
+// Struct fields, interface methods and methods are listed under their type.
+func GoSymbols(repoRoot, relPath string) (string, error) {
+	if _, err := resolvePath(repoRoot, relPath); err != nil {
+		return "", err
+	}
+
+	m, err := loadGoModule(repoRoot)
+	if err != nil {
+		return "", err
+	}
+	pkg, err := m.packageInDir(relPath)
+	if err != nil {
+		return "", err
+	}
+
+	qual := packageQualifier(pkg.types)
+	scope := pkg.types.Scope()
+
+	var objects []types.Object
+	for _, name := range scope.Names() {
+		if obj := scope.Lookup(name); obj.Exported() {
+			objects = append(objects, obj)
+		}
+	}
+	// Source order reads like the package itself
+	sort.Slice(objects, func(i, j int) bool { return objects[i].Pos() < objects[j].Pos() })
+
+	var out strings.Builder
+	fmt.Fprintf(&out, "package %s (%s)\n", pkg.types.Name(), pkg.path)
+	if len(objects) == 0 {
+		out.WriteString("No exported symbols\n")
+	}
+
+	for _, obj := range objects {
+		fmt.Fprintf(&out, "%s: %s\n", m.position(obj.Pos()), objectSignature(obj, qual))
+
+		tn, ok := obj.(*types.TypeName)
+		if !ok || tn.IsAlias() {
+			continue
+		}
+		for _, member := range typeMembers(tn, qual) {
+			fmt.Fprintf(&out, "  %s\n", member)
+		}
+		if named, ok := tn.Type().(*types.Named); ok {
+			for i := 0; i < named.NumMethods(); i++ {
+				if method := named.Method(i); method.Exported() {
+					fmt.Fprintf(&out, "  %s: %s\n", m.position(method.Pos()), types.ObjectString(method, qual))
+				}
+			}
+		}
+	}
+
+	return out.String(), nil
+}
+
+// GoDefinition finds where a symbol is declared in the module, with its signature and doc comment
+// symbol is "Name", "Type.Member" or "package.Name"; relPath optionally limits the search to one package.
+func GoDefinition(repoRoot, symbol, relPath string) (string, error) {
+	m, targets, err := resolveGoSymbol(repoRoot, symbol, relPath)
+	if err != nil {
+		return "", err
+	}
+
+	var out strings.Builder
+	for _, target := range targets {
+		qual := packageQualifier(target.pkg.types)
+		fmt.Fprintf(&out, "%s: %s\n", m.position(target.obj.Pos()), objectSignature(target.obj, qual))
+		if doc := declDoc(target.pkg, target.obj.Pos()); doc != "" {
+			for _, line := range strings.Split(strings.TrimSpace(doc), "\n") {
+				fmt.Fprintf(&out, "  // %s\n", line)
+			}
+		}
+	}
+	return out.String(), nil
+}
+
+// GoReferences lists the places in the module, tests included, that use a
+// symbol, as "file:line: source"
+func GoReferences(repoRoot, symbol, relPath string) (string, error) {
+	m, targets, err := resolveGoSymbol(repoRoot, symbol, relPath)
+	if err != nil {
+		return "", err
+	}
+	if len(targets) > 1 {
+		return "", fmt.Errorf("symbol %q is ambiguous: %s", symbol, describeTargets(m, targets))
+	}
+	target := targets[0].obj
+
+	var infos []*types.Info
+	for _, pkg := range m.loadAll() {
+		infos = append(infos, pkg.info)
+	}
+	infos = append(infos, m.loadAllTests()...)
+
+	seen := make(map[string]bool)
+	var refs []token.Position
+	for _, info := range infos {
+		for ident, obj := range info.Uses {
+			if !sameObject(obj, target) {
+				continue
+			}
+			pos := m.fset.Position(ident.Pos())
+			key := fmt.Sprintf("%s:%d", pos.Filename, pos.Line)
+			if !seen[key] {
+				seen[key] = true
+				refs = append(refs, pos)
+			}
+		}
+	}
+
+	if len(refs) == 0 {
+		return fmt.Sprintf("No references to %s (declared at %s)\n", symbol, m.position(target.Pos())), nil
+	}
+
+	sort.Slice(refs, func(i, j int) bool {
+		if refs[i].Filename != refs[j].Filename {
+			return refs[i].Filename < refs[j].Filename
+		}
+		return refs[i].Line < refs[j].Line
+	})
+
+	var out strings.Builder
+	fmt.Fprintf(&out, "%d references to %s (declared at %s)\n", len(refs), symbol, m.position(target.Pos()))
+
+	sources := make(map[string][]string)
+	for i, ref := range refs {
+		if i == maxReferences {
+			fmt.Fprintf(&out, "... and %d more\n", len(refs)-maxReferences)
+			break
+		}
+		lines, ok := sources[ref.Filename]
+		if !ok {
+			content, _ := os.ReadFile(ref.Filename)
+			lines = splitLines(string(content))
+			sources[ref.Filename] = lines
+		}
+		text := ""
+		if ref.Line <= len(lines) {
+			text = truncateMatch(strings.TrimSpace(lines[ref.Line-1]))
+		}
+		fmt.Fprintf(&out, "%s: %s\n", m.location(ref), text)
+	}
+
+	return out.String(), nil
+}
+
+// goTarget is a declaration a symbol name resolved to
+type goTarget struct {
+	obj types.Object
+	pkg *goPackage
+}
+
+// resolveGoSymbol loads the module and finds the declarations a symbol name refers to
+// A bare name matches package-level declarations, then methods and fields;
+// "A.B" matches B in package A or member B of type A.
+func resolveGoSymbol(repoRoot, symbol, relPath string) (*goModule, []goTarget, error) {
+	name := normalizeSymbol(symbol)
+	if name == "" {
+		return nil, nil, fmt.Errorf("empty symbol")
+	}
+	parts := strings.Split(name, ".")
+	if len(parts) > 2 {
+		return nil, nil, fmt.Errorf("symbol %q should be Name, Type.Member or package.Name", symbol)
+	}
+
+	m, err := loadGoModule(repoRoot)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var pkgs []*goPackage
+	if relPath == "" {
+		pkgs = m.loadAll()
+	} else {
+		if _, err := resolvePath(repoRoot, relPath); err != nil {
+			return nil, nil, err
+		}
+		pkg, err := m.packageInDir(relPath)
+		if err != nil {
+			return nil, nil, err
+		}
+		pkgs = []*goPackage{pkg}
+	}
+
+	var targets []goTarget
+	if len(parts) == 1 {
+		for _, pkg := range pkgs {
+			if obj := pkg.types.Scope().Lookup(parts[0]); obj != nil {
+				targets = append(targets, goTarget{obj, pkg})
+			}
+		}
+		if len(targets) == 0 {
+			for _, pkg := range pkgs {
+				targets = append(targets, membersNamed(pkg, parts[0])...)
+			}
+		}
+	} else {
+		for _, pkg := range pkgs {
+			if pkg.types.Name() == parts[0] {
+				if obj := pkg.types.Scope().Lookup(parts[1]); obj != nil {
+					targets = append(targets, goTarget{obj, pkg})
+				}
+			}
+			if tn, ok := pkg.types.Scope().Lookup(parts[0]).(*types.TypeName); ok {
+				obj, _, _ := types.LookupFieldOrMethod(tn.Type(), true, pkg.types, parts[1])
+				if obj != nil {
+					targets = append(targets, goTarget{obj, pkg})
+				}
+			}
+		}
+	}
+
+	if len(targets) == 0 {
+		return nil, nil, fmt.Errorf("no declaration of %q in module %s", symbol, m.path)
+	}
+	return m, targets, nil
+}
+
+// membersNamed finds the methods and struct fields called name on the package's types
+func membersNamed(pkg *goPackage, name string) []goTarget {
+	var targets []goTarget
+	scope := pkg.types.Scope()
+	for _, typeName := range scope.Names() {
+		tn, ok := scope.Lookup(typeName).(*types.TypeName)
+		if !ok || tn.IsAlias() {
+			continue
+		}
+		if named, ok := tn.Type().(*types.Named); ok {
+			for i := 0; i < named.NumMethods(); i++ {
+				if method := named.Method(i); method.Name() == name {
+					targets = append(targets, goTarget{method, pkg})
+				}
+			}
+		}
+		if st, ok := tn.Type().Underlying().(*types.Struct); ok {
+			for i := 0; i < st.NumFields(); i++ {
+				if field := st.Field(i); field.Name() == name {
+					targets = append(targets, goTarget{field, pkg})
+				}
+			}
+		}
+	}
+	return targets
+}
+
+// originObject maps an instantiated generic function or field back to its declaration
+func originObject(obj types.Object) types.Object {
+	switch obj := obj.(type) {
+	case *types.Func:
+		return obj.Origin()
+	case *types.Var:
+		return obj.Origin()
+	}
+	return obj
+}
+
+// sameObject reports whether obj is target, or target's declaration as
+// checked again together with its package's tests
+func sameObject(obj, target types.Object) bool {
+	obj = originObject(obj)
+	return obj == target || obj.Pos() == target.Pos() && obj.Name() == target.Name()
+}
+
+// describeTargets lists candidate declarations, e.g. for an ambiguous name
+func describeTargets(m *goModule, targets []goTarget) string {
+	names := make([]string, 0, len(targets))
+	for _, target := range targets {
+		names = append(names, fmt.Sprintf("%s (%s)", types.ObjectString(target.obj, nil), m.position(target.obj.Pos())))
+	}
+	return strings.Join(names, ", ")
+}
+
+// packageQualifier names other packages the way code refers to them, e.g.
+// "cart.Cart" rather than "example.com/shop/cart.Cart", and leaves pkg's own
+// names unqualified
+func packageQualifier(pkg *types.Package) types.Qualifier {
+	return func(p *types.Package) string {
+		if p == pkg {
+			return ""
+		}
+		return p.Name()
+	}
+}
+
+// objectSignature renders a declaration on one line; struct and interface bodies are left to typeMembers
+func objectSignature(obj types.Object, qual types.Qualifier) string {
+	if tn, ok := obj.(*types.TypeName); ok && !tn.IsAlias() {
+		switch tn.Type().Underlying().(type) {
+		case *types.Struct:
+			return "type " + tn.Name() + typeParams(tn, qual) + " struct"
+		case *types.Interface:
+			return "type " + tn.Name() + typeParams(tn, qual) + " interface"
+		}
+	}
+	if field, ok := obj.(*types.Var); ok && field.IsField() {
+		return "field " + field.Name() + " " + types.TypeString(field.Type(), qual)
+	}
+	return types.ObjectString(obj, qual)
+}
+
+// typeParams renders a generic type's parameter list, e.g. "[T any]"
+func typeParams(tn *types.TypeName, qual types.Qualifier) string {
+	named, ok := tn.Type().(*types.Named)
+	if !ok || named.TypeParams().Len() == 0 {
+		return ""
+	}
+
+	var params []string
+	for i := 0; i < named.TypeParams().Len(); i++ {
+		param := named.TypeParams().At(i)
+		params = append(params, param.Obj().Name()+" "+types.TypeString(param.Constraint(), qual))
+	}
+	return "[" + strings.Join(params, ", ") + "]"
+}
+
+// typeMembers lists the exported fields of a struct or the exported methods of an interface
+func typeMembers(tn *types.TypeName, qual types.Qualifier) []string {
+	var members []string
+
+	switch t := tn.Type().Underlying().(type) {
+	case *types.Struct:
+		for i := 0; i < t.NumFields(); i++ {
+			field := t.Field(i)
+			if !field.Exported() {
+				continue
+			}
+			if field.Embedded() {
+				members = append(members, types.TypeString(field.Type(), qual)+" (embedded)")
+			} else {
+				members = append(members, field.Name()+" "+types.TypeString(field.Type(), qual))
+			}
+		}
+	case *types.Interface:
+		for i := 0; i < t.NumMethods(); i++ {
+			method := t.Method(i)
+			if method.Exported() {
+				sig := types.TypeString(method.Type(), qual)
+				members = append(members, method.Name()+strings.TrimPrefix(sig, "func"))
+			}
+		}
+	}
+
+	return members
+}
+
+// declDoc returns the doc comment of the declaration whose name is at pos
+func declDoc(pkg *goPackage, pos token.Pos) string {
+	var doc *ast.CommentGroup
+
+	for _, file := range pkg.files {
+		if pos < file.Pos() || pos > file.End() {
+			continue
+		}
+		ast.Inspect(file, func(n ast.Node) bool {
+			if doc != nil {
+				return false
+			}
+			switch n := n.(type) {
+			case *ast.FuncDecl:
+				if n.Name.Pos() == pos {
+					doc = n.Doc
+				}
+			case *ast.GenDecl:
+				for _, spec := range n.Specs {
+					switch spec := spec.(type) {
+					case *ast.TypeSpec:
+						if spec.Name.Pos() == pos {
+							doc = firstDoc(spec.Doc, n.Doc)
+						}
+					case *ast.ValueSpec:
+						for _, name := range spec.Names {
+							if name.Pos() == pos {
+								doc = firstDoc(spec.Doc, n.Doc)
+							}
+						}
+					}
+				}
+			case *ast.Field:
+				for _, name := range n.Names {
+					if name.Pos() == pos {
+						doc = firstDoc(n.Doc, n.Comment)
+					}
+				}
+			}
+			return true
+		})
+	}
+
+	if doc == nil {
+		return ""
+	}
+	return doc.Text()
+}
+
+func firstDoc(docs ...*ast.CommentGroup) *ast.CommentGroup {
+	for _, doc := range docs {
+		if doc != nil {
+			return doc
+		}
+	}
+	return nil
+}
@@ -0,0 +1,198 @@
+package tools
+
+import (
+	"strings"
+	"testing"
+)
+
+func goRepo(t *testing.T) string {
+	return writeRepo(t, map[string]string{
+		"go.mod": "module example.com/shop\n\ngo 1.21\n",
+		"cart/cart.go": `package cart
+
+import "strings"
+
+// Cart holds items
+type Cart struct {
+	Items []string
+	owner string
+}
+
+// Store persists carts
+type Store interface {
+	Save(c *Cart) error
+}
+
+// MaxItems caps a cart
+const MaxItems = 10
+
+// New creates an empty cart
+func New() *Cart {
+	return &Cart{}
+}
+
+// Add appends an item
+func (c *Cart) Add(item string) {
+	c.Items = append(c.Items, strings.TrimSpace(item))
+}
+
+func (c *Cart) count() int { return len(c.Items) }
+`,
+		"main.go": `package main
+
+import "example.com/shop/cart"
+
+func main() {
+	c := cart.New()
+	c.Add("apple")
+	c.Add("pear")
+	_ = c.Items
+}
+
+func checkout(c *cart.Cart) error { return nil }
+`,
+		"cart/cart_test.go": `package cart
+
+import "testing"
+
+func TestAdd(t *testing.T) {
+	c := New()
+	c.Add(" apple ")
+	if c.count() != 1 {
+		t.Fail()
+	}
+}
+`,
+		"cart/example_test.go": `package cart_test
+
+import "example.com/shop/cart"
+
+func ExampleCart_Add() {
+	cart.New().Add("pear")
+}
+`,
+		"testdata/broken.go": "package broken\n\nfunc Add() {}\n",
+	})
+}
+
+func TestGoSymbols(t *testing.T) {
+	root := goRepo(t)
+
+	got, err := GoSymbols(root, "cart")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := `package cart (example.com/shop/cart)
+cart/cart.go:6: type Cart struct
+  Items []string
+  cart/cart.go:25: func (*Cart).Add(item string)
+cart/cart.go:12: type Store interface
+  Save(c *Cart) error
+cart/cart.go:17: const MaxItems untyped int
+cart/cart.go:20: func New() *Cart
+`
+	if got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+
+	if _, err := GoSymbols(root, "nope"); err == nil {
+		t.Error("expected an error for a directory without a package")
+	}
+	if _, err := GoSymbols(root, ".."); err == nil {
+		t.Error("expected an error for a path outside the repository")
+	}
+}
+
+func TestGoDefinition(t *testing.T) {
+	root := goRepo(t)
+
+	tests := []struct {
+		symbol  string
+		want    string
+		wantErr string
+	}{
+		{
+			symbol: "Cart.Add",
+			want:   "cart/cart.go:25: func (*Cart).Add(item string)\n  // Add appends an item\n",
+		},
+		{
+			symbol: "cart.New",
+			want:   "cart/cart.go:20: func New() *Cart\n  // New creates an empty cart\n",
+		},
+		{
+			symbol: "Items",
+			want:   "cart/cart.go:7: field Items []string\n",
+		},
+		{
+			symbol: "(*Cart).count",
+			want:   "cart/cart.go:29: func (*Cart).count() int\n",
+		},
+		{
+			symbol: "checkout",
+			want:   "main.go:12: func checkout(c *cart.Cart) error\n",
+		},
+		{symbol: "Remove", wantErr: `no declaration of "Remove"`},
+		{symbol: "a.b.c", wantErr: "should be Name"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.symbol, func(t *testing.T) {
+			got, err := GoDefinition(root, tt.symbol, "")
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("expected error containing %q, got %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("got:\n%s\nwant:\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGoReferences(t *testing.T) {
+	root := goRepo(t)
+
+	got, err := GoReferences(root, "Cart.Add", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `4 references to Cart.Add (declared at cart/cart.go:25)
+cart/cart_test.go:7: c.Add(" apple ")
+cart/example_test.go:6: cart.New().Add("pear")
+main.go:7: c.Add("apple")
+main.go:8: c.Add("pear")
+`
+	if got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+
+	got, err = GoReferences(root, "Items", "cart")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(got, "3 references to Items") || !strings.Contains(got, "main.go:9: _ = c.Items") {
+		t.Errorf("unexpected references to Items:\n%s", got)
+	}
+
+	got, err = GoReferences(root, "(*Cart).count", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(got, "1 references to (*Cart).count") || !strings.Contains(got, "cart/cart_test.go:8: if c.count() != 1 {") {
+		t.Errorf("unexpected references to count:\n%s", got)
+	}
+
+	got, err = GoReferences(root, "MaxItems", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != "No references to MaxItems (declared at cart/cart.go:17)\n" {
+		t.Errorf("unexpected result: %q", got)
+	}
+}
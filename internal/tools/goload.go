@@ -0,0 +1,299 @@
+package tools
+
+import (
+	"bufio"
+	"fmt"
+	"go/ast"
+	"go/build"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// goModule type-checks the packages of the Go module at the repository root from source
+// Packages outside the module (the standard library, dependencies) come from the Go
+// toolchain's export data. Checking is best effort: type errors and missing
+// dependencies leave gaps in the results rather than failing the tool.
+type goModule struct {
+	root     string            // Absolute repository root
+	path     string            // Module path from go.mod
+	dirs     map[string]string // Import path -> package directory, slash-separated and relative to root
+	tests    map[string]string // Import path -> directory, for packages with _test.go files
+	pkgs     map[string]*goPackage
+	fset     *token.FileSet
+	fallback types.Importer
+}
+
+// goPackage is one type-checked package of the module
+type goPackage struct {
+	path    string
+	dir     string
+	files   []*ast.File
+	types   *types.Package
+	info    *types.Info
+	loading bool
+}
+
+// loadGoModule finds the module's packages; each is parsed and checked the first time it is needed
+func loadGoModule(repoRoot string) (*goModule, error) {
+	root, err := filepath.Abs(repoRoot)
+	if err != nil {
+		return nil, fmt.Errorf("invalid repo path: %w", err)
+	}
+
+	modPath, err := readModulePath(filepath.Join(root, "go.mod"))
+	if err != nil {
+		return nil, err
+	}
+
+	m := &goModule{
+		root:     root,
+		path:     modPath,
+		dirs:     make(map[string]string),
+		tests:    make(map[string]string),
+		pkgs:     make(map[string]*goPackage),
+		fset:     token.NewFileSet(),
+		fallback: importer.Default(),
+	}
+
+	err = walkRepo(root, root, func(rel, absPath string) error {
+		name := path.Base(rel)
+		if !strings.HasSuffix(name, ".go") || isTestdata(rel) {
+			return nil
+		}
+		dir := path.Dir(rel)
+		importPath := modPath
+		if dir != "." {
+			importPath += "/" + dir
+		}
+		if isGoSource(name) {
+			m.dirs[importPath] = dir
+		} else {
+			m.tests[importPath] = dir
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan packages: %w", err)
+	}
+
+	return m, nil
+}
+
+// readModulePath returns the module path declared in a go.mod file
+func readModulePath(goMod string) (string, error) {
+	file, err := os.Open(goMod)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "", fmt.Errorf("no go.mod at the repository root")
+		}
+		return "", fmt.Errorf("failed to read go.mod: %w", err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) >= 2 && fields[0] == "module" {
+			if unquoted, err := strconv.Unquote(fields[1]); err == nil {
+				return unquoted, nil
+			}
+			return fields[1], nil
+		}
+	}
+	return "", fmt.Errorf("go.mod has no module directive")
+}
+
+// isGoSource reports whether a file name is a non-test Go source file
+func isGoSource(name string) bool {
+	return strings.HasSuffix(name, ".go") && !strings.HasSuffix(name, "_test.go")
+}
+
+func isTestdata(rel string) bool {
+	return rel == "testdata" || strings.HasPrefix(rel, "testdata/") || strings.Contains(rel, "/testdata/")
+}
+
+// Import implements types.Importer
+func (m *goModule) Import(importPath string) (*types.Package, error) {
+	if _, ok := m.dirs[importPath]; !ok {
+		return m.fallback.Import(importPath)
+	}
+
+	pkg, err := m.load(importPath)
+	if err != nil {
+		return nil, err
+	}
+	return pkg.types, nil
+}
+
+// load parses and type-checks a module package, once
+func (m *goModule) load(importPath string) (*goPackage, error) {
+	if pkg, ok := m.pkgs[importPath]; ok {
+		if pkg.loading {
+			return nil, fmt.Errorf("import cycle through %s", importPath)
+		}
+		return pkg, nil
+	}
+
+	dir, ok := m.dirs[importPath]
+	if !ok {
+		return nil, fmt.Errorf("package %s is not in the module", importPath)
+	}
+
+	pkg := &goPackage{path: importPath, dir: dir, loading: true}
+	m.pkgs[importPath] = pkg
+	defer func() { pkg.loading = false }()
+
+	files, err := m.parseDir(dir, isGoSource)
+	if err != nil {
+		return nil, err
+	}
+	pkg.files = files
+	pkg.types, pkg.info = m.check(importPath, files)
+
+	return pkg, nil
+}
+
+// loadTests type-checks the test files of a module package and returns what
+// they use. In-package tests are checked together with the package's own
+// files, so its declarations keep their positions; an external _test package
+// imports the package as it is. Like load, this is best effort.
+func (m *goModule) loadTests(importPath string) []*types.Info {
+	files, err := m.parseDir(m.tests[importPath], func(name string) bool {
+		return strings.HasSuffix(name, "_test.go")
+	})
+	if err != nil {
+		return nil
+	}
+
+	var internal, external []*ast.File
+	for _, file := range files {
+		if strings.HasSuffix(file.Name.Name, "_test") {
+			external = append(external, file)
+		} else {
+			internal = append(internal, file)
+		}
+	}
+
+	var infos []*types.Info
+	if len(internal) > 0 {
+		var own []*ast.File
+		if _, ok := m.dirs[importPath]; ok {
+			if pkg, err := m.load(importPath); err == nil {
+				own = pkg.files
+			}
+		}
+		_, info := m.check(importPath, append(slices.Clone(own), internal...))
+		infos = append(infos, info)
+	}
+	if len(external) > 0 {
+		_, info := m.check(importPath+"_test", external)
+		infos = append(infos, info)
+	}
+	return infos
+}
+
+// parseDir parses the files in a package directory whose names keep accepts
+// and that build on the current platform
+func (m *goModule) parseDir(dir string, keep func(name string) bool) ([]*ast.File, error) {
+	absDir := filepath.Join(m.root, filepath.FromSlash(dir))
+	entries, err := os.ReadDir(absDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read package %s: %w", dir, err)
+	}
+
+	var files []*ast.File
+	for _, entry := range entries {
+		if entry.IsDir() || !keep(entry.Name()) {
+			continue
+		}
+		// Honour build constraints for the current platform
+		if match, err := build.Default.MatchFile(absDir, entry.Name()); err != nil || !match {
+			continue
+		}
+		file, err := parser.ParseFile(m.fset, filepath.Join(absDir, entry.Name()), nil, parser.ParseComments)
+		if err != nil && file == nil {
+			continue
+		}
+		files = append(files, file)
+	}
+	return files, nil
+}
+
+// check type-checks files as the package importPath, keeping going past type errors
+func (m *goModule) check(importPath string, files []*ast.File) (*types.Package, *types.Info) {
+	info := &types.Info{
+		Defs: make(map[*ast.Ident]types.Object),
+		Uses: make(map[*ast.Ident]types.Object),
+	}
+	conf := types.Config{
+		Importer: m,
+		Error:    func(error) {}, // Keep going past type errors
+	}
+	pkg, _ := conf.Check(importPath, m.fset, files, info)
+	return pkg, info
+}
+
+// packageInDir loads the package in a directory relative to the repository root
+func (m *goModule) packageInDir(dir string) (*goPackage, error) {
+	dir = path.Clean(filepath.ToSlash(dir))
+	for importPath, pkgDir := range m.dirs {
+		if pkgDir == dir {
+			return m.load(importPath)
+		}
+	}
+	return nil, fmt.Errorf("no Go package in %s", dir)
+}
+
+// loadAll loads every package in the module, ordered by import path
+func (m *goModule) loadAll() []*goPackage {
+	paths := make([]string, 0, len(m.dirs))
+	for importPath := range m.dirs {
+		paths = append(paths, importPath)
+	}
+	sort.Strings(paths)
+
+	var pkgs []*goPackage
+	for _, importPath := range paths {
+		if pkg, err := m.load(importPath); err == nil {
+			pkgs = append(pkgs, pkg)
+		}
+	}
+	return pkgs
+}
+
+// loadAllTests type-checks the test files of every package, ordered by import path
+func (m *goModule) loadAllTests() []*types.Info {
+	paths := make([]string, 0, len(m.tests))
+	for importPath := range m.tests {
+		paths = append(paths, importPath)
+	}
+	sort.Strings(paths)
+
+	var infos []*types.Info
+	for _, importPath := range paths {
+		infos = append(infos, m.loadTests(importPath)...)
+	}
+	return infos
+}
+
+// position returns "file:line" for a position, with the file relative to the repository root
+func (m *goModule) position(pos token.Pos) string {
+	return m.location(m.fset.Position(pos))
+}
+
+// location formats a resolved position as "file:line"
+func (m *goModule) location(p token.Position) string {
+	if rel, err := filepath.Rel(m.root, p.Filename); err == nil {
+		return fmt.Sprintf("%s:%d", filepath.ToSlash(rel), p.Line)
+	}
+	return fmt.Sprintf("%s:%d", p.Filename, p.Line)
+}
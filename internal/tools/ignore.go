@@ -0,0 +1,233 @@
+package tools
+
+import (
+	"bufio"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// ignoreRule is one pattern line from a .gitignore file
+type ignoreRule struct {
+	re      *regexp.Regexp // Matches paths relative to the .gitignore's directory
+	negate  bool           // "!pattern" re-includes a path
+	dirOnly bool           // "pattern/" only matches directories
+}
+
+// gitignore holds the .gitignore rules seen so far, keyed by the directory
+// they were read from (slash-separated, relative to the repo root, "." for the root)
+type gitignore struct {
+	root  string
+	rules map[string][]ignoreRule
+}
+
+func newGitignore(root string) *gitignore {
+	return &gitignore{root: root, rules: make(map[string][]ignoreRule)}
+}
+
+// load reads dir/.gitignore, if there is one
+func (g *gitignore) load(dir string) {
+	if _, seen := g.rules[dir]; seen {
+		return
+	}
+	g.rules[dir] = nil
+
+	file, err := os.Open(filepath.Join(g.root, filepath.FromSlash(dir), ".gitignore"))
+	if err != nil {
+		return
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if rule, ok := parseIgnoreRule(scanner.Text()); ok {
+			g.rules[dir] = append(g.rules[dir], rule)
+		}
+	}
+}
+
+// ignored reports whether rel (slash-separated, relative to the repo root) is ignored
+// Rules from deeper directories and later lines win, as in git
+func (g *gitignore) ignored(rel string, isDir bool) bool {
+	ignored := false
+
+	dir := "."
+	for {
+		sub := rel
+		if dir != "." {
+			sub = strings.TrimPrefix(rel, dir+"/")
+		}
+		for _, rule := range g.rules[dir] {
+			if rule.dirOnly && !isDir {
+				continue
+			}
+			if rule.re.MatchString(sub) {
+				ignored = !rule.negate
+			}
+		}
+
+		next := strings.IndexByte(sub, '/')
+		if next < 0 {
+			return ignored
+		}
+		if dir == "." {
+			dir = sub[:next]
+		} else {
+			dir = dir + "/" + sub[:next]
+		}
+	}
+}
+
+// parseIgnoreRule parses one .gitignore line; blank lines and comments yield no rule
+func parseIgnoreRule(line string) (ignoreRule, bool) {
+	line = strings.TrimRight(line, " \t\r")
+	if line == "" || strings.HasPrefix(line, "#") {
+		return ignoreRule{}, false
+	}
+
+	var rule ignoreRule
+	if strings.HasPrefix(line, "!") {
+		rule.negate = true
+		line = line[1:]
+	}
+	if strings.HasSuffix(line, "/") {
+		rule.dirOnly = true
+		line = strings.TrimRight(line, "/")
+	}
+	if line == "" {
+		return ignoreRule{}, false
+	}
+
+	// A pattern with a slash is anchored to its .gitignore's directory; otherwise it matches at any depth
+	anchored := strings.Contains(line, "/")
+	line = strings.TrimPrefix(line, "/")
+
+	expr := "^" + globExpr(line) + "$"
+	if !anchored {
+		expr = "^(?:.*/)?" + globExpr(line) + "$"
+	}
+
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return ignoreRule{}, false
+	}
+	rule.re = re
+	return rule, true
+}
+
+// globRegexp compiles a glob into a regexp over slash-separated paths
+// "*" and "?" stay within one path segment, "**" spans any number of them.
+// A pattern without a slash, like "*.go", matches the base name at any depth.
+func globRegexp(pattern string) (*regexp.Regexp, error) {
+	pattern = strings.TrimPrefix(filepath.ToSlash(pattern), "./")
+	if !strings.Contains(pattern, "/") {
+		return regexp.Compile("^(?:.*/)?" + globExpr(pattern) + "$")
+	}
+	return regexp.Compile("^" + globExpr(strings.TrimPrefix(pattern, "/")) + "$")
+}
+
+// globExpr translates glob syntax into regexp syntax
+func globExpr(pattern string) string {
+	var expr strings.Builder
+
+	for i := 0; i < len(pattern); i++ {
+		c := pattern[i]
+		switch {
+		case strings.HasPrefix(pattern[i:], "**/"):
+			expr.WriteString("(?:.*/)?")
+			i += 2
+		case strings.HasPrefix(pattern[i:], "**"):
+			expr.WriteString(".*")
+			i++
+		case c == '*':
+			expr.WriteString("[^/]*")
+		case c == '?':
+			expr.WriteString("[^/]")
+		case c == '\\' && i+1 < len(pattern):
+			i++
+			expr.WriteString(regexp.QuoteMeta(pattern[i : i+1]))
+		case c == '[':
+			end := strings.IndexByte(pattern[i+1:], ']')
+			if end < 0 {
+				expr.WriteString(`\[`)
+				continue
+			}
+			class := pattern[i+1 : i+1+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			expr.WriteString("[" + strings.ReplaceAll(class, `\`, `\\`) + "]")
+			i += end + 1
+		default:
+			expr.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+
+	return expr.String()
+}
+
+// walkRepo calls fn for every file under start, skipping hidden entries,
+// symlinks and anything excluded by a .gitignore. rel is slash-separated and
+// relative to the repo root. fn may return filepath.SkipAll to stop early.
+func walkRepo(repoRoot, start string, fn func(rel, absPath string) error) error {
+	root, err := filepath.Abs(repoRoot)
+	if err != nil {
+		return err
+	}
+
+	ignore := newGitignore(root)
+
+	// Rules from the directories above start still apply
+	startRel, err := filepath.Rel(root, start)
+	if err != nil {
+		return err
+	}
+	dir := "."
+	ignore.load(dir)
+	for _, part := range strings.Split(filepath.ToSlash(startRel), "/") {
+		if part == "." || part == "" {
+			continue
+		}
+		dir = path.Join(dir, part)
+		ignore.load(dir)
+	}
+
+	return filepath.WalkDir(start, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if d != nil && d.IsDir() && p != start {
+				return filepath.SkipDir // Unreadable directory; keep going
+			}
+			return err
+		}
+
+		rel, err := filepath.Rel(root, p)
+		if err != nil {
+			return nil
+		}
+		rel = filepath.ToSlash(rel)
+
+		if p != start {
+			skip := strings.HasPrefix(d.Name(), ".") ||
+				d.Type()&fs.ModeSymlink != 0 ||
+				ignore.ignored(rel, d.IsDir())
+			if skip {
+				if d.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+		}
+
+		if d.IsDir() {
+			ignore.load(rel)
+			return nil
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		return fn(rel, p)
+	})
+}
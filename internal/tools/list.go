@@ -3,7 +3,6 @@ package tools
 import (
 	"fmt"
 	"os"
-	"path/filepath"
 )
 
 // FileInfo represents basic file/directory information
@@ -16,24 +15,10 @@ type FileInfo struct {
 // ListFiles lists files and directories in the given path
 // Respects .gitignore rules by only showing Git-tracked structure
 func ListFiles(repoRoot, relPath string) ([]FileInfo, error) {
-	// Resolve to absolute path
-	absPath := filepath.Join(repoRoot, relPath)
-
 	// Security: Ensure path is within repository bounds
-	cleanPath, err := filepath.Abs(absPath)
-	if err != nil {
-		return nil, fmt.Errorf("invalid path: %w", err)
-	}
-
-	cleanRepo, err := filepath.Abs(repoRoot)
+	cleanPath, err := resolvePath(repoRoot, relPath)
 	if err != nil {
-		return nil, fmt.Errorf("invalid repo path: %w", err)
-	}
-
-	// Check if cleanPath is under cleanRepo
-	relativeToRepo, err := filepath.Rel(cleanRepo, cleanPath)
-	if err != nil || (len(relativeToRepo) > 0 && relativeToRepo[0:2] == "..") {
-		return nil, fmt.Errorf("path is outside repository bounds: %s", relPath)
+		return nil, err
 	}
 
 	// Read directory
@@ -0,0 +1,29 @@
+package tools
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// resolvePath turns a repository-relative path into an absolute one
+// Security: rejects paths that escape the repository
+func resolvePath(repoRoot, relPath string) (string, error) {
+	cleanPath, err := filepath.Abs(filepath.Join(repoRoot, relPath))
+	if err != nil {
+		return "", fmt.Errorf("invalid path: %w", err)
+	}
+
+	cleanRepo, err := filepath.Abs(repoRoot)
+	if err != nil {
+		return "", fmt.Errorf("invalid repo path: %w", err)
+	}
+
+	// Check if cleanPath is under cleanRepo
+	relativeToRepo, err := filepath.Rel(cleanRepo, cleanPath)
+	if err != nil || relativeToRepo == ".." || strings.HasPrefix(relativeToRepo, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("path is outside repository bounds: %s", relPath)
+	}
+
+	return cleanPath, nil
+}
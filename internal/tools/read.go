@@ -3,30 +3,53 @@ package tools
 import (
 	"fmt"
 	"os"
-	"path/filepath"
+	"strings"
 )
 
-// ReadFile reads a file's contents from the repository
-// Security: validates path is within repo bounds
-func ReadFile(repoRoot, relPath string) (string, error) {
-	// Resolve to absolute path
-	absPath := filepath.Join(repoRoot, relPath)
+// maxReadBytes caps what one read returns (~2k tokens), so a large file
+// cannot fill the context window by itself. Reads are cut at a line boundary.
+const maxReadBytes = 8000
 
-	// Security: Ensure path is within repository bounds
-	cleanPath, err := filepath.Abs(absPath)
+// ReadFile reads a file from the repository as numbered lines
+// startLine and endLine are 1-based and inclusive; 0 means the start or end of the file.
+// Security: validates path is within repo bounds
+func ReadFile(repoRoot, relPath string, startLine, endLine int) (string, error) {
+	content, err := readRepoFile(repoRoot, relPath)
 	if err != nil {
-		return "", fmt.Errorf("invalid path: %w", err)
+		return "", err
 	}
 
-	cleanRepo, err := filepath.Abs(repoRoot)
-	if err != nil {
-		return "", fmt.Errorf("invalid repo path: %w", err)
+	lines := splitLines(content)
+	total := len(lines)
+	if total == 0 {
+		return fmt.Sprintf("%s (empty file)\n", relPath), nil
 	}
 
-	// Check if cleanPath is under cleanRepo
-	relativeToRepo, err := filepath.Rel(cleanRepo, cleanPath)
-	if err != nil || len(relativeToRepo) > 0 && relativeToRepo[0:2] == ".." {
-		return "", fmt.Errorf("path is outside repository bounds: %s", relPath)
+	if startLine < 0 || endLine < 0 {
+		return "", fmt.Errorf("line numbers start at 1")
+	}
+	if startLine == 0 {
+		startLine = 1
+	}
+	if endLine == 0 || endLine > total {
+		endLine = total
+	}
+	if startLine > total {
+		return "", fmt.Errorf("start_line %d is past the end of %s (%d lines)", startLine, relPath, total)
+	}
+	if endLine < startLine {
+		return "", fmt.Errorf("end_line %d is before start_line %d", endLine, startLine)
+	}
+
+	return numberLines(relPath, lines, startLine, endLine), nil
+}
+
+// readRepoFile reads a file after checking it is inside the repository
+func readRepoFile(repoRoot, relPath string) (string, error) {
+	// Security: Ensure path is within repository bounds
+	cleanPath, err := resolvePath(repoRoot, relPath)
+	if err != nil {
+		return "", err
 	}
 
 	// Read file
@@ -40,3 +63,42 @@ func ReadFile(repoRoot, relPath string) (string, error) {
 
 	return string(content), nil
 }
+
+// splitLines splits file content into lines, without a trailing empty line
+func splitLines(content string) []string {
+	if content == "" {
+		return nil
+	}
+	lines := strings.Split(strings.TrimSuffix(content, "\n"), "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimSuffix(line, "\r")
+	}
+	return lines
+}
+
+// numberLines renders lines first..last (1-based) under a title, e.g.
+//
+//	main.go (lines 1-3 of 3)
+//	1  package main
+//
+// Output past maxReadBytes is dropped, with a note saying where to continue
+func numberLines(title string, lines []string, first, last int) string {
+	width := len(fmt.Sprint(last))
+
+	var body strings.Builder
+	shown := last
+	for n := first; n <= last; n++ {
+		line := fmt.Sprintf("%*d  %s\n", width, n, lines[n-1])
+		if body.Len()+len(line) > maxReadBytes && n > first {
+			shown = n - 1
+			break
+		}
+		body.WriteString(line)
+	}
+
+	out := fmt.Sprintf("%s (lines %d-%d of %d)\n%s", title, first, shown, len(lines), body.String())
+	if shown < last {
+		out += fmt.Sprintf("[truncated at line %d; read_file with start_line=%d to continue]\n", shown, shown+1)
+	}
+	return out
+}
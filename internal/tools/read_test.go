@@ -0,0 +1,163 @@
+package tools
+
+import (
+	"strings"
+	"testing"
+)
+
+const readTestSource = `package shop
+
+// Cart holds items
+type Cart struct {
+	Items []string
+}
+
+type (
+	// ID identifies a cart
+	ID string
+	Total int
+)
+
+// Add appends an item
+func (c *Cart) Add(item string) {
+	c.Items = append(c.Items, item)
+}
+
+func (l *List[T]) Add(item T) {}
+
+// NewCart creates an empty cart
+func NewCart() *Cart {
+	return &Cart{}
+}
+`
+
+func TestReadFile(t *testing.T) {
+	root := writeRepo(t, map[string]string{
+		"shop.go":   readTestSource,
+		"empty.txt": "",
+		"big.txt":   strings.Repeat(strings.Repeat("x", 99)+"\n", 200),
+	})
+
+	tests := []struct {
+		name       string
+		path       string
+		start, end int
+		want       string
+		wantErr    bool
+	}{
+		{
+			name:  "range",
+			path:  "shop.go",
+			start: 3, end: 4,
+			want: "shop.go (lines 3-4 of 24)\n3  // Cart holds items\n4  type Cart struct {\n",
+		},
+		{
+			name:  "open end is clamped",
+			path:  "shop.go",
+			start: 23, end: 100,
+			want: "shop.go (lines 23-24 of 24)\n23  \treturn &Cart{}\n24  }\n",
+		},
+		{
+			name: "empty file",
+			path: "empty.txt",
+			want: "empty.txt (empty file)\n",
+		},
+		{name: "start past end", path: "shop.go", start: 25, wantErr: true},
+		{name: "end before start", path: "shop.go", start: 5, end: 4, wantErr: true},
+		{name: "negative", path: "shop.go", start: -1, wantErr: true},
+		{name: "missing", path: "nope.go", wantErr: true},
+		{name: "outside repo", path: "../shop.go", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ReadFile(root, tt.path, tt.start, tt.end)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected error, got %q", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("got:\n%s\nwant:\n%s", got, tt.want)
+			}
+		})
+	}
+
+	t.Run("truncated", func(t *testing.T) {
+		got, err := ReadFile(root, "big.txt", 0, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(got) > maxReadBytes+200 {
+			t.Errorf("read returned %d bytes, want about %d", len(got), maxReadBytes)
+		}
+		if !strings.HasPrefix(got, "big.txt (lines 1-76 of 200)\n") {
+			t.Errorf("unexpected header: %q", strings.SplitN(got, "\n", 2)[0])
+		}
+		if !strings.HasSuffix(got, "[truncated at line 76; read_file with start_line=77 to continue]\n") {
+			t.Errorf("missing truncation note: %q", got[len(got)-80:])
+		}
+	})
+}
+
+func TestReadSymbol(t *testing.T) {
+	root := writeRepo(t, map[string]string{
+		"shop.go":   readTestSource,
+		"notes.txt": "hello\n",
+	})
+
+	tests := []struct {
+		symbol  string
+		want    string
+		wantErr string
+	}{
+		{
+			symbol: "NewCart",
+			want:   "shop.go: func NewCart (lines 21-24 of 24)\n21  // NewCart creates an empty cart\n22  func NewCart() *Cart {\n23  \treturn &Cart{}\n24  }\n",
+		},
+		{
+			symbol: "(*Cart).Add",
+			want:   "shop.go: method Cart.Add (lines 14-17 of 24)\n14  // Add appends an item\n15  func (c *Cart) Add(item string) {\n16  \tc.Items = append(c.Items, item)\n17  }\n",
+		},
+		{
+			symbol: "List.Add",
+			want:   "shop.go: method List.Add (lines 19-19 of 24)\n19  func (l *List[T]) Add(item T) {}\n",
+		},
+		{
+			symbol: "Cart",
+			want:   "shop.go: type Cart (lines 3-6 of 24)\n3  // Cart holds items\n4  type Cart struct {\n5  \tItems []string\n6  }\n",
+		},
+		{
+			symbol: "ID",
+			want:   "shop.go: type ID (lines 9-10 of 24)\n 9  \t// ID identifies a cart\n10  \tID string\n",
+		},
+		{symbol: "Add", wantErr: `ambiguous: Cart.Add, List.Add`},
+		{symbol: "Remove", wantErr: `not found; declared: Cart, Cart.Add, ID, List.Add, NewCart, Total`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.symbol, func(t *testing.T) {
+			got, err := ReadSymbol(root, "shop.go", tt.symbol)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("expected error containing %q, got %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("got:\n%s\nwant:\n%s", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := ReadSymbol(root, "notes.txt", "hello"); err == nil {
+		t.Error("expected an error for a non-Go file")
+	}
+}
@@ -0,0 +1,180 @@
+package tools
+
+import (
+	"fmt"
+
+	"github.com/yourusername/playground/internal/llm"
+	"github.com/yourusername/playground/internal/session"
+)
+
+// Permission classifies what a tool may do
+type Permission int
+
+const (
+	PermissionRead    Permission = iota // Only reads the repository
+	PermissionPropose                   // Records a change for the user to review; nothing is written
+	PermissionExecute                   // Runs something on the user's machine; needs approval per call
+)
+
+func (p Permission) String() string {
+	switch p {
+	case PermissionRead:
+		return "read"
+	case PermissionPropose:
+		return "propose"
+	case PermissionExecute:
+		return "execute"
+	default:
+		return fmt.Sprintf("permission(%d)", int(p))
+	}
+}
+
+// Env is what a tool call acts on
+type Env struct {
+	RepoRoot string
+	Session  *session.Session
+
+	// Approve asks the user whether a PermissionExecute call may run; nil denies every such call
+	Approve func(name string, args Args) bool
+}
+
+// Tool is a capability offered to the model
+type Tool interface {
+	Definition() llm.Tool // Name, description and JSON schema of the arguments
+	Permission() Permission
+	Describe(args Args) string              // Short progress line for a call, e.g. "reading main.go"
+	Run(env Env, args Args) (string, error) // Called only with arguments that passed the schema
+}
+
+// Handler runs a tool call
+type Handler func(env Env, args Args) (string, error)
+
+// Describer phrases a tool call for the user
+type Describer func(args Args) string
+
+// NewTool builds a Tool from its definition, permission class, describer and
+// handler. A nil describer describes every call as "calling <name>".
+func NewTool(def llm.Tool, perm Permission, describe Describer, handler Handler) Tool {
+	return &funcTool{def: def, perm: perm, describe: describe, handler: handler}
+}
+
+type funcTool struct {
+	def      llm.Tool
+	perm     Permission
+	describe Describer
+	handler  Handler
+}
+
+func (t *funcTool) Definition() llm.Tool                   { return t.def }
+func (t *funcTool) Permission() Permission                 { return t.perm }
+func (t *funcTool) Run(env Env, args Args) (string, error) { return t.handler(env, args) }
+
+func (t *funcTool) Describe(args Args) string {
+	if t.describe == nil {
+		return "calling " + t.def.Name
+	}
+	return t.describe(args)
+}
+
+// Args are a tool call's arguments as decoded from JSON
+type Args map[string]interface{}
+
+// String returns a string argument, or "" if it is absent
+func (a Args) String(key string) string {
+	value, _ := a[key].(string)
+	return value
+}
+
+// Int returns an integer argument, or 0 if it is absent
+func (a Args) Int(key string) int {
+	value, _ := a[key].(float64) // JSON numbers decode as float64
+	return int(value)
+}
+
+// Bool returns a boolean argument, or false if it is absent
+func (a Args) Bool(key string) bool {
+	value, _ := a[key].(bool)
+	return value
+}
+
+// Registry is the set of tools the agent offers, and the one place calls are dispatched from
+type Registry struct {
+	tools map[string]Tool
+	order []string // Registration order, so the tool list is stable across prompts
+}
+
+// NewRegistry creates a registry holding tools; it panics on duplicate names
+func NewRegistry(tools ...Tool) *Registry {
+	r := &Registry{tools: make(map[string]Tool)}
+	for _, tool := range tools {
+		if err := r.Register(tool); err != nil {
+			panic(err)
+		}
+	}
+	return r
+}
+
+// Register adds a tool
+func (r *Registry) Register(tool Tool) error {
+	name := tool.Definition().Name
+	if name == "" {
+		return fmt.Errorf("tool has no name")
+	}
+	if _, exists := r.tools[name]; exists {
+		return fmt.Errorf("tool %s is already registered", name)
+	}
+
+	r.tools[name] = tool
+	r.order = append(r.order, name)
+	return nil
+}
+
+// Lookup returns the tool with the given name
+func (r *Registry) Lookup(name string) (Tool, bool) {
+	tool, ok := r.tools[name]
+	return tool, ok
+}
+
+// Definitions returns the tool list sent to the model
+func (r *Registry) Definitions() []llm.Tool {
+	defs := make([]llm.Tool, 0, len(r.order))
+	for _, name := range r.order {
+		defs = append(defs, r.tools[name].Definition())
+	}
+	return defs
+}
+
+// Describe returns a short progress line for a call, e.g. "reading main.go"
+func (r *Registry) Describe(call llm.ToolCall) string {
+	tool, ok := r.tools[call.Name]
+	if !ok {
+		return "calling " + call.Name
+	}
+	return tool.Describe(Args(call.Arguments))
+}
+
+// Validate checks a call's arguments against the tool's schema; a mismatch is an *ArgumentError
+func (r *Registry) Validate(name string, args map[string]interface{}) error {
+	tool, ok := r.tools[name]
+	if !ok {
+		return fmt.Errorf("unknown tool: %s", name)
+	}
+	return ValidateArgs(tool.Definition(), args)
+}
+
+// Execute validates and runs a tool call, asking for approval first when the tool needs it
+func (r *Registry) Execute(env Env, call llm.ToolCall) (string, error) {
+	if err := r.Validate(call.Name, call.Arguments); err != nil {
+		return "", err
+	}
+	tool := r.tools[call.Name]
+	args := Args(call.Arguments)
+
+	if tool.Permission() == PermissionExecute {
+		if env.Approve == nil || !env.Approve(call.Name, args) {
+			return "", fmt.Errorf("%s rejected by user", call.Name)
+		}
+	}
+
+	return tool.Run(env, args)
+}
@@ -0,0 +1,325 @@
+package tools
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/yourusername/playground/internal/llm"
+	"github.com/yourusername/playground/internal/session"
+)
+
+func echoTool(perm Permission) Tool {
+	return NewTool(llm.Tool{
+		Name:        "echo",
+		Description: "Repeat the text",
+		Parameters: schema([]string{"text"}, map[string]interface{}{
+			"text":  param("string", "Text to repeat"),
+			"times": param("integer", "How often"),
+		}),
+	}, perm, func(args Args) string {
+		return "echoing " + args.String("text")
+	}, func(env Env, args Args) (string, error) {
+		return strings.Repeat(args.String("text"), max(args.Int("times"), 1)), nil
+	})
+}
+
+func TestRegistryExecute(t *testing.T) {
+	tests := []struct {
+		name    string
+		perm    Permission
+		approve func(string, Args) bool
+		args    map[string]interface{}
+		want    string
+		wantErr string
+	}{
+		{
+			name: "runs handler",
+			perm: PermissionRead,
+			args: map[string]interface{}{"text": "hi", "times": float64(2)},
+			want: "hihi",
+		},
+		{
+			name: "missing required",
+			perm: PermissionRead,
+			args: map[string]interface{}{"times": float64(2)},
+			wantErr: "invalid arguments for echo: \"text\" is required\n" +
+				"Expected arguments: {\"text\": string, \"times\"?: integer} (? marks optional)\n" +
+				"Fix the arguments and call echo again.",
+		},
+		{
+			name: "wrong type",
+			perm: PermissionRead,
+			args: map[string]interface{}{"text": "hi", "times": 1.5},
+			wantErr: "invalid arguments for echo: \"times\" must be an integer, got number 1.5\n" +
+				"Expected arguments: {\"text\": string, \"times\"?: integer} (? marks optional)\n" +
+				"Fix the arguments and call echo again.",
+		},
+		{
+			name:    "execute without approval",
+			perm:    PermissionExecute,
+			args:    map[string]interface{}{"text": "hi"},
+			wantErr: "echo rejected by user",
+		},
+		{
+			name:    "execute denied",
+			perm:    PermissionExecute,
+			approve: func(string, Args) bool { return false },
+			args:    map[string]interface{}{"text": "hi"},
+			wantErr: "echo rejected by user",
+		},
+		{
+			name:    "execute approved",
+			perm:    PermissionExecute,
+			approve: func(name string, args Args) bool { return name == "echo" && args.String("text") == "hi" },
+			args:    map[string]interface{}{"text": "hi"},
+			want:    "hi",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			registry := NewRegistry(echoTool(tt.perm))
+			got, err := registry.Execute(Env{Approve: tt.approve}, llm.ToolCall{Name: "echo", Arguments: tt.args})
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("expected error %q, got %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := NewRegistry().Execute(Env{}, llm.ToolCall{Name: "echo"}); err == nil || err.Error() != "unknown tool: echo" {
+		t.Errorf("expected unknown tool error, got %v", err)
+	}
+}
+
+func TestRegistryRegister(t *testing.T) {
+	registry := NewRegistry(echoTool(PermissionRead))
+	if err := registry.Register(echoTool(PermissionRead)); err == nil {
+		t.Error("expected an error registering a duplicate tool")
+	}
+
+	defs := registry.Definitions()
+	if len(defs) != 1 || defs[0].Name != "echo" {
+		t.Errorf("unexpected definitions: %+v", defs)
+	}
+}
+
+func TestRegistryDescribe(t *testing.T) {
+	registry := Builtin()
+	registry.Register(NewTool(llm.Tool{Name: "plain"}, PermissionRead, nil, nil))
+
+	tests := []struct {
+		call llm.ToolCall
+		want string
+	}{
+		{llm.ToolCall{Name: "read_file", Arguments: map[string]interface{}{"path": "main.go"}}, "reading main.go"},
+		{llm.ToolCall{Name: "read_file", Arguments: map[string]interface{}{"path": "main.go", "start_line": float64(10)}}, "reading part of main.go"},
+		{llm.ToolCall{Name: "propose_patch", Arguments: map[string]interface{}{"unified_diff": "..."}}, "proposing a patch"},
+		{llm.ToolCall{Name: "plain"}, "calling plain"},
+		{llm.ToolCall{Name: "missing"}, "calling missing"},
+	}
+
+	for _, tt := range tests {
+		if got := registry.Describe(tt.call); got != tt.want {
+			t.Errorf("Describe(%s) = %q, want %q", tt.call.Name, got, tt.want)
+		}
+	}
+}
+
+// TestBuiltinSchemas keeps every built-in definition self-consistent, so the
+// tool list, dispatch and validation cannot drift apart
+func TestBuiltinSchemas(t *testing.T) {
+	registry := Builtin()
+
+	for _, def := range registry.Definitions() {
+		properties, ok := def.Parameters["properties"].(map[string]interface{})
+		if !ok {
+			t.Errorf("%s: schema has no properties", def.Name)
+			continue
+		}
+		required, _ := def.Parameters["required"].([]string)
+		for _, name := range required {
+			if _, ok := properties[name]; !ok {
+				t.Errorf("%s: required argument %q is not declared", def.Name, name)
+			}
+		}
+		for name, property := range properties {
+			if _, ok := property.(map[string]interface{})["type"].(string); !ok {
+				t.Errorf("%s: argument %q has no type", def.Name, name)
+			}
+		}
+	}
+
+	if err := registry.Validate("run_command", map[string]interface{}{"cmd": "go test"}); err == nil {
+		t.Error("expected run_command to require 'command'")
+	}
+	if tool, _ := registry.Lookup("run_command"); tool.Permission() != PermissionExecute {
+		t.Error("run_command must need approval")
+	}
+}
+
+func TestProposePatch(t *testing.T) {
+	root := writeRepo(t, map[string]string{"main.go": "package main\n\nfunc main() {\n\tprintln(\"hello\")\n}\n"})
+	sess := &session.Session{}
+	registry := Builtin()
+
+	propose := func(diff string) (string, error) {
+		return registry.Execute(Env{RepoRoot: root, Session: sess}, llm.ToolCall{Name: "propose_patch", Arguments: map[string]interface{}{
+			"file_path":    "main.go",
+			"unified_diff": diff,
+		}})
+	}
+
+	// A diff written against stale content is sent back with what the file really says
+	_, err := propose("--- a/main.go\n+++ b/main.go\n@@ -3,3 +3,3 @@\n func main() {\n-\tprintln(\"hi\")\n+\tprintln(\"bye\")\n }\n")
+	if err == nil {
+		t.Fatal("expected a stale patch to be rejected")
+	}
+	for _, want := range []string{
+		`line 4: expected "\tprintln(\"hi\")", found "\tprintln(\"hello\")"`,
+		"4  \tprintln(\"hello\")",
+		"call propose_patch again",
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("expected rejection to contain %q, got:\n%v", want, err)
+		}
+	}
+	if len(sess.PendingPatches) != 0 {
+		t.Fatalf("rejected patch was queued: %+v", sess.PendingPatches)
+	}
+
+	if _, err := propose("--- a/main.go\n+++ b/main.go\n@@ -3,3 +3,3 @@\n func main() {\n-\tprintln(\"hello\")\n+\tprintln(\"bye\")\n }\n"); err != nil {
+		t.Fatal(err)
+	}
+	if len(sess.PendingPatches) != 1 || sess.PendingPatches[0].Retries != 1 {
+		t.Errorf("expected one queued patch after one retry, got %+v", sess.PendingPatches)
+	}
+	if sess.Stats.RejectedPatches != 1 || len(sess.Stats.PatchRetries) != 0 {
+		t.Errorf("unexpected stats: %+v", sess.Stats)
+	}
+
+	// Fuzz would let a wrong context line through; proposals must match exactly
+	if _, err := propose("--- a/main.go\n+++ b/main.go\n@@ -2,4 +2,4 @@\n // main greets\n func main() {\n-\tprintln(\"hello\")\n+\tprintln(\"bye\")\n }\n"); err == nil {
+		t.Error("expected a patch with a wrong context line to be rejected")
+	}
+
+	// A hunk found away from its header is queued, and the model is told where it matched
+	result, err := propose("--- a/main.go\n+++ b/main.go\n@@ -10,3 +10,3 @@\n func main() {\n-\tprintln(\"hello\")\n+\tprintln(\"bye\")\n }\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(result, "hunk 1: @@ -10,3 +10,3 @@ applied at line 3 (offset -7)") {
+		t.Errorf("expected the result to report the offset, got:\n%s", result)
+	}
+
+	// A miscounted header without a/ b/ prefixes is repaired, and the repairs are kept for review
+	result, err = propose("--- main.go\n+++ main.go\n@@ -1,2 +1,9 @@\n-package main\n+package app\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(result, "recounted as @@ -1 +1 @@") {
+		t.Errorf("expected the result to list the repairs, got:\n%s", result)
+	}
+	queued := sess.PendingPatches[len(sess.PendingPatches)-1]
+	if queued.UnifiedDiff != "--- a/main.go\n+++ b/main.go\n@@ -1 +1 @@\n-package main\n+package app\n" || len(queued.Repairs) != 3 {
+		t.Errorf("unexpected repaired patch: %+v", queued)
+	}
+}
+
+// TestProposePatchRetries checks that rejections count against the files a
+// diff names, whether or not file_path was given
+func TestProposePatchRetries(t *testing.T) {
+	root := writeRepo(t, map[string]string{
+		"a.go": "package a\n\nvar A = 1\n",
+		"b.go": "package b\n\nvar B = 1\n",
+	})
+	sess := &session.Session{}
+	registry := Builtin()
+
+	propose := func(args map[string]interface{}) error {
+		_, err := registry.Execute(Env{RepoRoot: root, Session: sess}, llm.ToolCall{Name: "propose_patch", Arguments: args})
+		return err
+	}
+	change := func(name, from, to string) string {
+		return "--- a/" + name + "\n+++ b/" + name + "\n@@ -3 +3 @@\n-" + from + "\n+" + to + "\n"
+	}
+
+	// Header-only proposals: a stale one for a.go, then good ones for b.go and a.go
+	if err := propose(map[string]interface{}{"unified_diff": change("a.go", "var A = 0", "var A = 2")}); err == nil {
+		t.Fatal("expected a stale patch to be rejected")
+	}
+	if err := propose(map[string]interface{}{"unified_diff": change("b.go", "var B = 1", "var B = 2")}); err != nil {
+		t.Fatal(err)
+	}
+	if err := propose(map[string]interface{}{"unified_diff": change("a.go", "var A = 1", "var A = 2")}); err != nil {
+		t.Fatal(err)
+	}
+
+	// A rejection sent with file_path counts for the headered diff that fixes it
+	if err := propose(map[string]interface{}{"file_path": "b.go", "unified_diff": change("b.go", "var B = 0", "var B = 3")}); err == nil {
+		t.Fatal("expected a stale patch to be rejected")
+	}
+	if err := propose(map[string]interface{}{"unified_diff": change("b.go", "var B = 1", "var B = 3")}); err != nil {
+		t.Fatal(err)
+	}
+
+	var retries []int
+	for _, p := range sess.PendingPatches {
+		retries = append(retries, p.Retries)
+	}
+	if !slices.Equal(retries, []int{0, 1, 1}) {
+		t.Errorf("expected retries [0 1 1], got %v", retries)
+	}
+	if sess.Stats.RejectedPatches != 2 || len(sess.Stats.PatchRetries) != 0 {
+		t.Errorf("unexpected stats: %+v", sess.Stats)
+	}
+}
+
+func TestProposeMultiFilePatch(t *testing.T) {
+	root := writeRepo(t, map[string]string{
+		"main.go": "package main\n\nfunc main() {\n\tgreet()\n}\n",
+		"util.go": "package main\n\nfunc greet() {}\n",
+	})
+	sess := &session.Session{}
+	registry := Builtin()
+
+	propose := func(diff string) (string, error) {
+		return registry.Execute(Env{RepoRoot: root, Session: sess}, llm.ToolCall{Name: "propose_patch", Arguments: map[string]interface{}{
+			"unified_diff": diff,
+		}})
+	}
+
+	result, err := propose("diff --git a/util.go b/greet.go\nrename from util.go\nrename to greet.go\n" +
+		"--- a/main.go\n+++ b/main.go\n@@ -4 +4 @@\n-\tgreet()\n+\tgreet() // see greet.go\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(result, "Patch proposed for util.go, greet.go, main.go") {
+		t.Errorf("unexpected result: %s", result)
+	}
+	if len(sess.PendingPatches) != 1 {
+		t.Fatalf("expected one queued patch, got %+v", sess.PendingPatches)
+	}
+	if got := sess.PendingPatches[0]; got.FilePath != "util.go" || strings.Join(got.Files, " ") != "util.go greet.go main.go" {
+		t.Errorf("unexpected patch: %+v", got)
+	}
+	if _, err := os.Stat(filepath.Join(root, "greet.go")); !os.IsNotExist(err) {
+		t.Errorf("proposing a patch must not touch the repository: %v", err)
+	}
+
+	// Paths named only in the diff's headers are held to the repository too
+	if _, err := propose("--- a/../escape.go\n+++ b/../escape.go\n@@ -1 +1 @@\n-a\n+b\n"); err == nil || !strings.Contains(err.Error(), "outside repository bounds") {
+		t.Errorf("expected a path outside the repository to be rejected, got %v", err)
+	}
+}
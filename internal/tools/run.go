@@ -1,35 +1,16 @@
 package tools
 
 import (
-	"bufio"
 	"fmt"
-	"os"
 	"os/exec"
 	"strings"
 )
 
-// RunCommand executes a shell command with explicit user approval
-// This is a security-critical function - commands must be approved
-func RunCommand(repoRoot, command string) (string, error) {
-	// Display command to user and request approval
-	fmt.Printf("\n⚠️  The agent wants to run this command:\n")
-	fmt.Printf("   %s\n\n", command)
-	fmt.Printf("Allow this command? [y/N]: ")
-
-	reader := bufio.NewReader(os.Stdin)
-	response, err := reader.ReadString('\n')
-	if err != nil {
-		return "", fmt.Errorf("failed to read user input: %w", err)
-	}
-
-	response = strings.TrimSpace(strings.ToLower(response))
-	if response != "y" && response != "yes" {
-		return "", fmt.Errorf("command rejected by user")
-	}
-
-	// Execute command
+// runCommand executes a shell command in the repository
+// Security: this runs anything, so it is only reached through run_command,
+// which the registry runs after the user approves each call (PermissionExecute)
+func runCommand(repoRoot, command string) (string, error) {
 	// Note: This is intentionally simple - runs via shell
-	// Security: User has explicitly approved this specific command
 	var cmd *exec.Cmd
 	if strings.Contains(command, " ") {
 		// Command with arguments - use sh -c
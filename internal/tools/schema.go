@@ -0,0 +1,350 @@
+package tools
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/yourusername/playground/internal/llm"
+)
+
+// ArgumentError reports tool call arguments that do not match the tool's schema
+// Its message is written for the model: every bad field, then the expected shape.
+type ArgumentError struct {
+	Tool     string
+	Problems []string // One per bad field, e.g. `"start_line" must be an integer, got string "10"`
+	Expected string   // Shape of valid arguments, e.g. {"path": string, "start_line"?: integer}
+}
+
+func (e *ArgumentError) Error() string {
+	return fmt.Sprintf("invalid arguments for %s: %s\nExpected arguments: %s (? marks optional)\nFix the arguments and call %s again.",
+		e.Tool, strings.Join(e.Problems, "; "), e.Expected, e.Tool)
+}
+
+// ValidateArgs checks arguments against a tool's JSON schema: required fields,
+// types, enums and unknown keys, recursing into objects and arrays
+func ValidateArgs(def llm.Tool, args map[string]interface{}) error {
+	if args == nil {
+		args = map[string]interface{}{}
+	}
+
+	var problems []string
+	validateObject("", args, def.Parameters, &problems)
+	if len(problems) == 0 {
+		return nil
+	}
+
+	return &ArgumentError{
+		Tool:     def.Name,
+		Problems: problems,
+		Expected: describeShape(def.Parameters),
+	}
+}
+
+// validateObject checks an object's keys against the schema's properties
+func validateObject(path string, obj map[string]interface{}, schema map[string]interface{}, problems *[]string) {
+	properties, _ := schema["properties"].(map[string]interface{})
+	additional, _ := schema["additionalProperties"].(bool)
+
+	for _, name := range sortedKeys(obj) {
+		if _, declared := properties[name]; declared || additional || properties == nil {
+			continue
+		}
+		problem := fmt.Sprintf("unknown argument %q", joinPath(path, name))
+		if guess := closestName(name, sortedKeys(properties)); guess != "" {
+			problem += fmt.Sprintf(" (did you mean %q?)", joinPath(path, guess))
+		}
+		*problems = append(*problems, problem)
+	}
+
+	for _, name := range stringList(schema["required"]) {
+		if value, ok := obj[name]; !ok || value == nil {
+			*problems = append(*problems, fmt.Sprintf("%q is required", joinPath(path, name)))
+		}
+	}
+
+	for _, name := range sortedKeys(obj) {
+		property, ok := properties[name].(map[string]interface{})
+		if ok && obj[name] != nil {
+			validateValue(joinPath(path, name), obj[name], property, problems)
+		}
+	}
+}
+
+// validateValue checks one value's type and enum, then its members
+func validateValue(path string, value interface{}, schema map[string]interface{}, problems *[]string) {
+	if types := stringList(schema["type"]); len(types) > 0 {
+		matched := false
+		for _, want := range types {
+			matched = matched || hasJSONType(value, want)
+		}
+		if !matched {
+			*problems = append(*problems, fmt.Sprintf("%q must be %s, got %s", path, withArticle(strings.Join(types, " or ")), describeValue(value)))
+			return
+		}
+	}
+
+	if enum, ok := schema["enum"]; ok {
+		allowed := enumValues(enum)
+		found := false
+		for _, option := range allowed {
+			found = found || option == value
+		}
+		if !found {
+			*problems = append(*problems, fmt.Sprintf("%q must be one of %s, got %s", path, formatEnum(allowed), describeValue(value)))
+		}
+	}
+
+	switch v := value.(type) {
+	case map[string]interface{}:
+		if _, ok := schema["properties"]; ok {
+			validateObject(path, v, schema, problems)
+		}
+	case []interface{}:
+		if items, ok := schema["items"].(map[string]interface{}); ok {
+			for i, item := range v {
+				validateValue(fmt.Sprintf("%s[%d]", path, i), item, items, problems)
+			}
+		}
+	}
+}
+
+// hasJSONType reports whether a decoded JSON value has the given JSON schema type
+func hasJSONType(value interface{}, want string) bool {
+	switch want {
+	case "string":
+		_, ok := value.(string)
+		return ok
+	case "integer":
+		n, ok := value.(float64)
+		return ok && n == math.Trunc(n)
+	case "number":
+		_, ok := value.(float64)
+		return ok
+	case "boolean":
+		_, ok := value.(bool)
+		return ok
+	case "object":
+		_, ok := value.(map[string]interface{})
+		return ok
+	case "array":
+		_, ok := value.([]interface{})
+		return ok
+	case "null":
+		return value == nil
+	}
+	return true
+}
+
+// describeShape renders the arguments a schema expects, e.g. {"path": string, "start_line"?: integer}
+// Required arguments come first, in schema order, then the optional ones by name.
+func describeShape(schema map[string]interface{}) string {
+	properties, _ := schema["properties"].(map[string]interface{})
+	required := stringList(schema["required"])
+
+	isRequired := make(map[string]bool)
+	names := append([]string(nil), required...)
+	for _, name := range required {
+		isRequired[name] = true
+	}
+	for _, name := range sortedKeys(properties) {
+		if !isRequired[name] {
+			names = append(names, name)
+		}
+	}
+
+	fields := make([]string, 0, len(names))
+	for _, name := range names {
+		property, _ := properties[name].(map[string]interface{})
+		marker := "?"
+		if isRequired[name] {
+			marker = ""
+		}
+		fields = append(fields, fmt.Sprintf("%q%s: %s", name, marker, describeType(property)))
+	}
+	return "{" + strings.Join(fields, ", ") + "}"
+}
+
+// describeType renders one property's type, e.g. string, "a" | "b", [string] or a nested shape
+func describeType(schema map[string]interface{}) string {
+	if enum, ok := schema["enum"]; ok {
+		return strings.Join(formatValues(enumValues(enum)), " | ")
+	}
+
+	types := stringList(schema["type"])
+	switch {
+	case len(types) == 1 && types[0] == "object":
+		if _, ok := schema["properties"]; ok {
+			return describeShape(schema)
+		}
+	case len(types) == 1 && types[0] == "array":
+		if items, ok := schema["items"].(map[string]interface{}); ok {
+			return "[" + describeType(items) + "]"
+		}
+	case len(types) == 0:
+		return "any"
+	}
+	return strings.Join(types, " | ")
+}
+
+// describeValue names a value's JSON type, with the value itself when it is short
+func describeValue(value interface{}) string {
+	var kind string
+	switch value.(type) {
+	case nil:
+		return "null"
+	case string:
+		kind = "string"
+	case float64:
+		kind = "number"
+	case bool:
+		kind = "boolean"
+	case map[string]interface{}:
+		return "object"
+	case []interface{}:
+		return "array"
+	default:
+		return fmt.Sprintf("%T", value)
+	}
+
+	data, _ := json.Marshal(value)
+	if len(data) > 40 {
+		return kind
+	}
+	return kind + " " + string(data)
+}
+
+// closestName suggests the declared name a misspelt argument was probably meant to be
+func closestName(name string, candidates []string) string {
+	best, bestDistance := "", 3 // Only suggest close matches
+	for _, candidate := range candidates {
+		if d := editDistance(strings.ToLower(name), strings.ToLower(candidate)); d < bestDistance {
+			best, bestDistance = candidate, d
+		}
+	}
+	if best != "" {
+		return best
+	}
+
+	// Abbreviations such as "cmd" for "command", and other forms of the same word ("retry", "retries")
+	for _, candidate := range candidates {
+		if isAbbreviation(name, candidate) || (len(name) >= 4 && strings.HasPrefix(candidate, name[:4])) {
+			return candidate
+		}
+	}
+	return ""
+}
+
+// isAbbreviation reports whether short's letters appear in order in long, starting with its first letter
+func isAbbreviation(short, long string) bool {
+	if short == "" || long == "" || short[0] != long[0] {
+		return false
+	}
+	i := 0
+	for j := 0; j < len(long) && i < len(short); j++ {
+		if short[i] == long[j] {
+			i++
+		}
+	}
+	return i == len(short)
+}
+
+// editDistance is the Levenshtein distance between two strings
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur := make([]int, len(b)+1)
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev = cur
+	}
+	return prev[len(b)]
+}
+
+// stringList reads a schema keyword that may be a string or a list of strings
+func stringList(v interface{}) []string {
+	switch v := v.(type) {
+	case string:
+		return []string{v}
+	case []string:
+		return v
+	case []interface{}:
+		var out []string
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				out = append(out, s)
+			}
+		}
+		return out
+	}
+	return nil
+}
+
+// enumValues reads an enum keyword, normalising numbers to float64 like decoded JSON
+func enumValues(v interface{}) []interface{} {
+	switch v := v.(type) {
+	case []string:
+		out := make([]interface{}, len(v))
+		for i, s := range v {
+			out[i] = s
+		}
+		return out
+	case []int:
+		out := make([]interface{}, len(v))
+		for i, n := range v {
+			out[i] = float64(n)
+		}
+		return out
+	case []interface{}:
+		return v
+	}
+	return nil
+}
+
+func formatEnum(values []interface{}) string {
+	return strings.Join(formatValues(values), ", ")
+}
+
+func formatValues(values []interface{}) []string {
+	out := make([]string, len(values))
+	for i, value := range values {
+		data, _ := json.Marshal(value)
+		out[i] = string(data)
+	}
+	return out
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func joinPath(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
+
+func withArticle(word string) string {
+	switch word[0] {
+	case 'a', 'e', 'i', 'o', 'u':
+		return "an " + word
+	}
+	return "a " + word
+}
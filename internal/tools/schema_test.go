@@ -0,0 +1,118 @@
+package tools
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/yourusername/playground/internal/llm"
+)
+
+func TestValidateArgs(t *testing.T) {
+	def := llm.Tool{
+		Name: "deploy",
+		Parameters: map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"command": param("string", "Command to run"),
+				"env":     map[string]interface{}{"type": "string", "enum": []string{"dev", "prod"}},
+				"retries": param("integer", "Attempts"),
+				"dry_run": param("boolean", "Only print"),
+				"targets": map[string]interface{}{
+					"type":  "array",
+					"items": map[string]interface{}{"type": "string"},
+				},
+				"limits": map[string]interface{}{
+					"type":       "object",
+					"properties": map[string]interface{}{"cpu": param("number", "Cores")},
+					"required":   []string{"cpu"},
+				},
+			},
+			"required": []string{"command", "env"},
+		},
+	}
+
+	tests := []struct {
+		name     string
+		args     map[string]interface{}
+		problems []string
+	}{
+		{
+			name: "valid",
+			args: map[string]interface{}{
+				"command": "make", "env": "dev", "retries": float64(3), "dry_run": true,
+				"targets": []interface{}{"a", "b"}, "limits": map[string]interface{}{"cpu": 1.5},
+			},
+		},
+		{
+			name:     "missing required",
+			args:     map[string]interface{}{"env": "dev"},
+			problems: []string{`"command" is required`},
+		},
+		{
+			name:     "null counts as missing",
+			args:     map[string]interface{}{"command": nil, "env": "dev"},
+			problems: []string{`"command" is required`},
+		},
+		{
+			name:     "no arguments",
+			args:     nil,
+			problems: []string{`"command" is required`, `"env" is required`},
+		},
+		{
+			name:     "unknown key with suggestion",
+			args:     map[string]interface{}{"cmd": "make", "env": "dev"},
+			problems: []string{`unknown argument "cmd" (did you mean "command"?)`, `"command" is required`},
+		},
+		{
+			name:     "misspelt key",
+			args:     map[string]interface{}{"command": "make", "env": "dev", "retry": float64(1)},
+			problems: []string{`unknown argument "retry" (did you mean "retries"?)`},
+		},
+		{
+			name:     "unknown key without suggestion",
+			args:     map[string]interface{}{"command": "make", "env": "dev", "verbose": true},
+			problems: []string{`unknown argument "verbose"`},
+		},
+		{
+			name:     "wrong types",
+			args:     map[string]interface{}{"command": "make", "env": "dev", "retries": "3", "dry_run": "yes"},
+			problems: []string{`"dry_run" must be a boolean, got string "yes"`, `"retries" must be an integer, got string "3"`},
+		},
+		{
+			name:     "enum",
+			args:     map[string]interface{}{"command": "make", "env": "staging"},
+			problems: []string{`"env" must be one of "dev", "prod", got string "staging"`},
+		},
+		{
+			name:     "nested",
+			args:     map[string]interface{}{"command": "make", "env": "dev", "targets": []interface{}{"a", float64(2)}, "limits": map[string]interface{}{"mem": float64(1)}},
+			problems: []string{`unknown argument "limits.mem"`, `"limits.cpu" is required`, `"targets[1]" must be a string, got number 2`},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateArgs(def, tt.args)
+			if tt.problems == nil {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+
+			var argErr *ArgumentError
+			if !errors.As(err, &argErr) {
+				t.Fatalf("expected an ArgumentError, got %v", err)
+			}
+			if !reflect.DeepEqual(argErr.Problems, tt.problems) {
+				t.Errorf("problems:\n got %q\nwant %q", argErr.Problems, tt.problems)
+			}
+
+			want := `{"command": string, "env": "dev" | "prod", "dry_run"?: boolean, "limits"?: {"cpu": number}, "retries"?: integer, "targets"?: [string]}`
+			if argErr.Expected != want {
+				t.Errorf("expected shape:\n got %s\nwant %s", argErr.Expected, want)
+			}
+		})
+	}
+}
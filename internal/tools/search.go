@@ -0,0 +1,145 @@
+package tools
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+const (
+	defaultMatchesPerFile = 5
+	defaultSearchResults  = 50
+	maxSearchFileSize     = 1 << 20 // Larger files are skipped
+	maxMatchLineLength    = 160
+)
+
+// SearchOptions narrows a code search
+type SearchOptions struct {
+	Path       string // File or directory to search, relative to repo root ("" searches everything)
+	Include    string // Glob that file paths must match, e.g. "*.go" or "internal/**/*_test.go"
+	MaxPerFile int    // Matches reported per file (default 5)
+	MaxResults int    // Matches reported in total (default 50)
+	IgnoreCase bool
+}
+
+// SearchCode finds lines matching a regular expression across the repository
+// Results are one "path:line: text" entry per match; .gitignore'd and hidden files are skipped
+func SearchCode(repoRoot, pattern string, opts SearchOptions) (string, error) {
+	if pattern == "" {
+		return "", fmt.Errorf("empty search pattern")
+	}
+	expr := pattern
+	if opts.IgnoreCase {
+		expr = "(?i)" + expr
+	}
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return "", fmt.Errorf("invalid pattern: %w", err)
+	}
+
+	var include *regexp.Regexp
+	if opts.Include != "" {
+		if include, err = globRegexp(opts.Include); err != nil {
+			return "", fmt.Errorf("invalid include glob: %w", err)
+		}
+	}
+
+	if opts.MaxPerFile <= 0 {
+		opts.MaxPerFile = defaultMatchesPerFile
+	}
+	if opts.MaxResults <= 0 {
+		opts.MaxResults = defaultSearchResults
+	}
+
+	start, err := resolvePath(repoRoot, opts.Path)
+	if err != nil {
+		return "", err
+	}
+	if _, err := os.Stat(start); err != nil {
+		return "", fmt.Errorf("path not found: %s", opts.Path)
+	}
+
+	var out strings.Builder
+	matches, files := 0, 0
+	truncated := false
+
+	err = walkRepo(repoRoot, start, func(rel, absPath string) error {
+		if include != nil && !include.MatchString(rel) {
+			return nil
+		}
+
+		lines, total := searchFile(absPath, re, opts.MaxPerFile)
+		if total == 0 {
+			return nil
+		}
+		files++
+
+		for _, line := range lines {
+			if matches == opts.MaxResults {
+				truncated = true
+				return filepath.SkipAll
+			}
+			fmt.Fprintf(&out, "%s:%s\n", rel, line)
+			matches++
+		}
+		if total > len(lines) {
+			fmt.Fprintf(&out, "%s: %d more matches\n", rel, total-len(lines))
+		}
+		return nil
+	})
+	if err != nil {
+		return "", fmt.Errorf("search failed: %w", err)
+	}
+
+	if files == 0 {
+		return fmt.Sprintf("No matches for %q", pattern), nil
+	}
+	if truncated {
+		fmt.Fprintf(&out, "Stopped after %d matches; narrow the search with path or include\n", opts.MaxResults)
+	}
+	return out.String(), nil
+}
+
+// searchFile returns up to limit "line: text" entries for the matches in a file,
+// and the total number of matching lines. Binary and oversized files have no matches.
+func searchFile(absPath string, re *regexp.Regexp, limit int) ([]string, int) {
+	info, err := os.Stat(absPath)
+	if err != nil || info.Size() > maxSearchFileSize {
+		return nil, 0
+	}
+
+	data, err := os.ReadFile(absPath)
+	if err != nil || bytes.IndexByte(data[:min(len(data), 8000)], 0) >= 0 {
+		return nil, 0
+	}
+
+	var lines []string
+	total := 0
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 0, 64*1024), maxSearchFileSize)
+	for n := 1; scanner.Scan(); n++ {
+		text := scanner.Text()
+		if !re.MatchString(text) {
+			continue
+		}
+		total++
+		if len(lines) < limit {
+			lines = append(lines, fmt.Sprintf("%d: %s", n, truncateMatch(strings.TrimSpace(text))))
+		}
+	}
+
+	return lines, total
+}
+
+// truncateMatch shortens long matching lines, e.g. minified code
+func truncateMatch(line string) string {
+	if len(line) <= maxMatchLineLength {
+		return line
+	}
+	return line[:maxMatchLineLength] + "..."
+}
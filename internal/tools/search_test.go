@@ -0,0 +1,206 @@
+package tools
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeRepo creates files under a temporary repo root
+func writeRepo(t *testing.T, files map[string]string) string {
+	t.Helper()
+
+	root := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return root
+}
+
+func searchRepo(t *testing.T) string {
+	return writeRepo(t, map[string]string{
+		".gitignore":               "build/\n*.log\n/vendor\n!keep.log\n",
+		"main.go":                  "package main\n\nfunc main() {\n\tRun()\n}\n",
+		"internal/run.go":          "package internal\n\nfunc Run() {}\n\nfunc RunAll() { Run() }\n",
+		"internal/run_test.go":     "package internal\n\nfunc TestRun() { Run() }\n",
+		"internal/.gitignore":      "generated.go\n",
+		"internal/generated.go":    "package internal\n\nfunc Run2() {}\n",
+		"build/out.go":             "func Run() {}\n",
+		"debug.log":                "Run\n",
+		"keep.log":                 "Run\n",
+		"vendor/lib.go":            "func Run() {}\n",
+		"docs/vendor/notes.txt":    "Run\n",
+		".hidden/secret.go":        "func Run() {}\n",
+		"internal/data/binary.bin": "Run\x00\x01",
+	})
+}
+
+func TestSearchCode(t *testing.T) {
+	root := searchRepo(t)
+
+	tests := []struct {
+		name    string
+		pattern string
+		opts    SearchOptions
+		want    []string
+		wantErr bool
+	}{
+		{
+			name:    "respects gitignore",
+			pattern: `Run\b`,
+			want: []string{
+				"docs/vendor/notes.txt:1: Run",
+				"internal/run.go:3: func Run() {}",
+				"internal/run.go:5: func RunAll() { Run() }",
+				"internal/run_test.go:3: func TestRun() { Run() }",
+				"keep.log:1: Run",
+				"main.go:4: Run()",
+			},
+		},
+		{
+			name:    "include glob",
+			pattern: `Run\(`,
+			opts:    SearchOptions{Include: "*_test.go"},
+			want:    []string{"internal/run_test.go:3: func TestRun() { Run() }"},
+		},
+		{
+			name:    "path and per-file cap",
+			pattern: `Run`,
+			opts:    SearchOptions{Path: "internal", MaxPerFile: 1},
+			want: []string{
+				"internal/run.go:3: func Run() {}",
+				"internal/run.go: 1 more matches",
+				"internal/run_test.go:3: func TestRun() { Run() }",
+			},
+		},
+		{
+			name:    "total cap",
+			pattern: `Run`,
+			opts:    SearchOptions{MaxResults: 1},
+			want: []string{
+				"docs/vendor/notes.txt:1: Run",
+				"Stopped after 1 matches; narrow the search with path or include",
+			},
+		},
+		{
+			name:    "ignore case",
+			pattern: `func runall`,
+			opts:    SearchOptions{IgnoreCase: true},
+			want:    []string{"internal/run.go:5: func RunAll() { Run() }"},
+		},
+		{
+			name:    "no matches",
+			pattern: `Missing`,
+			want:    []string{`No matches for "Missing"`},
+		},
+		{
+			name:    "invalid pattern",
+			pattern: `Run(`,
+			wantErr: true,
+		},
+		{
+			name:    "outside repo",
+			pattern: `Run`,
+			opts:    SearchOptions{Path: "../"},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := SearchCode(root, tt.pattern, tt.opts)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected error, got %q", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			want := strings.Join(tt.want, "\n")
+			if strings.TrimRight(got, "\n") != want {
+				t.Errorf("got:\n%s\nwant:\n%s", got, want)
+			}
+		})
+	}
+}
+
+func TestFindFiles(t *testing.T) {
+	root := searchRepo(t)
+
+	tests := []struct {
+		name    string
+		pattern string
+		path    string
+		want    []string
+	}{
+		{
+			name:    "base name at any depth",
+			pattern: "*.go",
+			want:    []string{"internal/run.go", "internal/run_test.go", "main.go"},
+		},
+		{
+			name:    "double star",
+			pattern: "internal/**/*_test.go",
+			want:    []string{"internal/run_test.go"},
+		},
+		{
+			name:    "within path",
+			pattern: "*.txt",
+			path:    "docs",
+			want:    []string{"docs/vendor/notes.txt"},
+		},
+		{
+			name:    "negated ignore",
+			pattern: "*.log",
+			want:    []string{"keep.log"},
+		},
+		{
+			name:    "no matches",
+			pattern: "*.rs",
+			want:    []string{`No files match "*.rs"`},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := FindFiles(root, tt.pattern, tt.path)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			want := strings.Join(tt.want, "\n")
+			if strings.TrimRight(got, "\n") != want {
+				t.Errorf("got:\n%s\nwant:\n%s", got, want)
+			}
+		})
+	}
+
+	if _, err := FindFiles(root, "*.go", "../.."); err == nil {
+		t.Error("expected an error for a path outside the repository")
+	}
+}
+
+func TestResolvePath(t *testing.T) {
+	root := t.TempDir()
+
+	for _, rel := range []string{".", "", "a/b.go", "..foo", "a/../b"} {
+		if _, err := resolvePath(root, rel); err != nil {
+			t.Errorf("resolvePath(%q) = %v, want no error", rel, err)
+		}
+	}
+	for _, rel := range []string{"..", "../x", "a/../../x"} {
+		if _, err := resolvePath(root, rel); err == nil {
+			t.Errorf("resolvePath(%q) succeeded, want an error", rel)
+		}
+	}
+}
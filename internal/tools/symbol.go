@@ -0,0 +1,147 @@
+package tools
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// goDecl is a top-level function, method or type declaration in a Go file
+type goDecl struct {
+	name  string // "Run", or "Agent.Run" for a method
+	kind  string // "func", "method" or "type"
+	start int    // First line, including the doc comment
+	end   int
+}
+
+// ReadSymbol returns one function, method or type declaration from a Go file, with its doc comment
+// Methods can be named "Type.Method" or "(*Type).Method"; a bare method name works when it is unique.
+func ReadSymbol(repoRoot, relPath, symbol string) (string, error) {
+	if filepath.Ext(relPath) != ".go" {
+		return "", fmt.Errorf("read_symbol only supports Go files: %s", relPath)
+	}
+
+	content, err := readRepoFile(repoRoot, relPath)
+	if err != nil {
+		return "", err
+	}
+
+	decls, err := goDecls(content)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse %s: %w", relPath, err)
+	}
+
+	decl, err := findDecl(decls, normalizeSymbol(symbol))
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", relPath, err)
+	}
+
+	title := fmt.Sprintf("%s: %s %s", relPath, decl.kind, decl.name)
+	return numberLines(title, splitLines(content), decl.start, decl.end), nil
+}
+
+// goDecls lists the top-level declarations in Go source, in file order
+func goDecls(content string) ([]goDecl, error) {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "", content, parser.ParseComments)
+	if err != nil {
+		return nil, err
+	}
+
+	lines := func(doc *ast.CommentGroup, node ast.Node) (int, int) {
+		start := node.Pos()
+		if doc != nil {
+			start = doc.Pos()
+		}
+		return fset.Position(start).Line, fset.Position(node.End()).Line
+	}
+
+	var decls []goDecl
+	for _, d := range file.Decls {
+		switch d := d.(type) {
+		case *ast.FuncDecl:
+			decl := goDecl{name: d.Name.Name, kind: "func"}
+			if d.Recv != nil && len(d.Recv.List) > 0 {
+				decl.name = receiverName(d.Recv.List[0].Type) + "." + d.Name.Name
+				decl.kind = "method"
+			}
+			decl.start, decl.end = lines(d.Doc, d)
+			decls = append(decls, decl)
+
+		case *ast.GenDecl:
+			if d.Tok != token.TYPE {
+				continue
+			}
+			for _, spec := range d.Specs {
+				ts := spec.(*ast.TypeSpec)
+				decl := goDecl{name: ts.Name.Name, kind: "type"}
+				if d.Lparen.IsValid() {
+					// Grouped "type ( ... )": just this spec
+					decl.start, decl.end = lines(ts.Doc, ts)
+				} else {
+					decl.start, decl.end = lines(d.Doc, d)
+				}
+				decls = append(decls, decl)
+			}
+		}
+	}
+
+	return decls, nil
+}
+
+// receiverName returns the type name of a method receiver, e.g. "Agent" for (a *Agent) or (l *List[T])
+func receiverName(expr ast.Expr) string {
+	switch t := expr.(type) {
+	case *ast.StarExpr:
+		return receiverName(t.X)
+	case *ast.IndexExpr:
+		return receiverName(t.X)
+	case *ast.IndexListExpr:
+		return receiverName(t.X)
+	case *ast.Ident:
+		return t.Name
+	}
+	return ""
+}
+
+// normalizeSymbol accepts "(*Agent).Run", "*Agent.Run" and "Agent.Run" alike
+func normalizeSymbol(symbol string) string {
+	symbol = strings.TrimSpace(symbol)
+	symbol = strings.NewReplacer("(", "", ")", "", "*", "").Replace(symbol)
+	return symbol
+}
+
+// findDecl picks the declaration named symbol; a bare name falls back to a unique method
+func findDecl(decls []goDecl, symbol string) (goDecl, error) {
+	var methods []goDecl
+	for _, decl := range decls {
+		if decl.name == symbol {
+			return decl, nil
+		}
+		if decl.kind == "method" && strings.HasSuffix(decl.name, "."+symbol) {
+			methods = append(methods, decl)
+		}
+	}
+
+	switch len(methods) {
+	case 1:
+		return methods[0], nil
+	case 0:
+		names := make([]string, 0, len(decls))
+		for _, decl := range decls {
+			names = append(names, decl.name)
+		}
+		sort.Strings(names)
+		return goDecl{}, fmt.Errorf("symbol %q not found; declared: %s", symbol, strings.Join(names, ", "))
+	default:
+		names := make([]string, 0, len(methods))
+		for _, decl := range methods {
+			names = append(names, decl.name)
+		}
+		return goDecl{}, fmt.Errorf("symbol %q is ambiguous: %s", symbol, strings.Join(names, ", "))
+	}
+}